@@ -3,11 +3,13 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/jimyag/log"
 	"github.com/spf13/cobra"
 
-	"github.com/jimyag/parquet-tools/internal/dumper"
+	"github.com/jimyag/parquet-tools/pkg/parquettools"
 )
 
 var catCmd = &cobra.Command{
@@ -19,87 +21,119 @@ var catCmd = &cobra.Command{
 var (
 	convertInt96AsTime bool
 	count              int64
+	timezone           string
+	where              string
 )
 
 func init() {
 	catCmd.PersistentFlags().BoolVarP(&convertInt96AsTime, "convert", "", false, "convert int96 as time,false print as int96")
 	catCmd.PersistentFlags().Int64VarP(&count, "count", "n", 0, "print count rows")
+	catCmd.PersistentFlags().StringVarP(&timezone, "timezone", "", "UTC", "time zone of timestamps adjusted to UTC, such as Local or Europe/Berlin")
+	catCmd.PersistentFlags().StringVarP(&where, "where", "w", "", "print the rows matching an expression such as \"country = 'DE' AND amount > 100\"")
+	addColumnsFlag(catCmd)
 	rootCmd.AddCommand(catCmd)
 }
 
 func catRun(cmd *cobra.Command, args []string) {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		log.Error(err).Msg("error loading time zone")
+		return
+	}
+	args, err = expandInputs(args)
+	if err != nil {
+		log.Error(err).Msg("error expanding inputs")
+		return
+	}
 	rdrs, err := getReaders(args)
 	if err != nil {
+		// the other inputs are still printed
 		log.Error(err).Msg("error getting readers")
-		return
 	}
 	for _, rdr := range rdrs {
-		if count == 0 {
-			count = rdr.MetaData().NumRows + 1
+		if rdr == nil {
+			continue
+		}
+		cols, err := selectColumns(rdr)
+		if err != nil {
+			log.Error(err).Msg("error selecting columns")
+			return
+		}
+		var pred *parquettools.Predicate
+		if where != "" {
+			pred, err = parquettools.NewPredicate(where, rdr.MetaData().Schema, loc)
+			if err != nil {
+				log.Error(err).Msg("error parsing --where")
+				return
+			}
+		}
+		rows := parquettools.NewRowIterator(rdr, parquettools.RowOptions{
+			ConvertInt96AsTime: convertInt96AsTime,
+			Location:           loc,
+			Columns:            cols,
+			Where:              pred,
+		})
+		limit := count
+		if limit == 0 {
+			limit = rdr.MetaData().NumRows + 1
 		}
-		var printNum int64
-		for r := 0; r < rdr.NumRowGroups(); r++ {
-			rgr := rdr.RowGroup(r)
-			scanners := make([]*dumper.Dumper, rdr.MetaData().Schema.NumColumns())
-			fields := make([]string, rdr.MetaData().Schema.NumColumns())
-			for c := range rdr.MetaData().Schema.NumColumns() {
-				col, err := rgr.Column(c)
-				if err != nil {
-					log.Error(err).Int("column", c).Msg("error getting column")
-					return
-				}
-				scanners[c] = dumper.NewDumper(col, convertInt96AsTime)
-				fields[c] = col.Descriptor().Path()
+		for n := int64(0); n < limit; n++ {
+			row, ok := rows.Next()
+			if !ok {
+				break
+			}
+			var b strings.Builder
+			if err := writeRow(&b, row); err != nil {
+				log.Error(err).Msg("error marshalling json")
+				return
 			}
-			var line string
-			for {
-				// printNum is used to limit the number of rows
-				if printNum >= count {
-					return
-				}
-				if line == "" {
-					line = "{"
-				} else {
-					line = "\n{"
-				}
+			fmt.Println(b.String())
+		}
+		if err := rows.Err(); err != nil {
+			log.Error(err).Msg("error reading rows")
+			return
+		}
+	}
+}
+
+// writeRow writes a row as a JSON object, nested groups and maps as
+// objects and lists as arrays.
+func writeRow(b *strings.Builder, row parquettools.Row) error {
+	b.WriteString("{")
+	for i, f := range row {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(b, "%q: ", f.Name)
+		if err := writeValue(b, f.Value); err != nil {
+			return err
+		}
+	}
+	b.WriteString("}")
+	return nil
+}
 
-				data := false
-				first := true
-				for idx, s := range scanners {
-					if val, ok := s.Next(); ok {
-						if !data {
-							fmt.Print(line)
-						}
-						data = true
-						if val == nil {
-							continue
-						}
-						if !first {
-							fmt.Print(",")
-						}
-						first = false
-						switch val.(type) {
-						case bool, int32, int64, float32, float64:
-						default:
-							val = s.FormatValue(val, 0)
-						}
-						jsonVal, err := json.Marshal(val)
-						if err != nil {
-							log.Error(err).
-								Str("val", fmt.Sprintf("%+v", val)).
-								Msg("error marshalling json")
-							return
-						}
-						fmt.Printf("%q: %s", fields[idx], jsonVal)
-					}
-				}
-				if !data {
-					break
-				}
-				fmt.Print("}")
-				printNum++
+func writeValue(b *strings.Builder, val any) error {
+	switch val := val.(type) {
+	case parquettools.Row:
+		return writeRow(b, val)
+	case []any:
+		b.WriteString("[")
+		for i, e := range val {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			if err := writeValue(b, e); err != nil {
+				return err
 			}
-			fmt.Println()
 		}
+		b.WriteString("]")
+		return nil
+	}
+	jsonVal, err := json.Marshal(val)
+	if err != nil {
+		return fmt.Errorf("%+v: %w", val, err)
 	}
+	b.Write(jsonVal)
+	return nil
 }
@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/jimyag/parquet-tools/pkg/parquettools"
+)
+
+// columns are the --columns selectors, see parquettools.SelectColumns.
+var columns []string
+
+func addColumnsFlag(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVarP(&columns, "columns", "c", nil, "comma separated column paths such as a,b.c,list.element, a group selects the columns below it")
+}
+
+// selectColumns resolves --columns for a file, nil without the flag.
+func selectColumns(rdr *parquettools.File) ([]int, error) {
+	if len(columns) == 0 {
+		return nil, nil
+	}
+	return parquettools.SelectColumns(rdr.MetaData().Schema, columns)
+}
@@ -1,15 +1,15 @@
 package cmd
 
 import (
-	"bytes"
 	"fmt"
 	"os"
 	"os/exec"
 
-	"github.com/apache/arrow/go/v17/parquet/schema"
 	"github.com/jimyag/log"
 	"github.com/sergi/go-diff/diffmatchpatch"
 	"github.com/spf13/cobra"
+
+	"github.com/jimyag/parquet-tools/pkg/parquettools"
 )
 
 var diffCmd = &cobra.Command{
@@ -23,6 +23,11 @@ func init() {
 }
 
 func diffRun(cmd *cobra.Command, args []string) {
+	args, err := expandInputs(args)
+	if err != nil {
+		log.Error(err).Msg("error expanding inputs")
+		return
+	}
 	if len(args) != 2 {
 		log.Error().Msg("diff requires two parquet files")
 		return
@@ -32,14 +37,7 @@ func diffRun(cmd *cobra.Command, args []string) {
 		log.Error(err).Msg("error getting readers")
 		return
 	}
-	rdr1 := rdrs[0]
-	rdr2 := rdrs[1]
-	schema1 := rdr1.MetaData().Schema.Root()
-	schema2 := rdr2.MetaData().Schema.Root()
-	var buf1 bytes.Buffer
-	var buf2 bytes.Buffer
-	schema.PrintSchema(schema1, &buf1, 2)
-	schema.PrintSchema(schema2, &buf2, 2)
+	d := parquettools.InspectDiff(rdrs[0].Reader, rdrs[1].Reader)
 	// 如果系统有 diff 命令，则使用系统 diff 命令
 	// 将结果写到临时文件中，然后使用 diff 命令比较
 	if _, err := exec.LookPath("diff"); err == nil {
@@ -49,7 +47,7 @@ func diffRun(cmd *cobra.Command, args []string) {
 			return
 		}
 		defer os.Remove(tmp1.Name())
-		if _, err = tmp1.Write(buf1.Bytes()); err != nil {
+		if _, err = tmp1.Write([]byte(d.Left.Text)); err != nil {
 			log.Error(err).Msg("error writing to temp file")
 			return
 		}
@@ -59,7 +57,7 @@ func diffRun(cmd *cobra.Command, args []string) {
 			return
 		}
 		defer os.Remove(tmp2.Name())
-		if _, err = tmp2.Write(buf2.Bytes()); err != nil {
+		if _, err = tmp2.Write([]byte(d.Right.Text)); err != nil {
 			log.Error(err).Msg("error writing to temp file")
 			return
 		}
@@ -69,7 +67,5 @@ func diffRun(cmd *cobra.Command, args []string) {
 		cmd.Run()
 		return
 	}
-	dmp := diffmatchpatch.New()
-	diffs := dmp.DiffMain(buf1.String(), buf2.String(), true)
-	fmt.Println(dmp.DiffPrettyText(diffs))
+	fmt.Println(diffmatchpatch.New().DiffPrettyText(d.Diffs))
 }
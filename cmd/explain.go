@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+	"github.com/jimyag/log"
+	"github.com/spf13/cobra"
+
+	"github.com/jimyag/parquet-tools/pkg/parquettools"
+)
+
+var explainCmd = &cobra.Command{
+	Use:   "explain",
+	Short: "print which row groups and pages cat --where reads",
+	Long: `print which row groups and pages cat --where reads: the row groups
+whose column chunk statistics cannot match the expression are skipped and,
+for files with page indexes, the pages whose min and max values and null
+counts cannot match are skipped as well.`,
+	Run: explain,
+}
+
+func init() {
+	explainCmd.Flags().StringVarP(&timezone, "timezone", "", "UTC", "time zone of timestamps adjusted to UTC, such as Local or Europe/Berlin")
+	explainCmd.Flags().StringVarP(&where, "where", "w", "", "an expression such as \"country = 'DE' AND amount > 100\"")
+	addColumnsFlag(explainCmd)
+	rootCmd.AddCommand(explainCmd)
+}
+
+func explain(cmd *cobra.Command, args []string) {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		log.Error(err).Msg("error loading time zone")
+		return
+	}
+	args, err = expandInputs(args)
+	if err != nil {
+		log.Error(err).Msg("error expanding inputs")
+		return
+	}
+	rdrs, err := getReaders(args)
+	if err != nil {
+		// the other inputs are still explained
+		log.Error(err).Msg("error getting readers")
+	}
+	for i, rdr := range rdrs {
+		if rdr == nil {
+			continue
+		}
+		cols, err := selectColumns(rdr)
+		if err != nil {
+			log.Error(err).Msg("error selecting columns")
+			return
+		}
+		var pred *parquettools.Predicate
+		if where != "" {
+			pred, err = parquettools.NewPredicate(where, rdr.MetaData().Schema, loc)
+			if err != nil {
+				log.Error(err).Msg("error parsing --where")
+				return
+			}
+		}
+		plan, err := parquettools.PlanScan(rdr, pred, cols)
+		if err != nil {
+			log.Error(err).Msg("error planning the scan")
+			return
+		}
+		fmt.Println(args[i])
+		printScanPlan(plan)
+	}
+}
+
+// printScanPlan prints a table of the row groups of a plan and one of the
+// pages read of the column chunks with an offset index.
+func printScanPlan(plan *parquettools.ScanPlan) {
+	t := table.NewWriter()
+	t.Style().Options.DrawBorder = true
+	t.Style().Format.Footer = text.FormatDefault
+	t.AppendHeader(table.Row{"row group", "rows", "rows read", "pages read", "bytes", "bytes read", "pruned by"})
+	pages := table.NewWriter()
+	pages.Style().Options.DrawBorder = true
+	pages.AppendHeader(table.Row{"row group", "column", "pages", "read pages", "bytes read"})
+	var rows, rowsRead, bytes, bytesRead int64
+	for _, rg := range plan.RowGroups {
+		total, read := rg.Bytes()
+		rows += rg.NumRows
+		rowsRead += rg.ReadRows()
+		bytes += total
+		bytesRead += read
+		pagesRead := "-"
+		var numPages, numRead int
+		for _, c := range rg.Columns {
+			if len(c.Pages) == 0 {
+				continue
+			}
+			numPages += len(c.Pages)
+			readPages := ""
+			if !rg.Skipped {
+				readPages = c.ReadPages()
+				for _, p := range c.Pages {
+					if p.Read {
+						numRead++
+					}
+				}
+			}
+			pages.AppendRow(table.Row{rg.RowGroup, c.Path, len(c.Pages), readPages, c.ReadBytes})
+		}
+		if numPages > 0 {
+			pagesRead = fmt.Sprintf("%d/%d", numRead, numPages)
+		}
+		prunedBy := rg.Reason
+		if !rg.Skipped && rg.Rows != nil {
+			prunedBy = "page index"
+		}
+		t.AppendRow(table.Row{rg.RowGroup, rg.NumRows, rg.ReadRows(), pagesRead, total, read, prunedBy})
+	}
+	t.AppendFooter(table.Row{"total", rows, rowsRead, "", bytes, bytesRead, fmt.Sprintf("%d bytes skipped", bytes-bytesRead)})
+	fmt.Println(t.Render())
+	if pages.Length() > 0 {
+		fmt.Println(pages.Render())
+	}
+}
@@ -6,6 +6,8 @@ import (
 
 	"github.com/jimyag/log"
 	"github.com/spf13/cobra"
+
+	"github.com/jimyag/parquet-tools/pkg/parquettools"
 )
 
 // metaCmd represents the meta command
@@ -20,14 +22,21 @@ func init() {
 }
 
 func footer(cmd *cobra.Command, args []string) {
+	args, err := expandInputs(args)
+	if err != nil {
+		log.Error().Msgf("error expanding inputs: %s", err)
+		return
+	}
 	rdrs, err := getReaders(args)
 	if err != nil {
+		// the other inputs are still printed
 		log.Error().Msgf("error getting readers: %s", err)
-		return
 	}
 	for _, rdr := range rdrs {
-		fileMetadata := rdr.MetaData()
-		m, err := json.MarshalIndent(fileMetadata, "", "  ")
+		if rdr == nil {
+			continue
+		}
+		m, err := json.MarshalIndent(parquettools.InspectFooter(rdr.Reader), "", "  ")
 		if err != nil {
 			log.Error().Msgf("error marshalling file metadata: %s", err)
 			return
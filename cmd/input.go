@@ -0,0 +1,16 @@
+package cmd
+
+import (
+	"github.com/jimyag/parquet-tools/pkg/parquettools"
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringSliceVarP(&opts.Include, "include", "", nil, "only read expanded files matching these glob patterns")
+	rootCmd.PersistentFlags().StringSliceVarP(&opts.Exclude, "exclude", "", nil, "skip expanded files matching these glob patterns")
+}
+
+// expandInputs replaces directories, globs and s3 prefixes with the files
+// they contain, see parquettools.Expand.
+func expandInputs(args []string) ([]string, error) {
+	return parquettools.Expand(ctx, args, opts)
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/jimyag/log"
+
+	"github.com/jimyag/parquet-tools/pkg/parquettools"
+)
+
+var (
+	ioStats   bool
+	traceFile string
+)
+
+func init() {
+	rootCmd.PersistentFlags().BoolVarP(&ioStats, "io-stats", "", false, "print the reads of every input on stderr")
+	rootCmd.PersistentFlags().StringVarP(&traceFile, "trace", "", "", "write the reads of every input to this file as a Chrome trace")
+}
+
+// setupIOStats records the reads of the inputs when --io-stats or --trace
+// is set.
+func setupIOStats() {
+	if ioStats || traceFile != "" {
+		opts.IOStats = parquettools.NewIOStats()
+	}
+}
+
+// writeIOStats prints the summary and writes the trace after the command
+// ran.
+func writeIOStats() {
+	if opts.IOStats == nil {
+		return
+	}
+	if ioStats {
+		if err := opts.IOStats.WriteSummary(os.Stderr); err != nil {
+			log.Warn(err).Msg("error writing io stats")
+		}
+	}
+	if traceFile == "" {
+		return
+	}
+	f, err := os.Create(traceFile)
+	if err != nil {
+		log.Error(err).Msg("error creating trace file")
+		return
+	}
+	defer f.Close()
+	if err := opts.IOStats.WriteTrace(f); err != nil {
+		log.Error(err).Msg("error writing trace file")
+	}
+}
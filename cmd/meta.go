@@ -5,12 +5,14 @@ package cmd
 
 import (
 	"fmt"
+	"slices"
 
-	"github.com/apache/arrow/go/v17/parquet/metadata"
 	"github.com/jedib0t/go-pretty/v6/table"
 	"github.com/jedib0t/go-pretty/v6/text"
 	"github.com/jimyag/log"
 	"github.com/spf13/cobra"
+
+	"github.com/jimyag/parquet-tools/pkg/parquettools"
 )
 
 // metaCmd represents the meta command
@@ -21,17 +23,35 @@ var metaCmd = &cobra.Command{
 }
 
 func init() {
+	addColumnsFlag(metaCmd)
 	rootCmd.AddCommand(metaCmd)
 }
 
 func meta(cmd *cobra.Command, args []string) {
+	args, err := expandInputs(args)
+	if err != nil {
+		log.Error(err).Msg("error expanding inputs")
+		return
+	}
 	rdrs, err := getReaders(args)
 	if err != nil {
+		// the other inputs are still printed
 		log.Error(err).Msg("error getting readers")
-		return
 	}
 	for i, rdr := range rdrs {
-		fileMetadata := rdr.MetaData()
+		if rdr == nil {
+			continue
+		}
+		m, err := parquettools.InspectMeta(rdr.Reader)
+		if err != nil {
+			log.Error(err).Msg("error getting metadata")
+			continue
+		}
+		cols, err := selectColumns(rdr)
+		if err != nil {
+			log.Error(err).Msg("error selecting columns")
+			continue
+		}
 		t := table.NewWriter()
 		t.Style().Options.DrawBorder = true
 		t.Style().Options.SeparateRows = true
@@ -44,26 +64,23 @@ func meta(cmd *cobra.Command, args []string) {
 		t.AppendHeader(table.Row{"key", "value"}, table.RowConfig{AutoMergeAlign: text.AlignCenter})
 
 		t.AppendRow(table.Row{"filename", args[i]})
-		t.AppendRow(table.Row{"version", fileMetadata.Version()})
-		t.AppendRow(table.Row{"created by", fileMetadata.GetCreatedBy()})
-		t.AppendRow(table.Row{"num rows", rdr.NumRows()})
-		kvMeta := fileMetadata.KeyValueMetadata()
-		if kvMeta != nil {
-			if kvMeta.Len() > 0 {
-				t.AppendRow(table.Row{"key value file metadata", kvMeta.Len()})
+		t.AppendRow(table.Row{"version", m.Version})
+		t.AppendRow(table.Row{"created by", m.CreatedBy})
+		t.AppendRow(table.Row{"num rows", m.NumRows})
+		if m.HasKeyValue {
+			if len(m.KeyValue) > 0 {
+				t.AppendRow(table.Row{"key value file metadata", len(m.KeyValue)})
 			}
-			keys := kvMeta.Keys()
-			values := kvMeta.Values()
-			for i := 0; i < kvMeta.Len(); i++ {
-				t.AppendRow(table.Row{keys[i], values[i]})
+			for _, kv := range m.KeyValue {
+				t.AppendRow(table.Row{kv.Key, kv.Value})
 			}
-			t.AppendRow(table.Row{"number of row groups", rdr.NumRowGroups()})
-			t.AppendRow(table.Row{"number of real columns", fileMetadata.Schema.Root().NumFields()})
-			t.AppendRow(table.Row{"number of columns", fileMetadata.Schema.NumColumns()})
+			t.AppendRow(table.Row{"number of row groups", m.NumRowGroups})
+			t.AppendRow(table.Row{"number of real columns", m.NumRealColumns})
+			t.AppendRow(table.Row{"number of columns", m.NumColumns})
 		}
 		fmt.Println(t.Render())
 		fmt.Println()
-		for r := 0; r < rdr.NumRowGroups(); r++ {
+		for r, rg := range m.RowGroups {
 			fmt.Println("--- row group: ", r, " begin ---")
 			t := table.NewWriter()
 			t.Style().Options.DrawBorder = true
@@ -71,10 +88,8 @@ func meta(cmd *cobra.Command, args []string) {
 			tTemp := table.Table{}
 			tTemp.Render()
 
-			rgr := rdr.RowGroup(r)
-			rowGroupMeta := rgr.MetaData()
-			t.AppendRow(table.Row{"total bytes", rowGroupMeta.TotalByteSize()})
-			t.AppendRow(table.Row{"number of rows", rgr.NumRows()})
+			t.AppendRow(table.Row{"total bytes", rg.TotalByteSize})
+			t.AppendRow(table.Row{"number of rows", rg.NumRows})
 			fmt.Println(t.Render())
 
 			newT := table.NewWriter()
@@ -95,66 +110,43 @@ func meta(cmd *cobra.Command, args []string) {
 				{Name: "compressed size", WidthMax: 5, Align: text.AlignCenter},
 			})
 			newT.AppendHeader(table.Row{"column", "counts", "min", "max", "nulls", "distinct", "compression", "encodings", "uncompressed", "compressed"})
-			for c := range fileMetadata.Schema.NumColumns() {
-				row := table.Row{}
-				chunkMeta, err := rowGroupMeta.ColumnChunk(c)
-				descRecord := fileMetadata.Schema.Column(c)
-				row = append(row, descRecord.Name())
-				if err != nil {
-					log.Error(err).Msg("error getting column chunk metadata")
-					return
+			for _, col := range rg.Columns {
+				if cols != nil && !slices.Contains(cols, col.Column) {
+					continue
 				}
-				if set, _ := chunkMeta.StatsSet(); set {
-					stats, err := chunkMeta.Statistics()
-					if err != nil {
-						log.Error(err).Msg("error getting column chunk statistics")
-						return
-					}
-					row = append(row, fmt.Sprint(chunkMeta.NumValues()))
-					if stats.HasMinMax() {
-						minV := metadata.GetStatValue(stats.Type(), stats.EncodeMin())
-						if v, ok := minV.([]byte); ok {
-							row = append(row, fmt.Sprint(string(v)))
-						} else {
-							row = append(row, fmt.Sprint(minV))
-						}
-
-						maxV := metadata.GetStatValue(stats.Type(), stats.EncodeMax())
-						if v, ok := maxV.([]byte); ok {
-							row = append(row, fmt.Sprint(string(v)))
-						} else {
-							row = append(row, fmt.Sprint(maxV))
-						}
+				row := table.Row{col.Name, fmt.Sprint(col.NumValues)}
+				if col.HasStats {
+					if col.HasMinMax {
+						row = append(row, fmt.Sprint(col.Min), fmt.Sprint(col.Max))
 					} else {
 						row = append(row, "-", "-")
 					}
-					if stats.HasNullCount() {
-						row = append(row, fmt.Sprint(stats.NullCount()))
-					} else {
-						row = append(row, "-")
-					}
-					if stats.HasDistinctCount() {
-						row = append(row, fmt.Sprint(stats.DistinctCount()))
-					} else {
-						row = append(row, "-")
-					}
+					row = append(row, optionalCount(col.NullCount), optionalCount(col.DistinctCount))
 				} else {
-					row = append(row, fmt.Sprint(chunkMeta.NumValues()), "-", "-", "-", "-")
+					row = append(row, "-", "-", "-", "-")
 				}
-				row = append(row, fmt.Sprint(chunkMeta.Compression()))
+				row = append(row, fmt.Sprint(col.Compression))
 				encodings := ""
-				for _, enc := range chunkMeta.Encodings() {
+				for _, enc := range col.Encodings {
 					encodings += fmt.Sprint(enc) + " "
 				}
 				row = append(row, encodings)
-				row = append(row, fmt.Sprint(chunkMeta.TotalUncompressedSize()))
-				row = append(row, fmt.Sprint(chunkMeta.TotalCompressedSize()))
+				row = append(row, fmt.Sprint(col.UncompressedSize))
+				row = append(row, fmt.Sprint(col.CompressedSize))
 				newT.AppendRow(row)
 			}
 			fmt.Println(newT.Render())
 			fmt.Println("--- row group: ", r, " end ---")
 			fmt.Println()
 		}
-		fmt.Println(fileMetadata.Schema.String())
+		fmt.Println(m.Schema)
+	}
+}
+
+// optionalCount renders a statistic the writer may have left out.
+func optionalCount(n *int64) string {
+	if n == nil {
+		return "-"
 	}
+	return fmt.Sprint(*n)
 }
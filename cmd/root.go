@@ -5,22 +5,16 @@ package cmd
 
 import (
 	"context"
-	"fmt"
-	"net/url"
 	"os"
+	"os/signal"
 	"path/filepath"
-	"slices"
-
-	"github.com/BurntSushi/toml"
-	"github.com/apache/arrow/go/v17/parquet/file"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/s3"
+	"runtime"
+	"time"
+
 	"github.com/jimyag/log"
 	"github.com/spf13/cobra"
 
-	"github.com/jimyag/parquet-tools/internal/reader"
+	"github.com/jimyag/parquet-tools/pkg/parquettools"
 )
 
 var rootCmd = &cobra.Command{
@@ -29,11 +23,8 @@ var rootCmd = &cobra.Command{
 }
 
 const (
-	s3Scheme    = "s3"
-	s3aScheme   = "s3a"
-	localScheme = "file"
-	httpScheme  = "http"
-	httpsScheme = "https"
+	s3Scheme  = "s3"
+	s3aScheme = "s3a"
 
 	s3ConfigFileUsage = `
 # BEGIN S3 CONFIG -----
@@ -46,150 +37,201 @@ disable_ssl = true
 force_path_style = true
 scopes = ["bucket1", "bucket2"]
 [[s3]]
+name = "minio2"
 endpoint = "http://127.0.0.1:9000"
 region = "us-east-1"
 access_key = "ak2"
 secret_key = "sk2"
 disable_ssl = true
 force_path_style = true
-scopes = ["bucket3", "bucket4"]
+scopes = ["bucket3", "bucket4", "logs-*"]
+# credential_source is one of static (default), session_token, env,
+# profile, assume_role, credential_process or default (the AWS chain)
+# [[s3]]
+# credential_source = "assume_role"
+# profile = "dev"
+# role_arn = "arn:aws:iam::123456789012:role/reader"
+# external_id = "id"
+# scopes = ["bucket5"]
+# SSE-C encrypted objects need the key, as sse_customer_key (base64),
+# sse_customer_key_file or sse_customer_key_env
+# [[s3]]
+# endpoint = "https://minio.example.com"
+# sse_customer_key_file = "/path/to/sse-c.key"
+# requester_pays = true
+# scopes = ["bucket6"]
 # END S3 CONFIG -----
+
+# BEGIN GCS CONFIG -----
+# [[gcs]]
+# endpoint = "http://127.0.0.1:4443"
+# credentials_file = "/path/to/service-account.json"
+# scopes = ["bucket5"]
+# END GCS CONFIG -----
+
+# BEGIN AZURE CONFIG -----
+# [[azure]]
+# account = "devstoreaccount1"
+# account_key = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+# endpoint = "http://127.0.0.1:10000/devstoreaccount1"
+# scopes = ["container1"]
+# [[azure]]
+# account = "account2"
+# sas_token = "sv=...&sig=..."
+# END AZURE CONFIG -----
+
+# BEGIN WEBHDFS CONFIG -----
+# [[webhdfs]]
+# user = "hdfs"
+# scopes = ["namenode:9870"]
+# END WEBHDFS CONFIG -----
+
+# BEGIN TRANSPORT CONFIG -----
+# [transport]
+# ca_bundle = "/etc/ssl/private-ca.pem"
+# client_cert = "/path/to/client.pem"
+# client_key = "/path/to/client-key.pem"
+# insecure_skip_verify = false
+# https_proxy = "http://proxy:3128"
+# no_proxy = "localhost,.internal"
+# [[http]]
+# hosts = ["artifacts.example.com", "*.internal"]
+# headers = { "X-Team" = "data" }
+# bearer_token_env = "ARTIFACTS_TOKEN"
+# END TRANSPORT CONFIG -----
 `
 )
 
 var s3ConfigFile string = ".parquet-tools/s3.toml"
 
+// opts are filled from the flags and the config file before a command
+// runs.
+var opts = parquettools.DefaultOptions
+
+var (
+	retryOpts = parquettools.DefaultRetryOptions
+	// timeout bounds the whole command, 0 means no limit.
+	timeout time.Duration
+	// ctx is canceled on Ctrl-C and when the timeout expires, which aborts
+	// the in-flight requests of all remote readers.
+	ctx           = context.Background()
+	cancelTimeout = func() {}
+)
+
+var (
+	// the cache is opt-in: it reads whole blocks, more than the ranges
+	// of a pruned or projected scan
+	cacheOpts = parquettools.CacheOptions{
+		BlockSize: parquettools.DefaultCacheBlockSize,
+		DiskSize:  1 << 30,
+	}
+	// diskCacheMemory is the memory tier of --disk-cache without
+	// --cache-size.
+	diskCacheMemory int64 = 64 << 20
+	cacheDir              = ".parquet-tools/cache"
+	diskCache       bool
+	clearCache      bool
+)
+
+// opened are the readers returned by getReaders, closed after the command
+// ran, which removes the temporary files of spooled input.
+var opened []*parquettools.File
+
 func init() {
 	home, err := os.UserHomeDir()
 	if err != nil {
 		home = "./"
 	}
 	s3ConfigFile = filepath.Join(home, s3ConfigFile)
+	cacheDir = filepath.Join(home, cacheDir)
 	if _, err := os.Stat(s3ConfigFile); os.IsNotExist(err) {
 		os.MkdirAll(filepath.Dir(s3ConfigFile), 0700)
 		os.WriteFile(s3ConfigFile, []byte(s3ConfigFileUsage), 0600)
 	}
+	opts.Jobs = runtime.NumCPU()
 	rootCmd.PersistentFlags().StringVarP(&s3ConfigFile, "s3-config", "", s3ConfigFile, "s3 config file")
+	rootCmd.PersistentFlags().Int64VarP(&opts.Prefetch.MaxGap, "prefetch-gap", "", opts.Prefetch.MaxGap, "merge remote column chunk reads separated by at most this many bytes")
+	rootCmd.PersistentFlags().IntVarP(&opts.Prefetch.Workers, "prefetch-workers", "", opts.Prefetch.Workers, "number of concurrent remote prefetch requests")
+	rootCmd.PersistentFlags().Int64VarP(&cacheOpts.MemorySize, "cache-size", "", cacheOpts.MemorySize, "cache remote file blocks in memory, up to this many bytes, 0 disables the cache")
+	rootCmd.PersistentFlags().BoolVarP(&diskCache, "disk-cache", "", false, "also cache remote file blocks on disk in "+cacheDir)
+	rootCmd.PersistentFlags().Int64VarP(&cacheOpts.DiskSize, "disk-cache-size", "", cacheOpts.DiskSize, "bytes of remote file blocks kept on disk")
+	rootCmd.PersistentFlags().BoolVarP(&clearCache, "clear-cache", "", false, "remove the on-disk cache before running")
+	rootCmd.PersistentFlags().Int64VarP(&opts.SpoolMemory, "spool-memory", "", opts.SpoolMemory, "bytes of stdin or pipe input kept in memory before spilling to a temporary file")
+	rootCmd.PersistentFlags().Int64VarP(&opts.SpoolMaxSize, "spool-max-size", "", 0, "largest stdin or pipe input accepted in bytes, 0 means no limit")
+	rootCmd.PersistentFlags().BoolVarP(&opts.Mmap, "mmap", "", false, "memory-map local files")
+	rootCmd.PersistentFlags().IntVarP(&opts.Jobs, "jobs", "j", opts.Jobs, "number of inputs opened concurrently")
+	rootCmd.PersistentFlags().DurationVarP(&timeout, "timeout", "", 0, "abort the command after this long, 0 means no limit")
+	rootCmd.PersistentFlags().DurationVarP(&retryOpts.RequestTimeout, "request-timeout", "", retryOpts.RequestTimeout, "abort a single remote request after this long and retry it, 0 means no limit")
+	rootCmd.PersistentFlags().IntVarP(&retryOpts.MaxAttempts, "retries", "", retryOpts.MaxAttempts, "number of attempts per remote request")
+	rootCmd.PersistentFlags().Int64VarP(&retryOpts.Budget, "retry-budget", "", retryOpts.Budget, "number of retries shared by all remote requests")
+	rootCmd.PersistentPostRun = func(cmd *cobra.Command, args []string) {
+		cancelTimeout()
+		for _, rdr := range opened {
+			if err := rdr.Close(); err != nil {
+				log.Warn(err).Msg("error closing input")
+			}
+		}
+		writeIOStats()
+	}
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		ctx = cmd.Context()
+		if timeout > 0 {
+			ctx, cancelTimeout = context.WithTimeout(ctx, timeout)
+		}
+		cfg, err := parquettools.LoadConfig(s3ConfigFile)
+		if err != nil {
+			return err
+		}
+		opts.Config = cfg
+		opts.ConfigFile = s3ConfigFile
+		parquettools.SetRetryOptions(retryOpts)
+		setupIOStats()
+		if err := setupHTTPClient(cfg, args); err != nil {
+			return err
+		}
+		if clearCache {
+			if err := parquettools.ClearCacheDir(cacheDir); err != nil {
+				return err
+			}
+		}
+		if cacheOpts.MemorySize <= 0 && !diskCache {
+			return nil
+		}
+		if diskCache {
+			cacheOpts.Dir = cacheDir
+			if !cmd.Flag("cache-size").Changed {
+				cacheOpts.MemorySize = diskCacheMemory
+			}
+		}
+		opts.Cache = parquettools.NewBlockCache(cacheOpts)
+		if err := opts.Cache.Trim(); err != nil {
+			log.Warn(err).Msg("error trimming disk cache")
+		}
+		return nil
+	}
 }
 
 func Execute() {
-	err := rootCmd.Execute()
+	interrupted, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	go func() {
+		// a second Ctrl-C kills the process
+		<-interrupted.Done()
+		stop()
+	}()
+	err := rootCmd.ExecuteContext(interrupted)
+	stop()
 	if err != nil {
 		os.Exit(1)
 	}
 }
 
-type Config struct {
-	S3 []s3Cfg `toml:"s3" json:"s3"`
-}
-
-type s3Cfg struct {
-	Region         string   `toml:"region" json:"region"`
-	AccessKey      string   `toml:"access_key" json:"access_key"`
-	SecretKey      string   `toml:"secret_key" json:"secret_key"`
-	DisableSSL     bool     `toml:"disable_ssl" json:"disable_ssl"`
-	ForcePathStyle bool     `toml:"force_path_style" json:"force_path_style"`
-	EndPoint       string   `toml:"endpoint" json:"endpoint"`
-	Scopes         []string `toml:"scopes" json:"scopes"`
-}
-
-func getReaders(filenames []string) ([]*file.Reader, error) {
-	readers := make([]*file.Reader, len(filenames))
-	for i, filename := range filenames {
-		u, err := url.Parse(filename)
-		if err != nil {
-			return nil, err
-		}
-		if u.Scheme == "" || u.Scheme == localScheme {
-			rdr, err := file.OpenParquetFile(filename, false)
-			if err != nil {
-				return nil, err
-			}
-			readers[i] = rdr
-			continue
-		}
-
-		if u.Scheme == httpScheme || u.Scheme == httpsScheme {
-			httpReader, err := reader.NewHttpReader(filename)
-			if err != nil {
-				return nil, err
-			}
-			rdr, err := file.NewParquetReader(httpReader)
-			if err != nil {
-				return nil, err
-			}
-			readers[i] = rdr
-			continue
-		}
-		if u.Scheme == s3Scheme || u.Scheme == s3aScheme {
-			cfg := Config{}
-			if _, err := toml.DecodeFile(s3ConfigFile, &cfg); err != nil {
-				return nil, err
-			}
-			for ic, c := range cfg.S3 {
-				mySession := session.Must(session.NewSession(&aws.Config{
-					Credentials:      credentials.NewStaticCredentials(c.AccessKey, c.SecretKey, ""),
-					Endpoint:         aws.String(c.EndPoint),
-					Region:           aws.String(c.Region),
-					DisableSSL:       aws.Bool(c.DisableSSL),
-					S3ForcePathStyle: aws.Bool(c.ForcePathStyle),
-				}))
-				bucket, _, err := reader.ParsePath(filename)
-				if err != nil {
-					return nil, err
-				}
-				s3Cli := s3.New(mySession)
-				_, err = reader.Stat(context.Background(), filename, s3Cli)
-				if err == nil {
-					s3Reader, err := reader.NewS3Reader(context.Background(), filename, s3Cli)
-					if err != nil {
-						return nil, err
-					}
-					rdr, err := file.NewParquetReader(s3Reader)
-					if err != nil {
-						return nil, err
-					}
-					readers[i] = rdr
-					if c.Scopes == nil {
-						c.Scopes = []string{}
-					}
-					if slices.Contains(c.Scopes, bucket) {
-						continue
-					}
-					c.Scopes = append(c.Scopes, bucket)
-					cfg.S3[ic] = c
-					// update config file
-					f, err := os.OpenFile(s3ConfigFile, os.O_WRONLY, 0600)
-					if err != nil {
-						log.Error().Msgf("error opening s3 config file: %s", err)
-						return nil, err
-					}
-					if err := toml.NewEncoder(f).Encode(cfg); err != nil {
-						log.Error().Msgf("error encoding s3 config file: %s", err)
-						return nil, err
-					}
-					f.Close()
-					break
-				}
-				for _, scope := range c.Scopes {
-					if scope == bucket {
-						s3Reader, err := reader.NewS3Reader(context.Background(), filename, s3Cli)
-						if err != nil {
-							return nil, err
-						}
-						rdr, err := file.NewParquetReader(s3Reader)
-						if err != nil {
-							return nil, err
-						}
-						readers[i] = rdr
-						break
-					}
-				}
-			}
-			if readers[i] == nil {
-				return nil, fmt.Errorf("don't have access to %s", filename)
-			}
+// getReaders opens the inputs concurrently, see parquettools.OpenAll.
+func getReaders(filenames []string) ([]*parquettools.File, error) {
+	readers, err := parquettools.OpenAll(ctx, filenames, opts)
+	for _, rdr := range readers {
+		if rdr != nil {
+			opened = append(opened, rdr)
 		}
 	}
-	return readers, nil
+	return readers, err
 }
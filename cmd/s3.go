@@ -0,0 +1,7 @@
+package cmd
+
+func init() {
+	rootCmd.PersistentFlags().StringVarP(&opts.S3Profile, "s3-profile", "", "", "name of the [[s3]] config entry used for every s3 url")
+	rootCmd.PersistentFlags().BoolVarP(&opts.S3Probe, "s3-probe", "", false, "try every [[s3]] config entry when no scope matches the bucket")
+	rootCmd.PersistentFlags().BoolVarP(&opts.S3LearnScopes, "s3-learn-scopes", "", false, "with --s3-probe, add the bucket to the scopes of the entry that worked")
+}
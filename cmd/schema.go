@@ -1,11 +1,12 @@
 package cmd
 
 import (
-	"os"
+	"fmt"
 
-	"github.com/apache/arrow/go/v17/parquet/schema"
 	"github.com/jimyag/log"
 	"github.com/spf13/cobra"
+
+	"github.com/jimyag/parquet-tools/pkg/parquettools"
 )
 
 var schemaCmd = &cobra.Command{
@@ -19,12 +20,20 @@ func init() {
 }
 
 func schemaRun(cmd *cobra.Command, args []string) {
+	args, err := expandInputs(args)
+	if err != nil {
+		log.Error(err).Msg("error expanding inputs")
+		return
+	}
 	rdrs, err := getReaders(args)
 	if err != nil {
+		// the other inputs are still printed
 		log.Error(err).Msg("error getting readers")
-		return
 	}
 	for _, rdr := range rdrs {
-		schema.PrintSchema(rdr.MetaData().Schema.Root(), os.Stdout, 2)
+		if rdr == nil {
+			continue
+		}
+		fmt.Print(parquettools.InspectSchema(rdr.Reader).Text)
 	}
 }
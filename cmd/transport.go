@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/jimyag/log"
+
+	"github.com/jimyag/parquet-tools/pkg/parquettools"
+)
+
+var (
+	transportFlags parquettools.TransportConfig
+	proxyFlag      string
+	headerFlags    []string
+	bearerToken    string
+	basicAuth      string
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringVarP(&transportFlags.CABundle, "ca-bundle", "", "", "PEM file with additional trusted CA certificates")
+	rootCmd.PersistentFlags().StringVarP(&transportFlags.ClientCert, "client-cert", "", "", "PEM file with the TLS client certificate")
+	rootCmd.PersistentFlags().StringVarP(&transportFlags.ClientKey, "client-key", "", "", "PEM file with the TLS client key")
+	rootCmd.PersistentFlags().BoolVarP(&transportFlags.InsecureSkipVerify, "insecure-skip-verify", "", false, "don't verify TLS server certificates")
+	rootCmd.PersistentFlags().StringVarP(&proxyFlag, "proxy", "", "", "proxy for http and https requests")
+	rootCmd.PersistentFlags().StringVarP(&transportFlags.NoProxy, "no-proxy", "", "", "comma separated hosts reached without proxy")
+	rootCmd.PersistentFlags().StringArrayVarP(&headerFlags, "header", "H", nil, `extra "Name: value" header for the http(s) inputs`)
+	rootCmd.PersistentFlags().StringVarP(&bearerToken, "bearer-token", "", "", "bearer token for the http(s) inputs")
+	rootCmd.PersistentFlags().StringVarP(&basicAuth, "basic-auth", "", "", `"user:password" for the http(s) inputs`)
+}
+
+// setupHTTPClient builds the shared client from the [transport] and
+// [[http]] config sections and the flags, which take precedence. The
+// header and authorization flags only apply to the hosts of the http(s)
+// inputs in args, never to other remote backends sharing the client.
+func setupHTTPClient(cfg parquettools.Config, args []string) error {
+	t := &cfg.Transport
+	for _, f := range []struct{ flag, cfg *string }{
+		{&transportFlags.CABundle, &t.CABundle},
+		{&transportFlags.ClientCert, &t.ClientCert},
+		{&transportFlags.ClientKey, &t.ClientKey},
+		{&transportFlags.NoProxy, &t.NoProxy},
+		{&proxyFlag, &t.HTTPProxy},
+		{&proxyFlag, &t.HTTPSProxy},
+	} {
+		if *f.flag != "" {
+			*f.cfg = *f.flag
+		}
+	}
+	t.InsecureSkipVerify = t.InsecureSkipVerify || transportFlags.InsecureSkipVerify
+
+	// the flags come first, earlier entries win
+	if len(headerFlags) > 0 || bearerToken != "" || basicAuth != "" {
+		h := parquettools.HTTPConfig{Hosts: inputHosts(args), Headers: map[string]string{}, BearerToken: bearerToken}
+		if len(h.Hosts) == 0 {
+			log.Warn().Msg("--header, --bearer-token and --basic-auth only apply to http(s) inputs, there are none")
+		}
+		for _, hf := range headerFlags {
+			name, value, ok := strings.Cut(hf, ":")
+			if !ok {
+				return fmt.Errorf(`invalid header %q, want "Name: value"`, hf)
+			}
+			name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+			if prev, ok := h.Headers[name]; ok {
+				value = prev + ", " + value
+			}
+			h.Headers[name] = value
+		}
+		if basicAuth != "" {
+			h.Username, h.Password, _ = strings.Cut(basicAuth, ":")
+		}
+		cfg.HTTP = append([]parquettools.HTTPConfig{h}, cfg.HTTP...)
+	}
+
+	client, err := parquettools.NewHTTPClient(cfg)
+	if err != nil {
+		return err
+	}
+	parquettools.SetHTTPClient(client)
+	return nil
+}
+
+// inputHosts returns the hosts of the http(s) URLs in args as patterns
+// matching only themselves.
+func inputHosts(args []string) []string {
+	var hosts []string
+	for _, arg := range args {
+		u, err := url.Parse(arg)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			continue
+		}
+		host := patternEscaper.Replace(u.Host)
+		if !slices.Contains(hosts, host) {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// patternEscaper quotes the path.Match metacharacters, such as the
+// brackets of an IPv6 host.
+var patternEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`)
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jimyag/log"
+	"github.com/spf13/cobra"
+
+	"github.com/jimyag/parquet-tools/pkg/parquettools"
+)
+
+// versionsCmd represents the versions command
+var versionsCmd = &cobra.Command{
+	Use:   "versions",
+	Short: "list the versions of a Parquet file in a versioned s3 bucket",
+	Long: `list the versions of a Parquet file in a versioned s3 bucket with their
+size and row count. A version is read with s3://bucket/key?versionId=ID.`,
+	Run: versions,
+}
+
+func init() {
+	rootCmd.AddCommand(versionsCmd)
+}
+
+func versions(cmd *cobra.Command, args []string) {
+	for _, arg := range args {
+		u, err := url.Parse(arg)
+		if err != nil {
+			log.Error(err).Msg("error parsing url")
+			return
+		}
+		if u.Scheme != s3Scheme && u.Scheme != s3aScheme {
+			log.Error().Msgf("versions only supports s3 urls: %s", arg)
+			return
+		}
+		vs, err := parquettools.ListVersions(ctx, arg, opts)
+		if err != nil {
+			log.Error(err).Msgf("error listing versions of %s", arg)
+			return
+		}
+		if len(vs) == 0 {
+			log.Error().Msgf("no versions found for %s", arg)
+			return
+		}
+
+		t := table.NewWriter()
+		t.Style().Options.DrawBorder = true
+		t.AppendHeader(table.Row{"version id", "last modified", "size", "num rows", "latest"})
+		for _, v := range vs {
+			if v.DeleteMarker {
+				t.AppendRow(table.Row{v.VersionID, v.ModTime, "(delete marker)", "", v.IsLatest})
+				continue
+			}
+			numRows := "-"
+			versionURL := *u
+			versionURL.RawQuery = url.Values{"versionId": {v.VersionID}}.Encode()
+			if rdrs, err := getReaders([]string{versionURL.String()}); err != nil {
+				log.Warn(err).Msgf("error reading version %s", v.VersionID)
+			} else {
+				numRows = fmt.Sprint(rdrs[0].NumRows())
+			}
+			t.AppendRow(table.Row{v.VersionID, v.ModTime, v.Size, numRows, v.IsLatest})
+		}
+		fmt.Println(arg)
+		fmt.Println(t.Render())
+	}
+}
@@ -0,0 +1,341 @@
+package dumper
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errBSON = errors.New("invalid BSON document")
+
+// bsonToJSON converts a BSON document to MongoDB relaxed Extended JSON:
+// strings, booleans, null, documents, arrays, int32, int64 and finite
+// doubles as plain JSON and the other types as their "$" wrappers, such as
+// {"$oid": "..."}, {"$date": "..."} or {"$binary": {...}}.
+func bsonToJSON(b []byte) (json.RawMessage, error) {
+	d := &bsonDecoder{b: b}
+	var out strings.Builder
+	if err := d.document(&out, false); err != nil {
+		return nil, err
+	}
+	if d.off != len(b) {
+		return nil, errBSON
+	}
+	return json.RawMessage(out.String()), nil
+}
+
+type bsonDecoder struct {
+	b   []byte
+	off int
+}
+
+func (d *bsonDecoder) take(n int) ([]byte, error) {
+	if n < 0 || len(d.b)-d.off < n {
+		return nil, errBSON
+	}
+	b := d.b[d.off : d.off+n]
+	d.off += n
+	return b, nil
+}
+
+func (d *bsonDecoder) int32() (int32, error) {
+	b, err := d.take(4)
+	if err != nil {
+		return 0, err
+	}
+	return int32(binary.LittleEndian.Uint32(b)), nil
+}
+
+func (d *bsonDecoder) uint64() (uint64, error) {
+	b, err := d.take(8)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(b), nil
+}
+
+func (d *bsonDecoder) cstring() (string, error) {
+	for i := d.off; i < len(d.b); i++ {
+		if d.b[i] == 0 {
+			s := string(d.b[d.off:i])
+			d.off = i + 1
+			return s, nil
+		}
+	}
+	return "", errBSON
+}
+
+// string reads a length-prefixed string with its trailing zero.
+func (d *bsonDecoder) string() (string, error) {
+	n, err := d.int32()
+	if err != nil {
+		return "", err
+	}
+	b, err := d.take(int(n))
+	if err != nil || n < 1 || b[n-1] != 0 {
+		return "", errBSON
+	}
+	return string(b[:n-1]), nil
+}
+
+// document writes a document as a JSON object, or an array as a JSON
+// array.
+func (d *bsonDecoder) document(out *strings.Builder, array bool) error {
+	start := d.off
+	n, err := d.int32()
+	if err != nil {
+		return err
+	}
+	end := start + int(n)
+	if n < 5 || end > len(d.b) || d.b[end-1] != 0 {
+		return errBSON
+	}
+	open, close := "{", "}"
+	if array {
+		open, close = "[", "]"
+	}
+	out.WriteString(open)
+	for i := 0; d.off < end-1; i++ {
+		typ := d.b[d.off]
+		d.off++
+		name, err := d.cstring()
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			out.WriteString(",")
+		}
+		if !array {
+			writeJSONString(out, name)
+			out.WriteString(":")
+		}
+		if err := d.element(out, typ); err != nil {
+			return err
+		}
+	}
+	if d.off != end-1 {
+		return errBSON
+	}
+	d.off = end
+	out.WriteString(close)
+	return nil
+}
+
+func (d *bsonDecoder) element(out *strings.Builder, typ byte) error {
+	switch typ {
+	case 0x01:
+		bits, err := d.uint64()
+		if err != nil {
+			return err
+		}
+		f := math.Float64frombits(bits)
+		switch {
+		case math.IsNaN(f):
+			out.WriteString(`{"$numberDouble":"NaN"}`)
+		case math.IsInf(f, 1):
+			out.WriteString(`{"$numberDouble":"Infinity"}`)
+		case math.IsInf(f, -1):
+			out.WriteString(`{"$numberDouble":"-Infinity"}`)
+		default:
+			out.WriteString(strconv.FormatFloat(f, 'g', -1, 64))
+		}
+	case 0x02:
+		s, err := d.string()
+		if err != nil {
+			return err
+		}
+		writeJSONString(out, s)
+	case 0x03, 0x04:
+		return d.document(out, typ == 0x04)
+	case 0x05:
+		n, err := d.int32()
+		if err != nil {
+			return err
+		}
+		sub, err := d.take(1)
+		if err != nil {
+			return err
+		}
+		data, err := d.take(int(n))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, `{"$binary":{"base64":"%s","subType":"%02x"}}`, base64.StdEncoding.EncodeToString(data), sub[0])
+	case 0x06:
+		out.WriteString(`{"$undefined":true}`)
+	case 0x07:
+		id, err := d.take(12)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, `{"$oid":"%s"}`, hex.EncodeToString(id))
+	case 0x08:
+		b, err := d.take(1)
+		if err != nil {
+			return err
+		}
+		out.WriteString(strconv.FormatBool(b[0] != 0))
+	case 0x09:
+		ms, err := d.uint64()
+		if err != nil {
+			return err
+		}
+		t := time.UnixMilli(int64(ms)).UTC()
+		if t.Year() < 1970 || t.Year() > 9999 {
+			fmt.Fprintf(out, `{"$date":{"$numberLong":"%d"}}`, int64(ms))
+		} else {
+			fmt.Fprintf(out, `{"$date":"%s"}`, t.Format("2006-01-02T15:04:05.999Z07:00"))
+		}
+	case 0x0A:
+		out.WriteString("null")
+	case 0x0B:
+		pattern, err := d.cstring()
+		if err != nil {
+			return err
+		}
+		options, err := d.cstring()
+		if err != nil {
+			return err
+		}
+		out.WriteString(`{"$regularExpression":{"pattern":`)
+		writeJSONString(out, pattern)
+		out.WriteString(`,"options":`)
+		writeJSONString(out, options)
+		out.WriteString("}}")
+	case 0x0C:
+		ref, err := d.string()
+		if err != nil {
+			return err
+		}
+		id, err := d.take(12)
+		if err != nil {
+			return err
+		}
+		out.WriteString(`{"$dbPointer":{"$ref":`)
+		writeJSONString(out, ref)
+		fmt.Fprintf(out, `,"$id":{"$oid":"%s"}}}`, hex.EncodeToString(id))
+	case 0x0D, 0x0E:
+		s, err := d.string()
+		if err != nil {
+			return err
+		}
+		key := `{"$code":`
+		if typ == 0x0E {
+			key = `{"$symbol":`
+		}
+		out.WriteString(key)
+		writeJSONString(out, s)
+		out.WriteString("}")
+	case 0x0F:
+		start := d.off
+		n, err := d.int32()
+		if err != nil {
+			return err
+		}
+		code, err := d.string()
+		if err != nil {
+			return err
+		}
+		out.WriteString(`{"$code":`)
+		writeJSONString(out, code)
+		out.WriteString(`,"$scope":`)
+		if err := d.document(out, false); err != nil {
+			return err
+		}
+		if d.off != start+int(n) {
+			return errBSON
+		}
+		out.WriteString("}")
+	case 0x10:
+		v, err := d.int32()
+		if err != nil {
+			return err
+		}
+		out.WriteString(strconv.FormatInt(int64(v), 10))
+	case 0x11:
+		v, err := d.uint64()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, `{"$timestamp":{"t":%d,"i":%d}}`, v>>32, uint32(v))
+	case 0x12:
+		v, err := d.uint64()
+		if err != nil {
+			return err
+		}
+		out.WriteString(strconv.FormatInt(int64(v), 10))
+	case 0x13:
+		lo, err := d.uint64()
+		if err != nil {
+			return err
+		}
+		hi, err := d.uint64()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, `{"$numberDecimal":"%s"}`, formatDecimal128(hi, lo))
+	case 0x7F:
+		out.WriteString(`{"$maxKey":1}`)
+	case 0xFF:
+		out.WriteString(`{"$minKey":1}`)
+	default:
+		return errBSON
+	}
+	return nil
+}
+
+func writeJSONString(out *strings.Builder, s string) {
+	b, _ := json.Marshal(s)
+	out.Write(b)
+}
+
+// formatDecimal128 formats an IEEE 754-2008 decimal128 in binary integer
+// decimal encoding like the BSON specification: plain notation for
+// exponents up to 0 with an adjusted exponent from -6 on, scientific
+// notation otherwise.
+func formatDecimal128(hi, lo uint64) string {
+	sign := ""
+	if hi>>63 != 0 {
+		sign = "-"
+	}
+	var exp int64
+	coef := new(big.Int)
+	switch {
+	case (hi>>58)&0x1f == 0x1f:
+		return "NaN"
+	case (hi>>58)&0x1f == 0x1e:
+		return sign + "Infinity"
+	case (hi>>61)&0x3 == 0x3:
+		// the coefficient would exceed 34 digits, it is read as 0
+		exp = int64((hi>>47)&0x3fff) - 6176
+	default:
+		exp = int64((hi>>49)&0x3fff) - 6176
+		coef.SetUint64(hi & (1<<49 - 1))
+		coef.Lsh(coef, 64)
+		coef.Or(coef, new(big.Int).SetUint64(lo))
+	}
+	digits := coef.String()
+	adjusted := exp + int64(len(digits)) - 1
+	if exp <= 0 && adjusted >= -6 {
+		if exp == 0 {
+			return sign + digits
+		}
+		return sign + formatDecimal(coef, int32(-exp))
+	}
+	s := digits[:1]
+	if len(digits) > 1 {
+		s += "." + digits[1:]
+	}
+	if adjusted >= 0 {
+		return fmt.Sprintf("%s%sE+%d", sign, s, adjusted)
+	}
+	return fmt.Sprintf("%s%sE%d", sign, s, adjusted)
+}
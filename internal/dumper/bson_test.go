@@ -0,0 +1,106 @@
+package dumper
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+// bsonDoc builds a BSON document of the given elements, each a type byte,
+// a name and the encoded value.
+func bsonDoc(elems ...[]byte) []byte {
+	var body []byte
+	for _, e := range elems {
+		body = append(body, e...)
+	}
+	doc := binary.LittleEndian.AppendUint32(nil, uint32(len(body)+5))
+	return append(append(doc, body...), 0)
+}
+
+func bsonElem(typ byte, name string, value []byte) []byte {
+	return append(append(append([]byte{typ}, name...), 0), value...)
+}
+
+func bsonString(s string) []byte {
+	b := binary.LittleEndian.AppendUint32(nil, uint32(len(s)+1))
+	return append(append(b, s...), 0)
+}
+
+func le64(v uint64) []byte { return binary.LittleEndian.AppendUint64(nil, v) }
+func le32(v uint32) []byte { return binary.LittleEndian.AppendUint32(nil, v) }
+
+func TestBSONToJSON(t *testing.T) {
+	oid := []byte{0x65, 0x0a, 0x1b, 0x2c, 0x3d, 0x4e, 0x5f, 0x60, 0x71, 0x82, 0x93, 0xa4}
+	tests := []struct {
+		name string
+		doc  []byte
+		want string
+	}{
+		{"empty", bsonDoc(), `{}`},
+		{"scalars", bsonDoc(
+			bsonElem(0x02, "s", bsonString(`a"b`)),
+			bsonElem(0x10, "i", le32(uint32(0xffffffff))),
+			bsonElem(0x12, "l", le64(1<<40)),
+			bsonElem(0x01, "d", le64(math.Float64bits(1.5))),
+			bsonElem(0x08, "t", []byte{1}),
+			bsonElem(0x0A, "n", nil),
+		), `{"s":"a\"b","i":-1,"l":1099511627776,"d":1.5,"t":true,"n":null}`},
+		{"nested", bsonDoc(
+			bsonElem(0x03, "doc", bsonDoc(bsonElem(0x10, "x", le32(1)))),
+			bsonElem(0x04, "arr", bsonDoc(bsonElem(0x10, "0", le32(1)), bsonElem(0x02, "1", bsonString("b")))),
+		), `{"doc":{"x":1},"arr":[1,"b"]}`},
+		{"wrapped", bsonDoc(
+			bsonElem(0x07, "_id", oid),
+			bsonElem(0x09, "at", le64(1700000000123)),
+			bsonElem(0x09, "old", le64(math.MaxUint64)),
+			bsonElem(0x05, "bin", append(append(le32(2), 0x04), 0xde, 0xad)),
+			bsonElem(0x01, "inf", le64(math.Float64bits(math.Inf(-1)))),
+			bsonElem(0x11, "ts", le64(5<<32|7)),
+			bsonElem(0x0B, "re", []byte("^a\x00i\x00")),
+			bsonElem(0xFF, "min", nil),
+		), `{"_id":{"$oid":"650a1b2c3d4e5f60718293a4"},"at":{"$date":"2023-11-14T22:13:20.123Z"},` +
+			`"old":{"$date":{"$numberLong":"-1"}},"bin":{"$binary":{"base64":"3q0=","subType":"04"}},` +
+			`"inf":{"$numberDouble":"-Infinity"},"ts":{"$timestamp":{"t":5,"i":7}},` +
+			`"re":{"$regularExpression":{"pattern":"^a","options":"i"}},"min":{"$minKey":1}}`},
+		{"decimal128", bsonDoc(
+			// 1.5: coefficient 15, exponent -1
+			bsonElem(0x13, "a", append(le64(15), le64(uint64(6176-1)<<49)...)),
+			// -1E+3: coefficient 1, exponent 3
+			bsonElem(0x13, "b", append(le64(1), le64(1<<63|uint64(6176+3)<<49)...)),
+			bsonElem(0x13, "c", append(le64(0), le64(0x7c<<56)...)),
+		), `{"a":{"$numberDecimal":"1.5"},"b":{"$numberDecimal":"-1E+3"},"c":{"$numberDecimal":"NaN"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := bsonToJSON(tt.doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+			if !json.Valid(got) {
+				t.Errorf("%s is not valid JSON", got)
+			}
+		})
+	}
+}
+
+func TestBSONToJSONInvalid(t *testing.T) {
+	valid := bsonDoc(bsonElem(0x02, "s", bsonString("abc")))
+	tests := map[string][]byte{
+		"short":          {5, 0, 0},
+		"truncated":      valid[:len(valid)-3],
+		"trailing bytes": append(append([]byte{}, valid...), 0),
+		"no terminator":  append(append([]byte{}, valid[:len(valid)-1]...), 1),
+		"unknown type":   bsonDoc(bsonElem(0x42, "x", nil)),
+		"string length":  bsonDoc(bsonElem(0x02, "s", le32(100))),
+		"hex dump":       []byte("05 00 00 00 00"),
+	}
+	for name, doc := range tests {
+		if got, err := bsonToJSON(doc); err == nil {
+			t.Errorf("%s: got %s, want an error", name, got)
+		}
+	}
+}
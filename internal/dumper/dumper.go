@@ -2,8 +2,10 @@ package dumper
 
 import (
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"reflect"
+	"time"
 
 	"github.com/apache/arrow/go/v17/parquet"
 	"github.com/apache/arrow/go/v17/parquet/file"
@@ -14,6 +16,7 @@ const defaultBatchSize = 128
 
 type Dumper struct {
 	reader         file.ColumnChunkReader
+	descr          *schema.Column
 	batchSize      int64
 	valueOffset    int
 	valuesBuffered int
@@ -25,9 +28,27 @@ type Dumper struct {
 
 	valueBuffer      interface{}
 	parseInt96AsTime bool
+	// location is where UTC-adjusted timestamps are shown.
+	location *time.Location
+
+	// pending is the first value of the next record, read by NextRecord.
+	pending *Value
+}
+
+// Value is a value of a column with its levels. Value is nil when the
+// definition level is below the maximum of the column.
+type Value struct {
+	Value interface{}
+	Def   int16
+	Rep   int16
 }
 
-func NewDumper(reader file.ColumnChunkReader, parseInt96AsTime bool) *Dumper {
+// NewDumper reads the values of a column chunk. Timestamps adjusted to UTC
+// are shown in loc, UTC when loc is nil.
+func NewDumper(reader file.ColumnChunkReader, parseInt96AsTime bool, loc *time.Location) *Dumper {
+	if loc == nil {
+		loc = time.UTC
+	}
 	batchSize := defaultBatchSize
 
 	var valueBuffer interface{}
@@ -52,14 +73,25 @@ func NewDumper(reader file.ColumnChunkReader, parseInt96AsTime bool) *Dumper {
 
 	return &Dumper{
 		reader:           reader,
+		descr:            reader.Descriptor(),
 		batchSize:        int64(batchSize),
 		defLevels:        make([]int16, batchSize),
 		repLevels:        make([]int16, batchSize),
 		valueBuffer:      valueBuffer,
 		parseInt96AsTime: parseInt96AsTime,
+		location:         loc,
 	}
 }
 
+// NewFormatter returns a Dumper that only formats values of a column with
+// FormatValue and JSONValue, such as its min and max statistics.
+func NewFormatter(descr *schema.Column, parseInt96AsTime bool, loc *time.Location) *Dumper {
+	if loc == nil {
+		loc = time.UTC
+	}
+	return &Dumper{descr: descr, parseInt96AsTime: parseInt96AsTime, location: loc}
+}
+
 func (dump *Dumper) readNextBatch() {
 	switch reader := dump.reader.(type) {
 	case *file.BooleanColumnChunkReader:
@@ -96,8 +128,16 @@ func (dump *Dumper) hasNext() bool {
 	return dump.levelOffset < dump.levelsBuffered || dump.reader.HasNext()
 }
 
+// FormatValue formats a value by the logical type of the column, or by its
+// physical type when the column has none, padded to width.
 func (dump *Dumper) FormatValue(val interface{}, width int) string {
 	fmtStr := fmt.Sprintf("-%d", width)
+	if v, ok := dump.logicalValue(val); ok {
+		if raw, ok := v.(json.RawMessage); ok {
+			v = string(raw)
+		}
+		return fmt.Sprintf("%"+fmtStr+"v", v)
+	}
 	switch val := val.(type) {
 	case nil:
 		return fmt.Sprintf("%"+fmtStr+"s", "NULL")
@@ -122,7 +162,7 @@ func (dump *Dumper) FormatValue(val interface{}, width int) string {
 					binary.LittleEndian.Uint32(val[8:])))
 		}
 	case parquet.ByteArray:
-		if dump.reader.Descriptor().ConvertedType() == schema.ConvertedTypes.UTF8 {
+		if dump.descr.ConvertedType() == schema.ConvertedTypes.UTF8 {
 			return fmt.Sprintf("%"+fmtStr+"s", string(val))
 		}
 		return fmt.Sprintf("% "+fmtStr+"X", val)
@@ -133,6 +173,20 @@ func (dump *Dumper) FormatValue(val interface{}, width int) string {
 	}
 }
 
+// JSONValue returns a value in a form encoding/json renders: by the logical
+// type of the column, see FormatValue, then booleans and numbers as they
+// are and other physical types formatted.
+func (dump *Dumper) JSONValue(val interface{}) interface{} {
+	if v, ok := dump.logicalValue(val); ok {
+		return v
+	}
+	switch val.(type) {
+	case nil, bool, int32, int64, float32, float64:
+		return val
+	}
+	return dump.FormatValue(val, 0)
+}
+
 func (dump *Dumper) Next() (interface{}, bool) {
 	if dump.levelOffset == dump.levelsBuffered {
 		if !dump.hasNext() {
@@ -157,3 +211,56 @@ func (dump *Dumper) Next() (interface{}, bool) {
 
 	return v, true
 }
+
+// nextValue reads the next value with its levels.
+func (dump *Dumper) nextValue() (Value, bool) {
+	if dump.levelOffset == dump.levelsBuffered {
+		if !dump.hasNext() {
+			return Value{}, false
+		}
+		dump.readNextBatch()
+		if dump.levelsBuffered == 0 {
+			return Value{}, false
+		}
+	}
+
+	descr := dump.reader.Descriptor()
+	v := Value{Def: dump.defLevels[int(dump.levelOffset)]}
+	if descr.MaxRepetitionLevel() > 0 {
+		v.Rep = dump.repLevels[int(dump.levelOffset)]
+	}
+	dump.levelOffset++
+
+	if v.Def == descr.MaxDefinitionLevel() {
+		v.Value = reflect.ValueOf(dump.valueBuffer).Index(dump.valueOffset).Interface()
+		dump.valueOffset++
+	}
+	return v, true
+}
+
+// NextRecord returns the values of the next record: the value with
+// repetition level 0 that starts it and the repeated values after it.
+// It must not be mixed with Next.
+func (dump *Dumper) NextRecord() ([]Value, bool) {
+	var first Value
+	if dump.pending != nil {
+		first, dump.pending = *dump.pending, nil
+	} else {
+		var ok bool
+		if first, ok = dump.nextValue(); !ok {
+			return nil, false
+		}
+	}
+	values := []Value{first}
+	for {
+		v, ok := dump.nextValue()
+		if !ok {
+			return values, true
+		}
+		if v.Rep == 0 {
+			dump.pending = &v
+			return values, true
+		}
+		values = append(values, v)
+	}
+}
@@ -0,0 +1,174 @@
+package dumper
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow/go/v17/arrow/float16"
+	"github.com/apache/arrow/go/v17/parquet"
+	"github.com/apache/arrow/go/v17/parquet/schema"
+)
+
+// logicalValue renders val by the logical type of the column: decimals
+// as a string with the exact digits, which most JSON readers would round
+// as a number, dates, times and timestamps as ISO 8601 strings, JSON as a
+// json.RawMessage, BSON as a json.RawMessage in relaxed Extended JSON,
+// unsigned integers as uint32 or uint64, FLOAT16 as a float32 and UUIDs,
+// enums and intervals as strings. ok is false for columns without a
+// logical type and for values that do not fit it, invalid BSON included,
+// they are rendered by their physical type.
+func (dump *Dumper) logicalValue(val interface{}) (interface{}, bool) {
+	switch lt := dump.descr.LogicalType().(type) {
+	case schema.StringLogicalType, schema.EnumLogicalType:
+		if b, ok := val.(parquet.ByteArray); ok {
+			return string(b), true
+		}
+	case schema.JSONLogicalType:
+		if b, ok := val.(parquet.ByteArray); ok {
+			if json.Valid(b) {
+				return json.RawMessage(b), true
+			}
+			return string(b), true
+		}
+	case schema.BSONLogicalType:
+		if b, ok := val.(parquet.ByteArray); ok {
+			if doc, err := bsonToJSON(b); err == nil {
+				return doc, true
+			}
+		}
+	case *schema.DecimalLogicalType:
+		if unscaled, ok := unscaledDecimal(val); ok {
+			return formatDecimal(unscaled, lt.Scale()), true
+		}
+	case *schema.IntLogicalType:
+		if lt.IsSigned() {
+			return nil, false
+		}
+		switch v := val.(type) {
+		case int32:
+			return uint32(v), true
+		case int64:
+			return uint64(v), true
+		}
+	case schema.DateLogicalType:
+		if v, ok := val.(int32); ok {
+			return time.Unix(int64(v)*24*60*60, 0).UTC().Format(time.DateOnly), true
+		}
+	case *schema.TimeLogicalType:
+		if t, ok := unitTime(val, lt.TimeUnit()); ok {
+			s := t.UTC().Format("15:04:05.999999999")
+			if lt.IsAdjustedToUTC() {
+				s += "Z"
+			}
+			return s, true
+		}
+	case *schema.TimestampLogicalType:
+		if t, ok := unitTime(val, lt.TimeUnit()); ok {
+			if !lt.IsAdjustedToUTC() {
+				// a local date and time, shown as written
+				return t.UTC().Format("2006-01-02T15:04:05.999999999"), true
+			}
+			return t.In(dump.location).Format(time.RFC3339Nano), true
+		}
+	case schema.UUIDLogicalType:
+		if b, ok := val.(parquet.FixedLenByteArray); ok && len(b) == 16 {
+			s := hex.EncodeToString(b)
+			return s[:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:], true
+		}
+	case schema.Float16LogicalType:
+		if b, ok := val.(parquet.FixedLenByteArray); ok && len(b) == 2 {
+			f := float16.FromBits(binary.LittleEndian.Uint16(b)).Float32()
+			if math.IsNaN(float64(f)) || math.IsInf(float64(f), 0) {
+				return strconv.FormatFloat(float64(f), 'g', -1, 32), true
+			}
+			return f, true
+		}
+	case schema.IntervalLogicalType:
+		if b, ok := val.(parquet.FixedLenByteArray); ok && len(b) == 12 {
+			return formatInterval(
+				binary.LittleEndian.Uint32(b[0:4]),
+				binary.LittleEndian.Uint32(b[4:8]),
+				binary.LittleEndian.Uint32(b[8:12]),
+			), true
+		}
+	}
+	return nil, false
+}
+
+// unscaledDecimal returns the unscaled value of a decimal stored as
+// int32, int64 or a big-endian two's complement byte array.
+func unscaledDecimal(val interface{}) (*big.Int, bool) {
+	switch v := val.(type) {
+	case int32:
+		return big.NewInt(int64(v)), true
+	case int64:
+		return big.NewInt(v), true
+	case parquet.ByteArray:
+		return twosComplement(v), true
+	case parquet.FixedLenByteArray:
+		return twosComplement(v), true
+	}
+	return nil, false
+}
+
+func twosComplement(b []byte) *big.Int {
+	n := new(big.Int).SetBytes(b)
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		n.Sub(n, new(big.Int).Lsh(big.NewInt(1), uint(len(b))*8))
+	}
+	return n
+}
+
+// formatDecimal places the decimal point scale digits from the right of
+// the unscaled value.
+func formatDecimal(unscaled *big.Int, scale int32) string {
+	digits := new(big.Int).Abs(unscaled).String()
+	sign := ""
+	if unscaled.Sign() < 0 {
+		sign = "-"
+	}
+	if scale <= 0 {
+		return sign + digits + strings.Repeat("0", int(-scale))
+	}
+	if len(digits) <= int(scale) {
+		digits = strings.Repeat("0", int(scale)-len(digits)+1) + digits
+	}
+	point := len(digits) - int(scale)
+	return sign + digits[:point] + "." + digits[point:]
+}
+
+// unitTime converts a time or timestamp value in unit since the epoch,
+// or since midnight for times.
+func unitTime(val interface{}, unit schema.TimeUnitType) (time.Time, bool) {
+	var n int64
+	switch v := val.(type) {
+	case int32:
+		n = int64(v)
+	case int64:
+		n = v
+	default:
+		return time.Time{}, false
+	}
+	switch unit {
+	case schema.TimeUnitMillis:
+		return time.UnixMilli(n), true
+	case schema.TimeUnitMicros:
+		return time.UnixMicro(n), true
+	case schema.TimeUnitNanos:
+		return time.Unix(0, n), true
+	}
+	return time.Time{}, false
+}
+
+// formatInterval formats an INTERVAL as an ISO 8601 duration.
+func formatInterval(months, days, millis uint32) string {
+	seconds := strconv.FormatFloat(float64(millis)/1000, 'f', -1, 64)
+	return fmt.Sprintf("P%dM%dDT%sS", months, days, seconds)
+}
@@ -0,0 +1,175 @@
+// Package pageindex reads the page indexes of Parquet files: the
+// ColumnIndex with the min and max values of every page of a column chunk
+// and the OffsetIndex with the location and first row of every page.
+// Writers store them between the last row group and the footer, at the
+// offsets the column chunks of the footer hold.
+package pageindex
+
+import "fmt"
+
+// Location is where the page indexes of a column chunk are stored, zero
+// lengths when the chunk has none.
+type Location struct {
+	OffsetIndexOffset int64
+	OffsetIndexLength int32
+	ColumnIndexOffset int64
+	ColumnIndexLength int32
+}
+
+// HasOffsetIndex reports whether the chunk has an OffsetIndex.
+func (l Location) HasOffsetIndex() bool {
+	return l.OffsetIndexLength > 0
+}
+
+// HasColumnIndex reports whether the chunk has a ColumnIndex.
+func (l Location) HasColumnIndex() bool {
+	return l.ColumnIndexLength > 0
+}
+
+// Locations returns the page index locations of every column chunk, by row
+// group and column, from the thrift encoded FileMetaData of the footer.
+func Locations(footer []byte) ([][]Location, error) {
+	d := &decoder{b: footer}
+	var rowGroups [][]Location
+	d.fields(func(id int16, typ byte) {
+		if id != 4 || typ != typeList {
+			d.skip(typ)
+			return
+		}
+		_, n := d.list()
+		for i := 0; i < n && d.err == nil; i++ {
+			rowGroups = append(rowGroups, d.rowGroup())
+		}
+	})
+	if d.err != nil {
+		return nil, fmt.Errorf("error reading footer: %w", d.err)
+	}
+	return rowGroups, nil
+}
+
+func (d *decoder) rowGroup() []Location {
+	var cols []Location
+	d.fields(func(id int16, typ byte) {
+		if id != 1 || typ != typeList {
+			d.skip(typ)
+			return
+		}
+		_, n := d.list()
+		for i := 0; i < n && d.err == nil; i++ {
+			cols = append(cols, d.columnChunk())
+		}
+	})
+	return cols
+}
+
+func (d *decoder) columnChunk() Location {
+	var l Location
+	d.fields(func(id int16, typ byte) {
+		switch {
+		case id == 4 && typ == typeI64:
+			l.OffsetIndexOffset = d.varint()
+		case id == 5 && typ == typeI32:
+			l.OffsetIndexLength = int32(d.varint())
+		case id == 6 && typ == typeI64:
+			l.ColumnIndexOffset = d.varint()
+		case id == 7 && typ == typeI32:
+			l.ColumnIndexLength = int32(d.varint())
+		default:
+			d.skip(typ)
+		}
+	})
+	return l
+}
+
+// PageLocation is a data page of a column chunk.
+type PageLocation struct {
+	// Offset is the position of the page header in the file.
+	Offset int64
+	// CompressedPageSize is the size of the page with its header.
+	CompressedPageSize int32
+	// FirstRowIndex is the first row of the page in the row group.
+	FirstRowIndex int64
+}
+
+// OffsetIndex locates the data pages of a column chunk, in order.
+type OffsetIndex struct {
+	PageLocations []PageLocation
+}
+
+// ParseOffsetIndex decodes an OffsetIndex.
+func ParseOffsetIndex(b []byte) (*OffsetIndex, error) {
+	d := &decoder{b: b}
+	oi := &OffsetIndex{}
+	d.fields(func(id int16, typ byte) {
+		if id != 1 || typ != typeList {
+			d.skip(typ)
+			return
+		}
+		_, n := d.list()
+		oi.PageLocations = make([]PageLocation, 0, n)
+		for i := 0; i < n && d.err == nil; i++ {
+			var loc PageLocation
+			d.fields(func(id int16, typ byte) {
+				switch {
+				case id == 1 && typ == typeI64:
+					loc.Offset = d.varint()
+				case id == 2 && typ == typeI32:
+					loc.CompressedPageSize = int32(d.varint())
+				case id == 3 && typ == typeI64:
+					loc.FirstRowIndex = d.varint()
+				default:
+					d.skip(typ)
+				}
+			})
+			oi.PageLocations = append(oi.PageLocations, loc)
+		}
+	})
+	if d.err != nil {
+		return nil, fmt.Errorf("error reading offset index: %w", d.err)
+	}
+	return oi, nil
+}
+
+// ColumnIndex holds the statistics of the data pages of a column chunk,
+// in the order of the OffsetIndex. Min and max values are plain encoded,
+// like the statistics of the column chunk, and meaningless for null pages.
+type ColumnIndex struct {
+	NullPages []bool
+	MinValues [][]byte
+	MaxValues [][]byte
+	// NullCounts is nil when the writer left them out.
+	NullCounts []int64
+}
+
+// ParseColumnIndex decodes a ColumnIndex.
+func ParseColumnIndex(b []byte) (*ColumnIndex, error) {
+	d := &decoder{b: b}
+	ci := &ColumnIndex{}
+	d.fields(func(id int16, typ byte) {
+		if typ != typeList || id < 1 || id > 5 || id == 4 {
+			d.skip(typ)
+			return
+		}
+		_, n := d.list()
+		for i := 0; i < n && d.err == nil; i++ {
+			switch id {
+			case 1:
+				ci.NullPages = append(ci.NullPages, d.boolElem())
+			case 2:
+				ci.MinValues = append(ci.MinValues, d.binary())
+			case 3:
+				ci.MaxValues = append(ci.MaxValues, d.binary())
+			case 5:
+				ci.NullCounts = append(ci.NullCounts, d.varint())
+			}
+		}
+	})
+	if d.err != nil {
+		return nil, fmt.Errorf("error reading column index: %w", d.err)
+	}
+	n := len(ci.NullPages)
+	if len(ci.MinValues) != n || len(ci.MaxValues) != n || (ci.NullCounts != nil && len(ci.NullCounts) != n) {
+		return nil, fmt.Errorf("error reading column index: %d null pages, %d min and %d max values", n, len(ci.MinValues), len(ci.MaxValues))
+	}
+	return ci, nil
+}
@@ -0,0 +1,282 @@
+package pageindex
+
+import (
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// encoder writes the thrift compact protocol for the tests.
+type encoder struct {
+	b    []byte
+	last []int16
+}
+
+func (e *encoder) uvarint(v uint64) { e.b = binary.AppendUvarint(e.b, v) }
+func (e *encoder) varint(v int64)   { e.uvarint(uint64(v<<1) ^ uint64(v>>63)) }
+
+func (e *encoder) field(id int16, typ byte) {
+	last := &e.last[len(e.last)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		e.b = append(e.b, byte(delta)<<4|typ)
+	} else {
+		e.b = append(e.b, typ)
+		e.varint(int64(id))
+	}
+	*last = id
+}
+
+func (e *encoder) begin() { e.last = append(e.last, 0) }
+
+func (e *encoder) end() {
+	e.b = append(e.b, typeStop)
+	e.last = e.last[:len(e.last)-1]
+}
+
+func (e *encoder) i64(id int16, v int64) { e.field(id, typeI64); e.varint(v) }
+func (e *encoder) i32(id int16, v int32) { e.field(id, typeI32); e.varint(int64(v)) }
+
+func (e *encoder) bytes(b []byte) {
+	e.uvarint(uint64(len(b)))
+	e.b = append(e.b, b...)
+}
+
+func (e *encoder) list(elem byte, n int) {
+	if n < 15 {
+		e.b = append(e.b, byte(n)<<4|elem)
+		return
+	}
+	e.b = append(e.b, 0xf0|elem)
+	e.uvarint(uint64(n))
+}
+
+// unknown writes fields of every type the decoders skip, nested.
+func (e *encoder) unknown(id int16) {
+	e.field(id, typeStruct)
+	e.begin()
+	e.field(1, typeTrue)
+	e.field(2, typeByte)
+	e.b = append(e.b, 7)
+	e.field(3, typeDouble)
+	e.b = append(e.b, make([]byte, 8)...)
+	e.field(4, typeBinary)
+	e.bytes([]byte("skip"))
+	e.field(5, typeList)
+	e.list(typeFalse, 2)
+	e.b = append(e.b, typeTrue, typeFalse)
+	e.field(6, typeMap)
+	e.uvarint(1)
+	e.b = append(e.b, typeBinary<<4|typeStruct)
+	e.bytes([]byte("k"))
+	e.begin()
+	e.i32(1, 1)
+	e.end()
+	e.field(300, typeSet)
+	e.list(typeI16, 1)
+	e.varint(-3)
+	e.end()
+}
+
+func encodeOffsetIndex(locs []PageLocation) []byte {
+	e := &encoder{}
+	e.begin()
+	e.unknown(0)
+	e.field(1, typeList)
+	e.list(typeStruct, len(locs))
+	for _, l := range locs {
+		e.begin()
+		e.i64(1, l.Offset)
+		e.i32(2, l.CompressedPageSize)
+		e.unknown(2)
+		e.i64(3, l.FirstRowIndex)
+		e.end()
+	}
+	e.unknown(7)
+	e.end()
+	return e.b
+}
+
+func encodeColumnIndex(ci *ColumnIndex) []byte {
+	e := &encoder{}
+	e.begin()
+	e.field(1, typeList)
+	e.list(typeTrue, len(ci.NullPages))
+	for _, null := range ci.NullPages {
+		if null {
+			e.b = append(e.b, typeTrue)
+		} else {
+			e.b = append(e.b, typeFalse)
+		}
+	}
+	for i, values := range [][][]byte{ci.MinValues, ci.MaxValues} {
+		e.field(int16(2+i), typeList)
+		e.list(typeBinary, len(values))
+		for _, v := range values {
+			e.bytes(v)
+		}
+	}
+	e.i32(4, 1)
+	if ci.NullCounts != nil {
+		e.field(5, typeList)
+		e.list(typeI64, len(ci.NullCounts))
+		for _, n := range ci.NullCounts {
+			e.varint(n)
+		}
+	}
+	e.end()
+	return e.b
+}
+
+func encodeFooter(rowGroups [][]Location) []byte {
+	e := &encoder{}
+	e.begin()
+	e.i32(1, 2)
+	e.unknown(2)
+	e.field(4, typeList)
+	e.list(typeStruct, len(rowGroups))
+	for _, cols := range rowGroups {
+		e.begin()
+		e.field(1, typeList)
+		e.list(typeStruct, len(cols))
+		for _, l := range cols {
+			e.begin()
+			e.i64(2, 4)
+			e.unknown(3)
+			if l.HasOffsetIndex() {
+				e.i64(4, l.OffsetIndexOffset)
+				e.i32(5, l.OffsetIndexLength)
+			}
+			if l.HasColumnIndex() {
+				e.i64(6, l.ColumnIndexOffset)
+				e.i32(7, l.ColumnIndexLength)
+			}
+			e.end()
+		}
+		e.i64(2, 100)
+		e.end()
+	}
+	e.end()
+	return e.b
+}
+
+func TestParseOffsetIndex(t *testing.T) {
+	var want []PageLocation
+	for i := range 20 {
+		want = append(want, PageLocation{Offset: int64(4 + 100*i), CompressedPageSize: 100, FirstRowIndex: int64(1000 * i)})
+	}
+	got, err := ParseOffsetIndex(encodeOffsetIndex(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.PageLocations, want) {
+		t.Errorf("got %v, want %v", got.PageLocations, want)
+	}
+}
+
+func TestParseColumnIndex(t *testing.T) {
+	tests := map[string]*ColumnIndex{
+		"null counts": {
+			NullPages:  []bool{false, true, false},
+			MinValues:  [][]byte{[]byte("a"), {}, []byte("m")},
+			MaxValues:  [][]byte{[]byte("f"), {}, []byte("z")},
+			NullCounts: []int64{0, 5, 1},
+		},
+		"no null counts": {
+			NullPages: []bool{false},
+			MinValues: [][]byte{{1, 0, 0, 0}},
+			MaxValues: [][]byte{{9, 0, 0, 0}},
+		},
+	}
+	for name, want := range tests {
+		got, err := ParseColumnIndex(encodeColumnIndex(want))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %+v, want %+v", name, got, want)
+		}
+	}
+}
+
+func TestLocations(t *testing.T) {
+	want := [][]Location{
+		{{OffsetIndexOffset: 500, OffsetIndexLength: 20, ColumnIndexOffset: 400, ColumnIndexLength: 30}, {}},
+		{{ColumnIndexOffset: 600, ColumnIndexLength: 10}, {OffsetIndexOffset: 700, OffsetIndexLength: 5}},
+	}
+	got, err := Locations(encodeFooter(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	inputs := map[string][]byte{
+		"offset index": encodeOffsetIndex([]PageLocation{{Offset: 4, CompressedPageSize: 10}, {Offset: 14, CompressedPageSize: 10, FirstRowIndex: 3}}),
+		"column index": encodeColumnIndex(&ColumnIndex{
+			NullPages:  []bool{false, true},
+			MinValues:  [][]byte{[]byte("abc"), {}},
+			MaxValues:  [][]byte{[]byte("abd"), {}},
+			NullCounts: []int64{0, 2},
+		}),
+		"footer": encodeFooter([][]Location{{{OffsetIndexOffset: 1, OffsetIndexLength: 2}}}),
+	}
+	parse := map[string]func([]byte) error{
+		"offset index": func(b []byte) error { _, err := ParseOffsetIndex(b); return err },
+		"column index": func(b []byte) error { _, err := ParseColumnIndex(b); return err },
+		"footer":       func(b []byte) error { _, err := Locations(b); return err },
+	}
+	for name, b := range inputs {
+		if err := parse[name](b); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		for n := range len(b) {
+			if err := parse[name](b[:n]); err == nil {
+				t.Errorf("%s: no error for %d of %d bytes", name, n, len(b))
+			}
+		}
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	deep := &encoder{}
+	deep.begin()
+	for range maxSkipDepth + 2 {
+		deep.field(9, typeStruct)
+		deep.begin()
+	}
+	for range maxSkipDepth + 3 {
+		deep.end()
+	}
+
+	huge := &encoder{}
+	huge.begin()
+	huge.field(1, typeList)
+	huge.list(typeStruct, 1<<40)
+
+	tests := []struct {
+		name string
+		b    []byte
+		want string
+	}{
+		{"nested too deep", deep.b, "nested too deep"},
+		{"list size", huge.b, "invalid list size"},
+		{"unknown type", []byte{0x1d}, "unknown thrift type 13"},
+		{"binary size", []byte{0x18, 0xff, 0xff, 0x03}, "truncated"},
+	}
+	for _, tt := range tests {
+		_, err := ParseOffsetIndex(tt.b)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %v, want an error containing %q", tt.name, err, tt.want)
+		}
+	}
+
+	// the lists of a column index must have the same length
+	b := encodeColumnIndex(&ColumnIndex{NullPages: []bool{false}, MinValues: [][]byte{{1}, {2}}, MaxValues: [][]byte{{2}}})
+	if _, err := ParseColumnIndex(b); err == nil {
+		t.Error("no error for a column index with uneven lists")
+	}
+}
@@ -0,0 +1,184 @@
+package pageindex
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// The types of the thrift compact protocol.
+const (
+	typeStop      = 0
+	typeTrue      = 1
+	typeFalse     = 2
+	typeByte      = 3
+	typeI16       = 4
+	typeI32       = 5
+	typeI64       = 6
+	typeDouble    = 7
+	typeBinary    = 8
+	typeList      = 9
+	typeSet       = 10
+	typeMap       = 11
+	typeStruct    = 12
+	maxSkipDepth  = 64
+	maxBinarySize = 1 << 30
+)
+
+var errTruncated = errors.New("pageindex: truncated thrift data")
+
+// decoder reads the thrift compact protocol, the encoding of the Parquet
+// footer and page indexes. The first error sticks and reads after it
+// return zero values.
+type decoder struct {
+	b   []byte
+	off int
+	err error
+}
+
+func (d *decoder) fail(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil {
+		return 0
+	}
+	if d.off >= len(d.b) {
+		d.fail(errTruncated)
+		return 0
+	}
+	c := d.b[d.off]
+	d.off++
+	return c
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.b[d.off:])
+	if n <= 0 {
+		d.fail(errTruncated)
+		return 0
+	}
+	d.off += n
+	return v
+}
+
+func (d *decoder) varint() int64 {
+	v := d.uvarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (d *decoder) binary() []byte {
+	n := d.uvarint()
+	if d.err != nil {
+		return nil
+	}
+	if n > maxBinarySize || uint64(len(d.b)-d.off) < n {
+		d.fail(errTruncated)
+		return nil
+	}
+	b := d.b[d.off : d.off+int(n)]
+	d.off += int(n)
+	return b
+}
+
+// list reads a list or set header and returns its element type and size.
+func (d *decoder) list() (byte, int) {
+	h := d.byte()
+	n := int64(h >> 4)
+	if n == 15 {
+		n = int64(d.uvarint())
+	}
+	if n < 0 || n > int64(len(d.b)) {
+		d.fail(fmt.Errorf("pageindex: invalid list size %d", n))
+		return 0, 0
+	}
+	return h & 0x0f, int(n)
+}
+
+// boolElem reads a boolean list element, a byte of its own.
+func (d *decoder) boolElem() bool {
+	return d.byte() == typeTrue
+}
+
+// fields calls f for each field of a struct until its stop field. f reads
+// the value of the fields it knows and skips the others.
+func (d *decoder) fields(f func(id int16, typ byte)) {
+	var id int16
+	for d.err == nil {
+		h := d.byte()
+		if h == typeStop {
+			return
+		}
+		if delta := int16(h >> 4); delta != 0 {
+			id += delta
+		} else {
+			id = int16(d.varint())
+		}
+		f(id, h&0x0f)
+	}
+}
+
+// fieldBool returns the value of a boolean field, held in its type.
+func fieldBool(typ byte) bool {
+	return typ == typeTrue
+}
+
+func (d *decoder) skip(typ byte) {
+	d.skipDepth(typ, 0)
+}
+
+func (d *decoder) skipDepth(typ byte, depth int) {
+	if depth > maxSkipDepth {
+		d.fail(errors.New("pageindex: thrift data nested too deep"))
+		return
+	}
+	switch typ {
+	case typeTrue, typeFalse:
+	case typeByte:
+		d.byte()
+	case typeI16, typeI32, typeI64:
+		d.uvarint()
+	case typeDouble:
+		if len(d.b)-d.off < 8 {
+			d.fail(errTruncated)
+			return
+		}
+		d.off += 8
+	case typeBinary:
+		d.binary()
+	case typeList, typeSet:
+		elem, n := d.list()
+		for i := 0; i < n && d.err == nil; i++ {
+			d.skipElem(elem, depth+1)
+		}
+	case typeMap:
+		n := d.uvarint()
+		if n == 0 {
+			return
+		}
+		kv := d.byte()
+		for i := uint64(0); i < n && d.err == nil; i++ {
+			d.skipElem(kv>>4, depth+1)
+			d.skipElem(kv&0x0f, depth+1)
+		}
+	case typeStruct:
+		d.fields(func(_ int16, typ byte) { d.skipDepth(typ, depth+1) })
+	default:
+		d.fail(fmt.Errorf("pageindex: unknown thrift type %d", typ))
+	}
+}
+
+// skipElem skips a container element, where booleans take a byte.
+func (d *decoder) skipElem(typ byte, depth int) {
+	if typ == typeTrue || typ == typeFalse {
+		d.byte()
+		return
+	}
+	d.skipDepth(typ, depth)
+}
@@ -0,0 +1,166 @@
+// Package predicate parses the row filter expressions of --where, a small
+// SQL-like language:
+//
+//	country = 'DE' AND amount > 100 AND ts BETWEEN '2024-01-01' AND '2024-02-01'
+//	status IN ('new', 'open') OR (note IS NOT NULL AND note LIKE '%urgent%')
+//	NOT name ~ '^tmp_'
+//
+// Columns are dot separated paths, segments with other characters than
+// letters, digits and _ are quoted with double quotes or backticks.
+// Strings are single quoted, a quote is written twice. Keywords are case
+// insensitive.
+package predicate
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Expr is a boolean expression.
+type Expr interface {
+	fmt.Stringer
+	expr()
+}
+
+// Operand is a column or a literal.
+type Operand interface {
+	fmt.Stringer
+	operand()
+}
+
+type (
+	// And is true when both sides are.
+	And struct{ Left, Right Expr }
+	// Or is true when either side is.
+	Or struct{ Left, Right Expr }
+	// Not negates X.
+	Not struct{ X Expr }
+
+	// Compare compares two operands with =, !=, <, <=, > or >=.
+	Compare struct {
+		Op          string
+		Left, Right Operand
+	}
+	// Between is true when Low <= X <= High.
+	Between struct {
+		X, Low, High Operand
+		Not          bool
+	}
+	// In is true when X equals one of List.
+	In struct {
+		X    Operand
+		List []Operand
+		Not  bool
+	}
+	// IsNull is true when X is null.
+	IsNull struct {
+		X   Operand
+		Not bool
+	}
+	// Like matches X against a LIKE pattern, with % for any characters
+	// and _ for one, escaped with a backslash, or against a regular
+	// expression.
+	Like struct {
+		X       Operand
+		Pattern string
+		Regexp  bool
+		Not     bool
+	}
+)
+
+func (And) expr()     {}
+func (Or) expr()      {}
+func (Not) expr()     {}
+func (Compare) expr() {}
+func (Between) expr() {}
+func (In) expr()      {}
+func (IsNull) expr()  {}
+func (Like) expr()    {}
+
+func (e And) String() string { return "(" + e.Left.String() + " AND " + e.Right.String() + ")" }
+func (e Or) String() string  { return "(" + e.Left.String() + " OR " + e.Right.String() + ")" }
+func (e Not) String() string { return "NOT " + e.X.String() }
+
+func (e Compare) String() string {
+	return e.Left.String() + " " + e.Op + " " + e.Right.String()
+}
+
+func (e Between) String() string {
+	return e.X.String() + not(e.Not) + " BETWEEN " + e.Low.String() + " AND " + e.High.String()
+}
+
+func (e In) String() string {
+	list := make([]string, len(e.List))
+	for i, o := range e.List {
+		list[i] = o.String()
+	}
+	return e.X.String() + not(e.Not) + " IN (" + strings.Join(list, ", ") + ")"
+}
+
+func (e IsNull) String() string {
+	if e.Not {
+		return e.X.String() + " IS NOT NULL"
+	}
+	return e.X.String() + " IS NULL"
+}
+
+func (e Like) String() string {
+	op := " LIKE "
+	if e.Regexp {
+		op = " REGEXP "
+	}
+	return e.X.String() + not(e.Not) + op + quote(e.Pattern)
+}
+
+func not(n bool) string {
+	if n {
+		return " NOT"
+	}
+	return ""
+}
+
+// LiteralKind is the kind of a literal.
+type LiteralKind int
+
+const (
+	String LiteralKind = iota
+	Number
+	Bool
+	Null
+)
+
+type (
+	// Column is a column path.
+	Column struct{ Path []string }
+	// Literal is a string, number, boolean or NULL. Text holds the string
+	// or the digits of the number.
+	Literal struct {
+		Kind LiteralKind
+		Text string
+		Bool bool
+	}
+)
+
+func (Column) operand()  {}
+func (Literal) operand() {}
+
+func (c Column) String() string { return strings.Join(c.Path, ".") }
+
+func (l Literal) String() string {
+	switch l.Kind {
+	case String:
+		return quote(l.Text)
+	case Bool:
+		if l.Bool {
+			return "TRUE"
+		}
+		return "FALSE"
+	case Null:
+		return "NULL"
+	}
+	return l.Text
+}
+
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
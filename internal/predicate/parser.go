@@ -0,0 +1,380 @@
+package predicate
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokQuotedIdent
+	tokString
+	tokNumber
+	tokOp
+	tokLParen
+	tokRParen
+	tokComma
+	tokDot
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// SyntaxError is an error in an expression at byte offset Pos.
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at offset %d: %s", e.Pos, e.Msg)
+}
+
+func lex(s string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			toks = append(toks, token{tokLParen, "(", i})
+			i++
+		case c == ')':
+			toks = append(toks, token{tokRParen, ")", i})
+			i++
+		case c == ',':
+			toks = append(toks, token{tokComma, ",", i})
+			i++
+		case c == '.' && (i+1 >= len(s) || !isDigit(s[i+1])):
+			toks = append(toks, token{tokDot, ".", i})
+			i++
+		case c == '\'':
+			text, n, err := lexQuoted(s[i:], '\'')
+			if err != nil {
+				return nil, &SyntaxError{i, "unterminated string"}
+			}
+			toks = append(toks, token{tokString, text, i})
+			i += n
+		case c == '"' || c == '`':
+			text, n, err := lexQuoted(s[i:], c)
+			if err != nil {
+				return nil, &SyntaxError{i, "unterminated quoted column"}
+			}
+			toks = append(toks, token{tokQuotedIdent, text, i})
+			i += n
+		case isDigit(c) || c == '.' || (c == '-' && i+1 < len(s) && (isDigit(s[i+1]) || s[i+1] == '.')):
+			j := i + 1
+			for j < len(s) && (isDigit(s[j]) || s[j] == '.' || s[j] == 'e' || s[j] == 'E' ||
+				((s[j] == '-' || s[j] == '+') && (s[j-1] == 'e' || s[j-1] == 'E'))) {
+				j++
+			}
+			toks = append(toks, token{tokNumber, s[i:j], i})
+			i = j
+		case c == '_' || unicode.IsLetter(rune(c)) || c >= 0x80:
+			j := i
+			for j < len(s) && (s[j] == '_' || isDigit(s[j]) || unicode.IsLetter(rune(s[j])) || s[j] >= 0x80) {
+				j++
+			}
+			toks = append(toks, token{tokIdent, s[i:j], i})
+			i = j
+		default:
+			op := ""
+			for _, o := range []string{"<=", ">=", "<>", "!=", "=", "<", ">", "~"} {
+				if strings.HasPrefix(s[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, &SyntaxError{i, fmt.Sprintf("unexpected character %q", c)}
+			}
+			toks = append(toks, token{tokOp, op, i})
+			i += len(op)
+		}
+	}
+	return append(toks, token{tokEOF, "", len(s)}), nil
+}
+
+// lexQuoted reads a string quoted with q, where a doubled q stands for
+// itself, and returns its text and length.
+func lexQuoted(s string, q byte) (string, int, error) {
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		if s[i] != q {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+1 < len(s) && s[i+1] == q {
+			b.WriteByte(q)
+			i++
+			continue
+		}
+		return b.String(), i + 1, nil
+	}
+	return "", 0, fmt.Errorf("unterminated")
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+// Parse parses an expression.
+func Parse(s string) (Expr, error) {
+	toks, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	e, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.errorf(t, "unexpected %q", t.text)
+	}
+	return e, nil
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// keyword consumes the next token if it is one of the keywords.
+func (p *parser) keyword(words ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != tokIdent {
+		return "", false
+	}
+	for _, w := range words {
+		if strings.EqualFold(t.text, w) {
+			p.pos++
+			return w, true
+		}
+	}
+	return "", false
+}
+
+func (p *parser) expectKeyword(w string) error {
+	if _, ok := p.keyword(w); !ok {
+		t := p.peek()
+		return p.errorf(t, "expected %s, found %s", w, describe(t))
+	}
+	return nil
+}
+
+func (p *parser) errorf(t token, format string, args ...any) error {
+	return &SyntaxError{t.pos, fmt.Sprintf(format, args...)}
+}
+
+func describe(t token) string {
+	if t.kind == tokEOF {
+		return "end of expression"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+func (p *parser) or() (Expr, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.keyword("OR"); !ok {
+			return left, nil
+		}
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = Or{left, right}
+	}
+}
+
+func (p *parser) and() (Expr, error) {
+	left, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.keyword("AND"); !ok {
+			return left, nil
+		}
+		right, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		left = And{left, right}
+	}
+}
+
+func (p *parser) not() (Expr, error) {
+	if _, ok := p.keyword("NOT"); ok {
+		x, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return Not{x}, nil
+	}
+	if p.peek().kind == tokLParen {
+		p.next()
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); t.kind != tokRParen {
+			return nil, p.errorf(t, "expected ), found %s", describe(t))
+		}
+		return e, nil
+	}
+	return p.predicate()
+}
+
+func (p *parser) predicate() (Expr, error) {
+	x, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	if t.kind == tokOp {
+		p.next()
+		if t.text == "~" {
+			pattern, err := p.pattern()
+			if err != nil {
+				return nil, err
+			}
+			return Like{X: x, Pattern: pattern, Regexp: true}, nil
+		}
+		y, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		op := t.text
+		if op == "<>" {
+			op = "!="
+		}
+		return Compare{op, x, y}, nil
+	}
+	if _, ok := p.keyword("IS"); ok {
+		_, neg := p.keyword("NOT")
+		if err := p.expectKeyword("NULL"); err != nil {
+			return nil, err
+		}
+		return IsNull{x, neg}, nil
+	}
+	_, neg := p.keyword("NOT")
+	kw, ok := p.keyword("BETWEEN", "IN", "LIKE", "REGEXP", "RLIKE")
+	switch {
+	case !ok && neg:
+		t := p.peek()
+		return nil, p.errorf(t, "expected BETWEEN, IN, LIKE or REGEXP after NOT, found %s", describe(t))
+	case !ok:
+		// a bare boolean column
+		if _, isCol := x.(Column); isCol {
+			return Compare{"=", x, Literal{Kind: Bool, Bool: true}}, nil
+		}
+		return nil, p.errorf(t, "expected an operator, found %s", describe(t))
+	case kw == "BETWEEN":
+		low, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("AND"); err != nil {
+			return nil, err
+		}
+		high, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		return Between{x, low, high, neg}, nil
+	case kw == "IN":
+		if t := p.next(); t.kind != tokLParen {
+			return nil, p.errorf(t, "expected ( after IN, found %s", describe(t))
+		}
+		var list []Operand
+		for {
+			o, err := p.operand()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, o)
+			t := p.next()
+			if t.kind == tokRParen {
+				break
+			}
+			if t.kind != tokComma {
+				return nil, p.errorf(t, "expected , or ), found %s", describe(t))
+			}
+		}
+		return In{x, list, neg}, nil
+	}
+	pattern, err := p.pattern()
+	if err != nil {
+		return nil, err
+	}
+	return Like{X: x, Pattern: pattern, Regexp: kw != "LIKE", Not: neg}, nil
+}
+
+func (p *parser) pattern() (string, error) {
+	t := p.next()
+	if t.kind != tokString {
+		return "", p.errorf(t, "expected a quoted pattern, found %s", describe(t))
+	}
+	return t.text, nil
+}
+
+func (p *parser) operand() (Operand, error) {
+	t := p.next()
+	switch t.kind {
+	case tokString:
+		return Literal{Kind: String, Text: t.text}, nil
+	case tokNumber:
+		return Literal{Kind: Number, Text: t.text}, nil
+	case tokIdent:
+		switch strings.ToUpper(t.text) {
+		case "TRUE":
+			return Literal{Kind: Bool, Bool: true}, nil
+		case "FALSE":
+			return Literal{Kind: Bool}, nil
+		case "NULL":
+			return Literal{Kind: Null}, nil
+		}
+		return p.column(t)
+	case tokQuotedIdent:
+		return p.column(t)
+	}
+	return nil, p.errorf(t, "expected a column or a value, found %s", describe(t))
+}
+
+func (p *parser) column(first token) (Operand, error) {
+	path := []string{first.text}
+	for p.peek().kind == tokDot {
+		p.next()
+		t := p.next()
+		if t.kind != tokIdent && t.kind != tokQuotedIdent {
+			return nil, p.errorf(t, "expected a column name after ., found %s", describe(t))
+		}
+		path = append(path, t.text)
+	}
+	return Column{path}, nil
+}
@@ -0,0 +1,119 @@
+package predicate
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		// NOT binds tighter than AND, which binds tighter than OR
+		{"a = 1 OR b = 2 AND c = 3", "(a = 1 OR (b = 2 AND c = 3))"},
+		{"a = 1 AND b = 2 OR c = 3", "((a = 1 AND b = 2) OR c = 3)"},
+		{"NOT a = 1 AND b = 2", "(NOT a = 1 AND b = 2)"},
+		{"NOT NOT a = 1", "NOT NOT a = 1"},
+		{"NOT (a = 1 OR b = 2)", "NOT (a = 1 OR b = 2)"},
+		{"(a = 1 OR b = 2) AND c = 3", "((a = 1 OR b = 2) AND c = 3)"},
+		{"a = 1 OR b = 2 OR c = 3", "((a = 1 OR b = 2) OR c = 3)"},
+		// the AND of BETWEEN belongs to it
+		{"x BETWEEN 1 AND 2 AND y = 3", "(x BETWEEN 1 AND 2 AND y = 3)"},
+		{"x NOT BETWEEN 1 AND 2 OR y BETWEEN 'a' AND 'b'", "(x NOT BETWEEN 1 AND 2 OR y BETWEEN 'a' AND 'b')"},
+		{"NOT x BETWEEN 1 AND 2", "NOT x BETWEEN 1 AND 2"},
+		// operators and keywords
+		{"a <> 1", "a != 1"},
+		{"a>=-1.5e3", "a >= -1.5e3"},
+		{"a < .5", "a < .5"},
+		{"a is not null and b Is Null", "(a IS NOT NULL AND b IS NULL)"},
+		{"a IN (1, 'x', NULL, TRUE)", "a IN (1, 'x', NULL, TRUE)"},
+		{"a not in (false)", "a NOT IN (FALSE)"},
+		{"a LIKE 'x%' OR a NOT LIKE '_y'", "(a LIKE 'x%' OR a NOT LIKE '_y')"},
+		{"a REGEXP '^x' AND a RLIKE 'y' AND a ~ 'z'", "((a REGEXP '^x' AND a REGEXP 'y') AND a REGEXP 'z')"},
+		{"flag", "flag = TRUE"},
+		{"NOT flag OR other", "(NOT flag = TRUE OR other = TRUE)"},
+		// literals and columns
+		{"s = 'it''s'", "s = 'it''s'"},
+		{"\"my col\".`x.y` = 1", "my col.x.y = 1"},
+		{"attrs.color = 'red'", "attrs.color = 'red'"},
+		{"1 = a", "1 = a"},
+		{"naïve = 'é'", "naïve = 'é'"},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.expr)
+		if err != nil {
+			t.Errorf("%s: %v", tt.expr, err)
+			continue
+		}
+		if got := e.String(); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParseLiterals(t *testing.T) {
+	e, err := Parse("a IN ('x''y', -2, NULL, TRUE, false)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Literal{
+		{Kind: String, Text: "x'y"},
+		{Kind: Number, Text: "-2"},
+		{Kind: Null},
+		{Kind: Bool, Bool: true},
+		{Kind: Bool},
+	}
+	in := e.(In)
+	if len(in.List) != len(want) {
+		t.Fatalf("got %d literals, want %d", len(in.List), len(want))
+	}
+	for i, o := range in.List {
+		if o != want[i] {
+			t.Errorf("literal %d: got %#v, want %#v", i, o, want[i])
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		pos  int
+		msg  string
+	}{
+		{"", 0, "expected a column or a value, found end of expression"},
+		{"a =", 3, "expected a column or a value, found end of expression"},
+		{"a = 'x", 4, "unterminated string"},
+		{"\"a = 1", 0, "unterminated quoted column"},
+		{"(a = 1", 6, "expected ), found end of expression"},
+		{"a = 1)", 5, `unexpected ")"`},
+		{"a = 1 b = 2", 6, `unexpected "b"`},
+		{"a BETWEEN 1 2", 12, `expected AND, found "2"`},
+		{"a BETWEEN 1 OR 2", 12, `expected AND, found "OR"`},
+		{"a IN 1", 5, `expected ( after IN, found "1"`},
+		{"a IN (1 2)", 8, `expected , or ), found "2"`},
+		{"a IN ()", 6, `expected a column or a value, found ")"`},
+		{"a NOT = 1", 6, `expected BETWEEN, IN, LIKE or REGEXP after NOT, found "="`},
+		{"a IS 1", 5, `expected NULL, found "1"`},
+		{"a LIKE b", 7, `expected a quoted pattern, found "b"`},
+		{"a ~ 1", 4, `expected a quoted pattern, found "1"`},
+		{"1 2", 2, `expected an operator, found "2"`},
+		{"a.", 2, "expected a column name after ., found end of expression"},
+		{"a.1 = 2", 1, `unexpected ".1"`},
+		{"a # 1", 2, `unexpected character '#'`},
+		{"a AND", 5, "expected a column or a value, found end of expression"},
+		{"NOT", 3, "expected a column or a value, found end of expression"},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.expr)
+		var se *SyntaxError
+		if !errors.As(err, &se) {
+			t.Errorf("%q: got %v, want a syntax error", tt.expr, err)
+			continue
+		}
+		if se.Pos != tt.pos || !strings.Contains(se.Msg, tt.msg) {
+			t.Errorf("%q: got %q at %d, want %q at %d", tt.expr, se.Msg, se.Pos, tt.msg, tt.pos)
+		}
+	}
+}
@@ -0,0 +1,369 @@
+package reader
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/apache/arrow/go/v17/parquet"
+	"github.com/jimyag/log"
+)
+
+const DefaultCacheBlockSize = 1 << 20
+
+var (
+	_ parquet.ReaderAtSeeker = (*CachedReader)(nil)
+	_ Prefetcher             = (*CachedReader)(nil)
+	_ Private                = (*S3Reader)(nil)
+)
+
+// Cacheable is implemented by remote readers that can tell which version
+// of an object they read, so cached blocks of an overwritten object are
+// never served.
+type Cacheable interface {
+	CacheKey() string
+}
+
+// Private is implemented by remote readers of objects whose plaintext
+// must not be written to disk, such as SSE-C encrypted S3 objects. Their
+// blocks are only cached in memory.
+type Private interface {
+	Private() bool
+}
+
+// CacheKey builds a cache key from an object URL and its validators. It
+// returns an empty key when there is no validator, such objects must not be
+// cached.
+func CacheKey(uri, etag string, modTime time.Time) string {
+	if etag == "" && modTime.IsZero() {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\n%s\n%d", uri, etag, modTime.UnixNano())))
+	return hex.EncodeToString(sum[:])
+}
+
+type CacheOptions struct {
+	// BlockSize is the size of the cached blocks.
+	BlockSize int64
+	// MemorySize bounds the bytes kept in the in-memory LRU.
+	MemorySize int64
+	// Dir enables the on-disk tier when not empty.
+	Dir string
+	// DiskSize bounds the bytes kept in Dir, the oldest blocks are removed
+	// first.
+	DiskSize int64
+}
+
+type blockKey struct {
+	key   string
+	index int64
+}
+
+type cacheEntry struct {
+	blockKey
+	data []byte
+}
+
+// BlockCache keeps fixed-size blocks of remote files in a memory LRU and
+// optionally on disk. It is safe for concurrent use.
+type BlockCache struct {
+	opts CacheOptions
+
+	mu    sync.Mutex
+	used  int64
+	lru   *list.List
+	items map[blockKey]*list.Element
+}
+
+func NewBlockCache(opts CacheOptions) *BlockCache {
+	if opts.BlockSize <= 0 {
+		opts.BlockSize = DefaultCacheBlockSize
+	}
+	return &BlockCache{
+		opts:  opts,
+		lru:   list.New(),
+		items: make(map[blockKey]*list.Element),
+	}
+}
+
+// ClearCacheDir removes every block stored in dir.
+func ClearCacheDir(dir string) error {
+	return os.RemoveAll(dir)
+}
+
+// get returns a cached block of length bytes, from disk as well when disk
+// is set. The disk tier is shared and can be edited, a block file of
+// another length is a miss and is removed.
+func (c *BlockCache) get(k blockKey, length int64, disk bool) ([]byte, bool) {
+	c.mu.Lock()
+	if e, ok := c.items[k]; ok {
+		data := e.Value.(*cacheEntry).data
+		if int64(len(data)) == length {
+			c.lru.MoveToFront(e)
+			c.mu.Unlock()
+			return data, true
+		}
+	}
+	c.mu.Unlock()
+
+	if c.opts.Dir == "" || !disk {
+		return nil, false
+	}
+	path := c.blockPath(k)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	if int64(len(data)) != length {
+		log.Warn().Msgf("removing cached block %s of %d bytes, want %d", path, len(data), length)
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Warn(err).Msg("error removing cached block")
+		}
+		return nil, false
+	}
+	c.putMemory(k, data)
+	return data, true
+}
+
+// put caches a block, on disk as well when disk is set.
+func (c *BlockCache) put(k blockKey, data []byte, disk bool) {
+	c.putMemory(k, data)
+	if c.opts.Dir == "" || !disk {
+		return
+	}
+	if err := c.putDisk(k, data); err != nil {
+		log.Warn(err).Msg("error writing block to disk cache")
+	}
+}
+
+func (c *BlockCache) putMemory(k blockKey, data []byte) {
+	if int64(len(data)) > c.opts.MemorySize {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[k]; ok {
+		entry := e.Value.(*cacheEntry)
+		if len(entry.data) == len(data) {
+			c.lru.MoveToFront(e)
+			return
+		}
+		c.lru.Remove(e)
+		c.used -= int64(len(entry.data))
+	}
+	c.items[k] = c.lru.PushFront(&cacheEntry{blockKey: k, data: data})
+	c.used += int64(len(data))
+	for c.used > c.opts.MemorySize {
+		e := c.lru.Back()
+		entry := e.Value.(*cacheEntry)
+		c.lru.Remove(e)
+		delete(c.items, entry.blockKey)
+		c.used -= int64(len(entry.data))
+	}
+}
+
+func (c *BlockCache) blockPath(k blockKey) string {
+	return filepath.Join(c.opts.Dir, k.key[:2], fmt.Sprintf("%s-%d-%d", k.key, c.opts.BlockSize, k.index))
+}
+
+// putDisk writes the block to a temporary file and renames it, so
+// concurrent invocations never see partial blocks.
+func (c *BlockCache) putDisk(k blockKey, data []byte) error {
+	path := c.blockPath(k)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".block-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// Trim removes the oldest blocks from the disk tier until it fits in
+// DiskSize.
+func (c *BlockCache) Trim() error {
+	if c.opts.Dir == "" || c.opts.DiskSize <= 0 {
+		return nil
+	}
+	type block struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var blocks []block
+	var total int64
+	err := filepath.WalkDir(c.opts.Dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		blocks = append(blocks, block{path: path, size: info.Size(), modTime: info.ModTime()})
+		total += info.Size()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Slice(blocks, func(i, j int) bool {
+		return blocks[i].modTime.Before(blocks[j].modTime)
+	})
+	for _, b := range blocks {
+		if total <= c.opts.DiskSize {
+			break
+		}
+		if err := os.Remove(b.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		total -= b.size
+	}
+	return nil
+}
+
+// CachedReader serves reads of a remote file from a BlockCache and only
+// asks the underlying reader for missing blocks.
+type CachedReader struct {
+	src   parquet.ReaderAtSeeker
+	size  int64
+	key   string
+	cache *BlockCache
+	// disk is unset for Private sources, which only use the memory tier.
+	disk   bool
+	offset int64
+}
+
+// NewCachedReader caches the blocks of src under key. Blocks of a Private
+// src are never written to disk.
+func NewCachedReader(src parquet.ReaderAtSeeker, size int64, key string, cache *BlockCache, private bool) *CachedReader {
+	return &CachedReader{
+		src:   src,
+		size:  size,
+		key:   key,
+		cache: cache,
+		disk:  !private,
+	}
+}
+
+func (r *CachedReader) ReadAt(p []byte, off int64) (int, error) {
+	if off >= r.size {
+		return 0, io.EOF
+	}
+	blockSize := r.cache.opts.BlockSize
+	n := 0
+	for n < len(p) && off+int64(n) < r.size {
+		pos := off + int64(n)
+		block, err := r.block(pos / blockSize)
+		if err != nil {
+			return n, err
+		}
+		n += copy(p[n:], block[pos%blockSize:])
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// blockLength returns the length of a block, shorter for the last one.
+func (r *CachedReader) blockLength(index int64) int64 {
+	return min(r.cache.opts.BlockSize, r.size-index*r.cache.opts.BlockSize)
+}
+
+func (r *CachedReader) block(index int64) ([]byte, error) {
+	k := blockKey{key: r.key, index: index}
+	length := r.blockLength(index)
+	if data, ok := r.cache.get(k, length, r.disk); ok {
+		return data, nil
+	}
+	start := index * r.cache.opts.BlockSize
+	data := make([]byte, length)
+	n, err := r.src.ReadAt(data, start)
+	if n < len(data) {
+		// a short block is never cached, it would be served zero padded
+		if err == nil || errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, fmt.Errorf("error reading block %d: %w", index, err)
+	}
+	r.cache.put(k, data, r.disk)
+	return data, nil
+}
+
+// Prefetch widens the ranges to whole blocks, drops the ones already
+// cached and hands the rest to the underlying reader when it supports
+// prefetching.
+func (r *CachedReader) Prefetch(ranges []Range, opts PrefetchOptions) error {
+	p, ok := r.src.(Prefetcher)
+	if !ok {
+		return nil
+	}
+	blockSize := r.cache.opts.BlockSize
+	var missing []Range
+	for _, rg := range ranges {
+		for index := rg.Offset / blockSize; index*blockSize < min(rg.End(), r.size); index++ {
+			if _, ok := r.cache.get(blockKey{key: r.key, index: index}, r.blockLength(index), r.disk); ok {
+				continue
+			}
+			missing = append(missing, Range{Offset: index * blockSize, Length: blockSize})
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return p.Prefetch(missing, opts)
+}
+
+// Close closes the underlying reader when it can be closed.
+func (r *CachedReader) Close() error {
+	if c, ok := r.src.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+func (r *CachedReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.New("reader.CachedReader.Seek: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("reader.CachedReader.Seek: negative position")
+	}
+	r.offset = offset
+	return r.offset, nil
+}
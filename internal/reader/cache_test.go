@@ -0,0 +1,147 @@
+package reader
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// countingReader counts the reads of a source and can cut them short.
+type countingReader struct {
+	*bytes.Reader
+	reads int
+	short bool
+}
+
+func (r *countingReader) ReadAt(p []byte, off int64) (int, error) {
+	r.reads++
+	if r.short {
+		p = p[:len(p)-1]
+	}
+	return r.Reader.ReadAt(p, off)
+}
+
+func testData(n int) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	return data
+}
+
+func TestCachedReader(t *testing.T) {
+	data := testData(25)
+	cache := NewBlockCache(CacheOptions{BlockSize: 10, MemorySize: 100})
+	src := &countingReader{Reader: bytes.NewReader(data)}
+	r := NewCachedReader(src, int64(len(data)), "k", cache, false)
+	for _, off := range []int64{0, 5, 18, 24, 3} {
+		p := make([]byte, 6)
+		n, err := r.ReadAt(p, off)
+		want := data[off:min(off+6, int64(len(data)))]
+		if !bytes.Equal(p[:n], want) {
+			t.Errorf("offset %d: got %v, want %v", off, p[:n], want)
+		}
+		if n < len(p) && err != io.EOF {
+			t.Errorf("offset %d: got %v for a short read, want EOF", off, err)
+		}
+	}
+	if src.reads != 3 {
+		t.Errorf("got %d source reads for 3 blocks", src.reads)
+	}
+	if _, err := r.ReadAt(make([]byte, 1), 25); err != io.EOF {
+		t.Errorf("got %v past the end, want EOF", err)
+	}
+}
+
+func TestCachedReaderShortSource(t *testing.T) {
+	data := testData(25)
+	cache := NewBlockCache(CacheOptions{BlockSize: 10, MemorySize: 100})
+	src := &countingReader{Reader: bytes.NewReader(data), short: true}
+	r := NewCachedReader(src, int64(len(data)), "k", cache, false)
+	if _, err := r.ReadAt(make([]byte, 4), 0); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("got %v, want ErrUnexpectedEOF", err)
+	}
+	// the short block was not cached
+	src.short = false
+	p := make([]byte, 4)
+	if _, err := r.ReadAt(p, 0); err != nil || !bytes.Equal(p, data[:4]) {
+		t.Errorf("got %v %v, want %v", p, err, data[:4])
+	}
+}
+
+func TestCachedReaderDisk(t *testing.T) {
+	data := testData(25)
+	opts := CacheOptions{BlockSize: 10, MemorySize: 100, Dir: t.TempDir()}
+	read := func(private bool) *countingReader {
+		t.Helper()
+		src := &countingReader{Reader: bytes.NewReader(data)}
+		r := NewCachedReader(src, int64(len(data)), "0123", NewBlockCache(opts), private)
+		p := make([]byte, len(data))
+		if _, err := r.ReadAt(p, 0); err != nil || !bytes.Equal(p, data) {
+			t.Fatalf("got %v %v, want %v", p, err, data)
+		}
+		return src
+	}
+	if src := read(false); src.reads != 3 {
+		t.Fatalf("got %d source reads, want 3", src.reads)
+	}
+	blocks, _ := filepath.Glob(filepath.Join(opts.Dir, "01", "*"))
+	if len(blocks) != 3 {
+		t.Fatalf("got %d blocks on disk, want 3", len(blocks))
+	}
+	if src := read(false); src.reads != 0 {
+		t.Errorf("got %d source reads with a warm disk tier", src.reads)
+	}
+
+	// truncated, padded and emptied blocks are misses and are rewritten
+	last := filepath.Join(opts.Dir, "01", "0123-10-2")
+	for _, corrupt := range [][]byte{data[20:22], append(data[20:25:25], 0), {}} {
+		if err := os.WriteFile(last, corrupt, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if src := read(false); src.reads != 1 {
+			t.Errorf("%d byte block: got %d source reads, want 1", len(corrupt), src.reads)
+		}
+		if b, err := os.ReadFile(last); err != nil || !bytes.Equal(b, data[20:]) {
+			t.Errorf("%d byte block: got %v %v after the read, want %v", len(corrupt), b, err, data[20:])
+		}
+	}
+
+	// private readers neither read nor write the disk tier
+	if err := os.RemoveAll(opts.Dir); err != nil {
+		t.Fatal(err)
+	}
+	if src := read(true); src.reads != 3 {
+		t.Errorf("got %d source reads, want 3", src.reads)
+	}
+	if _, err := os.Stat(opts.Dir); !os.IsNotExist(err) {
+		t.Errorf("private blocks written to disk: %v", err)
+	}
+}
+
+func TestBlockCacheTrim(t *testing.T) {
+	opts := CacheOptions{BlockSize: 10, Dir: t.TempDir(), DiskSize: 25}
+	c := NewBlockCache(opts)
+	for i := range 4 {
+		c.put(blockKey{key: "abcd", index: int64(i)}, testData(10), true)
+		// make the modification times increase with the index
+		path := c.blockPath(blockKey{key: "abcd", index: int64(i)})
+		mtime := time.Unix(int64(1000+i), 0)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Trim(); err != nil {
+		t.Fatal(err)
+	}
+	for i := range 4 {
+		_, err := os.Stat(c.blockPath(blockKey{key: "abcd", index: int64(i)}))
+		if kept := err == nil; kept != (i >= 2) {
+			t.Errorf("block %d: kept %v", i, kept)
+		}
+	}
+}
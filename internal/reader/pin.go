@@ -0,0 +1,47 @@
+package reader
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrObjectChanged is returned when a remote object is overwritten while
+// it is read. Readers pin the version they saw when opening the object,
+// so a read never mixes bytes of two versions.
+var ErrObjectChanged = errors.New("object changed while reading")
+
+func objectChanged(uri, etag string) error {
+	if etag == "" {
+		return fmt.Errorf("%w: %s", ErrObjectChanged, uri)
+	}
+	return fmt.Errorf("%w: %s no longer has ETag %s", ErrObjectChanged, uri, etag)
+}
+
+// pinRequest makes req fail with 412 Precondition Failed when the object
+// no longer has the given ETag. Weak ETags can't be used with If-Match,
+// the modification time is used for them instead.
+func pinRequest(req *http.Request, etag string, modTime time.Time) {
+	switch {
+	case etag != "" && !strings.HasPrefix(etag, "W/"):
+		req.Header.Set("If-Match", etag)
+	case !modTime.IsZero():
+		req.Header.Set("If-Unmodified-Since", modTime.UTC().Format(http.TimeFormat))
+	}
+}
+
+// checkPinned reports ErrObjectChanged for a 412 answer to a pinned
+// request, and for servers that ignore If-Match but send a different
+// strong ETag.
+func checkPinned(resp *http.Response, uri, etag string) error {
+	if resp.StatusCode == http.StatusPreconditionFailed {
+		return objectChanged(uri, etag)
+	}
+	got := resp.Header.Get("ETag")
+	if etag != "" && got != "" && !strings.HasPrefix(etag, "W/") && !strings.HasPrefix(got, "W/") && got != etag {
+		return objectChanged(uri, etag)
+	}
+	return nil
+}
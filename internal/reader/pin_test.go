@@ -0,0 +1,117 @@
+package reader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPinRequest(t *testing.T) {
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("", 3600))
+	tests := []struct {
+		etag             string
+		modTime          time.Time
+		ifMatch, ifUnmod string
+	}{
+		{`"v1"`, modTime, `"v1"`, ""},
+		{`W/"v1"`, modTime, "", "Tue, 02 Jan 2024 02:04:05 GMT"},
+		{`W/"v1"`, time.Time{}, "", ""},
+		{"", modTime, "", "Tue, 02 Jan 2024 02:04:05 GMT"},
+		{"", time.Time{}, "", ""},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodGet, "http://h/f", nil)
+		pinRequest(req, tt.etag, tt.modTime)
+		if got := req.Header.Get("If-Match"); got != tt.ifMatch {
+			t.Errorf("%q %v: got If-Match %q, want %q", tt.etag, tt.modTime, got, tt.ifMatch)
+		}
+		if got := req.Header.Get("If-Unmodified-Since"); got != tt.ifUnmod {
+			t.Errorf("%q %v: got If-Unmodified-Since %q, want %q", tt.etag, tt.modTime, got, tt.ifUnmod)
+		}
+	}
+}
+
+func TestCheckPinned(t *testing.T) {
+	tests := []struct {
+		status    int
+		etag, got string
+		changed   bool
+	}{
+		{200, `"v1"`, `"v1"`, false},
+		{206, `"v1"`, `"v2"`, true},
+		{412, `"v1"`, "", true},
+		{412, "", "", true},
+		{206, `"v1"`, "", false},
+		{206, "", `"v2"`, false},
+		// weak ETags may change with the encoding of the same bytes
+		{206, `W/"v1"`, `W/"v2"`, false},
+		{206, `"v1"`, `W/"v1"`, false},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{StatusCode: tt.status, Header: http.Header{}}
+		if tt.got != "" {
+			resp.Header.Set("ETag", tt.got)
+		}
+		err := checkPinned(resp, "http://h/f", tt.etag)
+		if changed := errors.Is(err, ErrObjectChanged); changed != tt.changed {
+			t.Errorf("%d %q -> %q: got %v", tt.status, tt.etag, tt.got, err)
+		}
+	}
+}
+
+// TestHttpReaderChanged checks that a file overwritten after it was
+// opened fails the reads instead of mixing two versions.
+func TestHttpReaderChanged(t *testing.T) {
+	fastRetry(t, 3)
+	for _, ignore := range []bool{false, true} {
+		o := &httpObject{data: testData(100), etag: `"v1"`, ignoreIfMatch: ignore}
+		srv := o.serve(t)
+		r, err := NewHttpReader(context.Background(), srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o.log()
+		o.etag = `"v2"`
+		_, err = r.ReadAt(make([]byte, 10), 10)
+		if !errors.Is(err, ErrObjectChanged) || !strings.Contains(err.Error(), `no longer has ETag "v1"`) {
+			t.Errorf("ignoreIfMatch=%v: got %v, want ErrObjectChanged", ignore, err)
+		}
+		// a changed object is not retried
+		if n := len(o.log()); n != 1 {
+			t.Errorf("ignoreIfMatch=%v: got %d requests, want 1", ignore, n)
+		}
+	}
+}
+
+func TestHttpReaderPinning(t *testing.T) {
+	fastRetry(t, 1)
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	var got []http.Header
+	var mu sync.Mutex
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		got = append(got, r.Header.Clone())
+		mu.Unlock()
+		w.Header().Set("ETag", `W/"weak"`)
+		http.ServeContent(w, r, "f", modTime, bytes.NewReader(testData(100)))
+	}))
+	defer srv.Close()
+	r, err := NewHttpReader(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.ReadAt(make([]byte, 10), 0); err != nil {
+		t.Fatal(err)
+	}
+	// a weak ETag cannot be used with If-Match
+	h := got[len(got)-1]
+	if h.Get("If-Match") != "" || h.Get("If-Unmodified-Since") != modTime.Format(http.TimeFormat) {
+		t.Errorf("got If-Match %q and If-Unmodified-Since %q", h.Get("If-Match"), h.Get("If-Unmodified-Since"))
+	}
+}
@@ -0,0 +1,189 @@
+package reader
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/apache/arrow/go/v17/parquet/metadata"
+)
+
+// maxDictHeaderSize mirrors the padding arrow adds after a column chunk to
+// work around writers that did not include the dictionary page header in
+// the chunk size, so prefetched buffers cover the whole read. Readers clamp
+// the padded ranges to the file size.
+const maxDictHeaderSize = 100
+
+// Range is a byte range [Offset, Offset+Length) of a file.
+type Range struct {
+	Offset int64
+	Length int64
+}
+
+func (r Range) End() int64 {
+	return r.Offset + r.Length
+}
+
+// PrefetchOptions controls how ranges are merged and fetched.
+type PrefetchOptions struct {
+	// MaxGap is the largest hole between two ranges that is read
+	// (and thrown away) to merge them into a single request.
+	MaxGap int64
+	// Workers bounds the number of concurrent requests.
+	Workers int
+	// Fetched, when set, is called after each request with the merged
+	// range requested and the number of bytes received.
+	Fetched func(r Range, n int64, start time.Time, err error)
+}
+
+var DefaultPrefetchOptions = PrefetchOptions{
+	MaxGap:  1 << 20,
+	Workers: 8,
+}
+
+// Prefetcher is implemented by remote readers that can fetch byte ranges
+// ahead of decoding and serve later ReadAt calls from memory.
+type Prefetcher interface {
+	Prefetch(ranges []Range, opts PrefetchOptions) error
+}
+
+// ColumnChunkRanges returns the byte ranges of the given columns of a row
+// group, all columns when cols is nil.
+func ColumnChunkRanges(rg *metadata.RowGroupMetaData, cols []int) ([]Range, error) {
+	if cols == nil {
+		cols = make([]int, rg.NumColumns())
+		for i := range cols {
+			cols[i] = i
+		}
+	}
+	ranges := make([]Range, 0, len(cols))
+	for _, c := range cols {
+		chunk, err := rg.ColumnChunk(c)
+		if err != nil {
+			return nil, err
+		}
+		start := chunk.DataPageOffset()
+		if chunk.HasDictionaryPage() && chunk.DictionaryPageOffset() > 0 && start > chunk.DictionaryPageOffset() {
+			start = chunk.DictionaryPageOffset()
+		}
+		length := chunk.TotalCompressedSize()
+		if start < 0 || length < 0 {
+			return nil, fmt.Errorf("invalid column chunk metadata, offset (%d) and length (%d)", start, length)
+		}
+		ranges = append(ranges, Range{Offset: start, Length: length + maxDictHeaderSize})
+	}
+	return ranges, nil
+}
+
+// MergeRanges sorts ranges and coalesces the ones that overlap or are at
+// most maxGap bytes apart.
+func MergeRanges(ranges []Range, maxGap int64) []Range {
+	if len(ranges) == 0 {
+		return nil
+	}
+	sorted := make([]Range, len(ranges))
+	copy(sorted, ranges)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Offset < sorted[j].Offset
+	})
+	merged := []Range{sorted[0]}
+	for _, r := range sorted[1:] {
+		last := &merged[len(merged)-1]
+		if r.Offset <= last.End()+maxGap {
+			if r.End() > last.End() {
+				last.Length = r.End() - last.Offset
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+type prefetchedRange struct {
+	Range
+	data []byte
+}
+
+// prefetcher holds the buffers fetched by the last Prefetch call. Each call
+// replaces the previous buffers, so memory stays bounded by what one plan
+// (usually one row group) needs.
+type prefetcher struct {
+	size  int64
+	fetch func(off, length int64) ([]byte, error)
+
+	mu   sync.RWMutex
+	bufs []prefetchedRange
+}
+
+func newPrefetcher(size int64, fetch func(off, length int64) ([]byte, error)) *prefetcher {
+	return &prefetcher{size: size, fetch: fetch}
+}
+
+func (p *prefetcher) Prefetch(ranges []Range, opts PrefetchOptions) error {
+	// ranges are clamped to the file, the ones past its end come from
+	// bad metadata and are dropped
+	var planned []Range
+	for _, r := range MergeRanges(ranges, opts.MaxGap) {
+		if r.Offset >= p.size || r.Length <= 0 {
+			continue
+		}
+		r.Length = min(r.Length, p.size-r.Offset)
+		planned = append(planned, r)
+	}
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+
+	bufs := make([]prefetchedRange, len(planned))
+	errs := make([]error, len(planned))
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for i, r := range planned {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, r Range) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			start := time.Now()
+			data, err := p.fetch(r.Offset, r.Length)
+			if opts.Fetched != nil {
+				n := int64(len(data))
+				if err != nil {
+					n = 0
+				}
+				opts.Fetched(r, n, start, err)
+			}
+			bufs[i] = prefetchedRange{Range: r, data: data}
+			errs[i] = err
+		}(i, r)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	p.mu.Lock()
+	p.bufs = bufs
+	p.mu.Unlock()
+	return nil
+}
+
+// readAt copies from a prefetched buffer when one fully covers the request.
+func (p *prefetcher) readAt(b []byte, off int64) (int, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	end := off + int64(len(b))
+	i := sort.Search(len(p.bufs), func(i int) bool {
+		return p.bufs[i].End() >= end
+	})
+	if i == len(p.bufs) || p.bufs[i].Offset > off || int64(len(p.bufs[i].data)) < p.bufs[i].Length {
+		return 0, false
+	}
+	buf := p.bufs[i]
+	return copy(b, buf.data[off-buf.Offset:]), true
+}
@@ -0,0 +1,178 @@
+package reader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMergeRanges(t *testing.T) {
+	tests := []struct {
+		in     []Range
+		maxGap int64
+		want   []Range
+	}{
+		{nil, 10, nil},
+		{[]Range{{0, 10}}, 0, []Range{{0, 10}}},
+		// sorted, merged when they touch, overlap or are close enough
+		{[]Range{{20, 5}, {0, 10}, {10, 5}}, 0, []Range{{0, 15}, {20, 5}}},
+		{[]Range{{20, 5}, {0, 10}, {10, 5}}, 5, []Range{{0, 25}}},
+		{[]Range{{0, 10}, {16, 4}}, 5, []Range{{0, 10}, {16, 4}}},
+		{[]Range{{0, 100}, {10, 5}, {50, 60}}, 0, []Range{{0, 110}}},
+		{[]Range{{5, 5}, {5, 10}, {5, 1}}, 0, []Range{{5, 10}}},
+	}
+	for _, tt := range tests {
+		in := append([]Range(nil), tt.in...)
+		if got := MergeRanges(tt.in, tt.maxGap); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v gap %d: got %v, want %v", tt.in, tt.maxGap, got, tt.want)
+		}
+		if !reflect.DeepEqual(in, tt.in) {
+			t.Errorf("%v: the input was modified", in)
+		}
+	}
+}
+
+func TestPrefetcher(t *testing.T) {
+	data := testData(1000)
+	var mu sync.Mutex
+	var fetches []Range
+	short := false
+	p := newPrefetcher(int64(len(data)), func(off, length int64) ([]byte, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		fetches = append(fetches, Range{off, length})
+		if off+length > int64(len(data)) {
+			return nil, errors.New("read past the end")
+		}
+		if short {
+			length--
+		}
+		return bytes.Clone(data[off : off+length]), nil
+	})
+	// the last range is clamped to the file
+	if err := p.Prefetch([]Range{{100, 50}, {0, 50}, {990, 100}}, PrefetchOptions{Workers: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []Range{{0, 50}, {100, 50}, {990, 10}}; !reflect.DeepEqual(fetches, want) {
+		t.Errorf("got fetches %v, want %v", fetches, want)
+	}
+	tests := []struct {
+		off, length int64
+		ok          bool
+	}{
+		{0, 50, true},
+		{10, 10, true},
+		{100, 50, true},
+		{995, 5, true},
+		{40, 20, false},
+		{50, 10, false},
+		{90, 20, false},
+		{140, 20, false},
+		{0, 150, false},
+	}
+	for _, tt := range tests {
+		b := make([]byte, tt.length)
+		n, ok := p.readAt(b, tt.off)
+		if ok != tt.ok {
+			t.Errorf("%d+%d: got %v, want %v", tt.off, tt.length, ok, tt.ok)
+		}
+		if ok && (n != int(tt.length) || !bytes.Equal(b, data[tt.off:tt.off+tt.length])) {
+			t.Errorf("%d+%d: got %d bytes %v", tt.off, tt.length, n, b)
+		}
+	}
+
+	// a new plan replaces the buffers, a short one never serves reads
+	short = true
+	if err := p.Prefetch([]Range{{500, 10}}, PrefetchOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, off := range []int64{0, 500} {
+		if _, ok := p.readAt(make([]byte, 5), off); ok {
+			t.Errorf("%d: served from a replaced or short buffer", off)
+		}
+	}
+
+	// ranges past the end are not fetched
+	short = false
+	fetches = nil
+	if err := p.Prefetch([]Range{{0, 10}, {2000, 10}, {20, 0}}, PrefetchOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []Range{{0, 10}}; !reflect.DeepEqual(fetches, want) {
+		t.Errorf("got fetches %v, want %v", fetches, want)
+	}
+}
+
+func TestPrefetcherError(t *testing.T) {
+	fail := errors.New("fail")
+	p := newPrefetcher(100, func(off, length int64) ([]byte, error) {
+		if off > 0 {
+			return nil, fail
+		}
+		return make([]byte, length), nil
+	})
+	if err := p.Prefetch([]Range{{0, 10}}, PrefetchOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	// a failed plan does not replace the buffers of the last one
+	if err := p.Prefetch([]Range{{0, 20}, {50, 10}}, PrefetchOptions{}); !errors.Is(err, fail) {
+		t.Errorf("got %v, want the fetch error", err)
+	}
+	if _, ok := p.readAt(make([]byte, 5), 0); !ok {
+		t.Error("the buffers of the last plan were dropped")
+	}
+	if _, ok := p.readAt(make([]byte, 5), 12); ok {
+		t.Error("served from the failed plan")
+	}
+}
+
+func TestHttpReaderPrefetch(t *testing.T) {
+	fastRetry(t, 1)
+	o := &httpObject{data: testData(10000)}
+	srv := o.serve(t)
+	r, err := NewHttpReader(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.log()
+	var fetched []Range
+	var mu sync.Mutex
+	opts := PrefetchOptions{MaxGap: 100, Workers: 2, Fetched: func(r Range, n int64, _ time.Time, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil || n != r.Length {
+			t.Errorf("fetched %v: got %d bytes and %v", r, n, err)
+		}
+		fetched = append(fetched, r)
+	}}
+	if err := r.Prefetch([]Range{{5000, 100}, {1000, 100}, {1150, 50}, {9950, 200}}, opts); err != nil {
+		t.Fatal(err)
+	}
+	reqs := o.log()
+	slices.Sort(reqs)
+	if want := []string{"GET bytes=1000-1199", "GET bytes=5000-5099", "GET bytes=9950-9999"}; !slices.Equal(reqs, want) {
+		t.Errorf("got requests %q, want %q", reqs, want)
+	}
+	if len(fetched) != 3 {
+		t.Errorf("got %d Fetched calls, want 3", len(fetched))
+	}
+
+	// reads inside a buffer are served from memory, the others are not
+	for _, rg := range []Range{{1000, 200}, {1120, 10}, {5050, 50}, {9990, 10}} {
+		p := make([]byte, rg.Length)
+		if _, err := r.ReadAt(p, rg.Offset); err != nil || !bytes.Equal(p, o.data[rg.Offset:rg.End()]) {
+			t.Errorf("%v: got %v", rg, err)
+		}
+	}
+	checkLog(t, o)
+	p := make([]byte, 20)
+	if _, err := r.ReadAt(p, 5090); err != nil || !bytes.Equal(p, o.data[5090:5110]) {
+		t.Errorf("got %v", err)
+	}
+	checkLog(t, o, "GET bytes=5090-5109")
+}
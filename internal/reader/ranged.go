@@ -0,0 +1,100 @@
+package reader
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// rangedReader implements parquet.ReaderAtSeeker on top of ranged GET
+// requests. It is embedded by the readers of HTTP based object stores,
+// which only have to stat the object and build the requests.
+type rangedReader struct {
+	ctx      context.Context
+	size     int64
+	offset   int64
+	prefetch *prefetcher
+	// rangeRequest issues a GET for [off, off+length) and returns the
+	// response when its status is 200 or 206.
+	rangeRequest func(ctx context.Context, off, length int64) (*http.Response, error)
+}
+
+func newRangedReader(ctx context.Context, size int64, rangeRequest func(ctx context.Context, off, length int64) (*http.Response, error)) *rangedReader {
+	r := &rangedReader{
+		ctx:          ctx,
+		size:         size,
+		rangeRequest: rangeRequest,
+	}
+	r.prefetch = newPrefetcher(size, r.fetch)
+	return r
+}
+
+func (r *rangedReader) ReadAt(p []byte, off int64) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if off >= r.size {
+		return 0, io.EOF
+	}
+	count := min(int64(len(p)), r.size-off)
+	n, ok := r.prefetch.readAt(p[:count], off)
+	if !ok {
+		if err := r.read(p[:count], off); err != nil {
+			return 0, err
+		}
+		n = int(count)
+	}
+	if count < int64(len(p)) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Prefetch fetches the given ranges concurrently, later ReadAt calls
+// inside them are served from memory.
+func (r *rangedReader) Prefetch(ranges []Range, opts PrefetchOptions) error {
+	return r.prefetch.Prefetch(ranges, opts)
+}
+
+func (r *rangedReader) fetch(off, length int64) ([]byte, error) {
+	data := make([]byte, length)
+	if err := r.read(data, off); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// read fills p from off, retrying failed requests and truncated bodies.
+func (r *rangedReader) read(p []byte, off int64) error {
+	return retry.do(r.ctx, func(ctx context.Context) error {
+		resp, err := r.rangeRequest(ctx, off, int64(len(p)))
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		_, err = readRangeBody(resp, p, off)
+		return err
+	})
+}
+
+func (r *rangedReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.New("reader.Seek: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("reader.Seek: negative position")
+	}
+	r.offset = offset
+	return r.offset, nil
+}
+
+func (r *rangedReader) Size() (int64, error) {
+	return r.size, nil
+}
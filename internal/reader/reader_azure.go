@@ -0,0 +1,236 @@
+package reader
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow/go/v17/parquet"
+)
+
+const azureAPIVersion = "2021-08-06"
+
+var (
+	_                   parquet.ReaderAtSeeker = (*AzureReader)(nil)
+	_                   Prefetcher             = (*AzureReader)(nil)
+	_                   Cacheable              = (*AzureReader)(nil)
+	ErrInvalidAzurePath                        = errors.New("path is not a valid azure blob path")
+)
+
+// AzurePath is a blob location parsed from an abfs[s]://, wasb[s]:// or
+// az:// URL. Account is empty for az:// URLs, it comes from the config.
+type AzurePath struct {
+	Account   string
+	Container string
+	Blob      string
+}
+
+// ParseAzurePath accepts
+//
+//	abfs[s]://container@account.dfs.core.windows.net/path
+//	wasb[s]://container@account.blob.core.windows.net/path
+//	az://container/path
+func ParseAzurePath(path string) (AzurePath, error) {
+	u, err := url.Parse(path)
+	if err != nil {
+		return AzurePath{}, err
+	}
+	p := AzurePath{Blob: strings.TrimPrefix(u.Path, "/")}
+	switch u.Scheme {
+	case "abfs", "abfss", "wasb", "wasbs":
+		if u.User == nil || u.User.Username() == "" {
+			return AzurePath{}, ErrInvalidAzurePath
+		}
+		p.Container = u.User.Username()
+		p.Account, _, _ = strings.Cut(u.Hostname(), ".")
+	case "az":
+		p.Container = u.Host
+	default:
+		return AzurePath{}, ErrInvalidAzurePath
+	}
+	if p.Container == "" || p.Blob == "" {
+		return AzurePath{}, ErrInvalidAzurePath
+	}
+	return p, nil
+}
+
+// AzureCredentials authenticates blob requests with either a shared
+// account key or a SAS token. Both empty means anonymous access.
+type AzureCredentials struct {
+	// AccountKey is the base64 encoded shared key.
+	AccountKey string
+	SASToken   string
+}
+
+// AzureReader reads a blob through the Blob REST API with ranged GETs.
+// ADLS Gen2 accounts are read through their blob endpoint as well.
+type AzureReader struct {
+	*rangedReader
+
+	ctx     context.Context
+	client  *http.Client
+	path    AzurePath
+	blobURL string
+	key     []byte
+	sas     string
+
+	etag    string
+	modTime time.Time
+}
+
+// NewAzureReader stats the blob and returns a reader for it. endpoint
+// defaults to https://<account>.blob.core.windows.net, set it to
+// http://127.0.0.1:10000/devstoreaccount1 for Azurite.
+func NewAzureReader(ctx context.Context, path AzurePath, endpoint string, creds AzureCredentials) (*AzureReader, error) {
+	if path.Account == "" {
+		return nil, fmt.Errorf("no azure storage account for container %s", path.Container)
+	}
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s.blob.core.windows.net", path.Account)
+	}
+	r := &AzureReader{
+		ctx:    ctx,
+		client: httpClient,
+		path:   path,
+		sas:    strings.TrimPrefix(creds.SASToken, "?"),
+	}
+	blob := &url.URL{Path: "/" + path.Container + "/" + path.Blob}
+	r.blobURL = strings.TrimSuffix(endpoint, "/") + blob.EscapedPath()
+	if creds.AccountKey != "" {
+		key, err := base64.StdEncoding.DecodeString(creds.AccountKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid azure account key: %w", err)
+		}
+		r.key = key
+	}
+
+	size, err := r.stat()
+	if err != nil {
+		return nil, err
+	}
+	r.rangedReader = newRangedReader(ctx, size, r.rangeRequest)
+	return r, nil
+}
+
+func (r *AzureReader) newRequest(ctx context.Context, method string) (*http.Request, error) {
+	rawURL := r.blobURL
+	if r.sas != "" {
+		rawURL += "?" + r.sas
+	}
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-ms-version", azureAPIVersion)
+	req.Header.Set("x-ms-date", time.Now().UTC().Format(http.TimeFormat))
+	return req, nil
+}
+
+func (r *AzureReader) do(req *http.Request) (*http.Response, error) {
+	if r.key != nil && r.sas == "" {
+		r.sign(req)
+	}
+	return r.client.Do(req)
+}
+
+// sign adds a SharedKey authorization header, see
+// https://learn.microsoft.com/rest/api/storageservices/authorize-with-shared-key
+func (r *AzureReader) sign(req *http.Request) {
+	var msHeaders []string
+	for k := range req.Header {
+		if lk := strings.ToLower(k); strings.HasPrefix(lk, "x-ms-") {
+			msHeaders = append(msHeaders, lk)
+		}
+	}
+	sort.Strings(msHeaders)
+
+	var sb strings.Builder
+	sb.WriteString(req.Method + "\n")
+	for _, h := range []string{"Content-Encoding", "Content-Language", "Content-Length", "Content-MD5",
+		"Content-Type", "Date", "If-Modified-Since", "If-Match", "If-None-Match", "If-Unmodified-Since", "Range"} {
+		sb.WriteString(req.Header.Get(h) + "\n")
+	}
+	for _, h := range msHeaders {
+		sb.WriteString(h + ":" + strings.TrimSpace(req.Header.Get(h)) + "\n")
+	}
+	sb.WriteString("/" + r.path.Account + req.URL.EscapedPath())
+	query := req.URL.Query()
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		values := query[name]
+		sort.Strings(values)
+		sb.WriteString("\n" + strings.ToLower(name) + ":" + strings.Join(values, ","))
+	}
+
+	mac := hmac.New(sha256.New, r.key)
+	mac.Write([]byte(sb.String()))
+	sig := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	req.Header.Set("Authorization", "SharedKey "+r.path.Account+":"+sig)
+}
+
+func (r *AzureReader) stat() (size int64, err error) {
+	err = retry.do(r.ctx, func(ctx context.Context) error {
+		size, err = r.statOnce(ctx)
+		return err
+	})
+	return size, err
+}
+
+func (r *AzureReader) statOnce(ctx context.Context) (int64, error) {
+	req, err := r.newRequest(ctx, http.MethodHead)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := r.do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, newStatusError(resp, "azure returned non-OK status for "+r.blobURL)
+	}
+	if resp.ContentLength < 0 {
+		return 0, fmt.Errorf("azure did not specify Content-Length for %s", r.blobURL)
+	}
+	r.etag = resp.Header.Get("ETag")
+	r.modTime, _ = http.ParseTime(resp.Header.Get("Last-Modified"))
+	return resp.ContentLength, nil
+}
+
+func (r *AzureReader) rangeRequest(ctx context.Context, off, length int64) (*http.Response, error) {
+	req, err := r.newRequest(ctx, http.MethodGet)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-ms-range", fmt.Sprintf("bytes=%d-%d", off, off+length-1))
+	pinRequest(req, r.etag, r.modTime)
+	resp, err := r.do(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPinned(resp, r.blobURL, r.etag); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusPartialContent && resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, newStatusError(resp, "azure returned unexpected status for range request")
+	}
+	return resp, nil
+}
+
+func (r *AzureReader) CacheKey() string {
+	return CacheKey(r.blobURL, r.etag, r.modTime)
+}
@@ -0,0 +1,306 @@
+package reader
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apache/arrow/go/v17/parquet"
+)
+
+const (
+	DefaultGCSEndpoint = "https://storage.googleapis.com"
+	gcsReadOnlyScope   = "https://www.googleapis.com/auth/devstorage.read_only"
+	gcsTokenURI        = "https://oauth2.googleapis.com/token"
+)
+
+var (
+	_                 parquet.ReaderAtSeeker = (*GCSReader)(nil)
+	_                 Prefetcher             = (*GCSReader)(nil)
+	_                 Cacheable              = (*GCSReader)(nil)
+	ErrInvalidGCSPath                        = errors.New("path is not a valid gs path")
+)
+
+// GCSReader reads an object from Google Cloud Storage through the JSON API
+// with ranged media downloads.
+type GCSReader struct {
+	*rangedReader
+
+	ctx      context.Context
+	client   *http.Client
+	endpoint string
+	token    *GCSTokenSource
+	bucket   string
+	object   string
+
+	etag    string
+	modTime time.Time
+	// generation identifies the version of the object read, ranged
+	// requests fail once it was overwritten.
+	generation string
+}
+
+func ParseGCSPath(path string) (bucket, object string, err error) {
+	u, err := url.Parse(path)
+	if err != nil {
+		return
+	}
+	if u.Scheme != "gs" || u.Host == "" {
+		err = ErrInvalidGCSPath
+		return
+	}
+	bucket = u.Host
+	object = strings.TrimPrefix(u.Path, "/")
+	return
+}
+
+// NewGCSReader stats the object and returns a reader for it. endpoint may
+// point at a fake-gcs-server, token may be nil for anonymous access.
+func NewGCSReader(ctx context.Context, path, endpoint string, token *GCSTokenSource) (*GCSReader, error) {
+	bucket, object, err := ParseGCSPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if endpoint == "" {
+		endpoint = DefaultGCSEndpoint
+	}
+	r := &GCSReader{
+		ctx:      ctx,
+		client:   httpClient,
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		token:    token,
+		bucket:   bucket,
+		object:   object,
+	}
+	size, err := r.stat()
+	if err != nil {
+		return nil, err
+	}
+	r.rangedReader = newRangedReader(ctx, size, r.rangeRequest)
+	return r, nil
+}
+
+func (r *GCSReader) objectURL() string {
+	return fmt.Sprintf("%s/storage/v1/b/%s/o/%s", r.endpoint, url.PathEscape(r.bucket), url.PathEscape(r.object))
+}
+
+func (r *GCSReader) newRequest(ctx context.Context, rawURL string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if r.token != nil {
+		tok, err := r.token.Token(ctx)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+tok)
+	}
+	return req, nil
+}
+
+func (r *GCSReader) stat() (size int64, err error) {
+	err = retry.do(r.ctx, func(ctx context.Context) error {
+		size, err = r.statOnce(ctx)
+		return err
+	})
+	return size, err
+}
+
+func (r *GCSReader) statOnce(ctx context.Context) (int64, error) {
+	req, err := r.newRequest(ctx, r.objectURL())
+	if err != nil {
+		return 0, err
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, newStatusError(resp, fmt.Sprintf("gcs returned non-OK status for gs://%s/%s", r.bucket, r.object))
+	}
+	var obj struct {
+		Size       string    `json:"size"`
+		ETag       string    `json:"etag"`
+		Generation string    `json:"generation"`
+		Updated    time.Time `json:"updated"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&obj); err != nil {
+		return 0, err
+	}
+	size, err := strconv.ParseInt(obj.Size, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("gcs returned invalid object size %q: %w", obj.Size, err)
+	}
+	r.etag = obj.ETag
+	r.generation = obj.Generation
+	r.modTime = obj.Updated
+	return size, nil
+}
+
+func (r *GCSReader) rangeRequest(ctx context.Context, off, length int64) (*http.Response, error) {
+	params := url.Values{"alt": {"media"}}
+	if r.generation != "" {
+		params.Set("ifGenerationMatch", r.generation)
+	}
+	req, err := r.newRequest(ctx, r.objectURL()+"?"+params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+length-1))
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusPreconditionFailed {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: gs://%s/%s no longer has generation %s", ErrObjectChanged, r.bucket, r.object, r.generation)
+	}
+	if resp.StatusCode != http.StatusPartialContent && resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, newStatusError(resp, "gcs returned unexpected status for range request")
+	}
+	return resp, nil
+}
+
+func (r *GCSReader) CacheKey() string {
+	return CacheKey("gs://"+r.bucket+"/"+r.object, r.etag, r.modTime)
+}
+
+// GCSTokenSource issues OAuth2 access tokens for a service account with
+// the JWT bearer flow and caches them until they expire.
+type GCSTokenSource struct {
+	email    string
+	key      *rsa.PrivateKey
+	tokenURI string
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// NewGCSTokenSource loads a service account key file as downloaded from
+// the cloud console.
+func NewGCSTokenSource(keyFile string) (*GCSTokenSource, error) {
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	var sa struct {
+		Type        string `json:"type"`
+		ClientEmail string `json:"client_email"`
+		PrivateKey  string `json:"private_key"`
+		TokenURI    string `json:"token_uri"`
+	}
+	if err := json.Unmarshal(data, &sa); err != nil {
+		return nil, fmt.Errorf("error parsing gcs key file %s: %w", keyFile, err)
+	}
+	if sa.Type != "service_account" {
+		return nil, fmt.Errorf("gcs key file %s is not a service account key", keyFile)
+	}
+	block, _ := pem.Decode([]byte(sa.PrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("gcs key file %s has no private key", keyFile)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("gcs key file %s does not hold an RSA key", keyFile)
+	}
+	if sa.TokenURI == "" {
+		sa.TokenURI = gcsTokenURI
+	}
+	return &GCSTokenSource{
+		email:    sa.ClientEmail,
+		key:      key,
+		tokenURI: sa.TokenURI,
+	}, nil
+}
+
+func (ts *GCSTokenSource) Token(ctx context.Context) (string, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if ts.token != "" && time.Now().Add(time.Minute).Before(ts.expires) {
+		return ts.token, nil
+	}
+
+	assertion, err := ts.assertion()
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ts.tokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("error fetching gcs access token: %v: %s", resp.Status, body)
+	}
+	var tok struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return "", err
+	}
+	ts.token = tok.AccessToken
+	ts.expires = time.Now().Add(time.Duration(tok.ExpiresIn) * time.Second)
+	return ts.token, nil
+}
+
+// assertion builds the RS256 signed JWT exchanged for an access token.
+func (ts *GCSTokenSource) assertion() (string, error) {
+	now := time.Now()
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]any{
+		"iss":   ts.email,
+		"scope": gcsReadOnlyScope,
+		"aud":   ts.tokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, ts.key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + enc.EncodeToString(sig), nil
+}
@@ -0,0 +1,204 @@
+package reader
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gcsObject serves an httpObject through the JSON API of GCS and issues
+// access tokens for a service account key.
+type gcsObject struct {
+	t          *testing.T
+	obj        *httpObject
+	generation string
+	key        *rsa.PublicKey
+
+	mu     sync.Mutex
+	tokens int
+	srv    *httptest.Server
+}
+
+func (g *gcsObject) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/token" {
+		g.token(w, r)
+		return
+	}
+	if got := r.Header.Get("Authorization"); got != "Bearer token-1" {
+		g.t.Errorf("got Authorization %q, want the access token", got)
+	}
+	if got, want := r.URL.EscapedPath(), "/storage/v1/b/bucket/o/dir%2Fa%20b.parquet"; got != want {
+		g.t.Errorf("got path %q, want %q", got, want)
+	}
+	g.mu.Lock()
+	generation := g.generation
+	g.mu.Unlock()
+	q := r.URL.Query()
+	if q.Get("alt") != "media" {
+		json.NewEncoder(w).Encode(map[string]any{
+			"size":       "1000",
+			"etag":       "CJ/etag",
+			"generation": generation,
+			"updated":    time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC),
+		})
+		return
+	}
+	if got := q.Get("ifGenerationMatch"); got != "7" {
+		g.t.Errorf("got ifGenerationMatch %q, want 7", got)
+	}
+	if q.Get("ifGenerationMatch") != generation {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+	g.obj.ServeHTTP(w, r)
+}
+
+// token checks the JWT bearer grant of a GCSTokenSource.
+func (g *gcsObject) token(w http.ResponseWriter, r *http.Request) {
+	g.mu.Lock()
+	g.tokens++
+	g.mu.Unlock()
+	if err := r.ParseForm(); err != nil {
+		g.t.Error(err)
+		return
+	}
+	if got := r.PostForm.Get("grant_type"); got != "urn:ietf:params:oauth:grant-type:jwt-bearer" {
+		g.t.Errorf("got grant_type %q", got)
+	}
+	parts := strings.Split(r.PostForm.Get("assertion"), ".")
+	if len(parts) != 3 {
+		g.t.Errorf("got assertion with %d parts, want 3", len(parts))
+		return
+	}
+	enc := base64.RawURLEncoding
+	sig, _ := enc.DecodeString(parts[2])
+	sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(g.key, crypto.SHA256, sum[:], sig); err != nil {
+		g.t.Errorf("bad assertion signature: %v", err)
+	}
+	var header map[string]string
+	var claims struct {
+		Iss   string `json:"iss"`
+		Scope string `json:"scope"`
+		Aud   string `json:"aud"`
+		Iat   int64  `json:"iat"`
+		Exp   int64  `json:"exp"`
+	}
+	h, _ := enc.DecodeString(parts[0])
+	c, _ := enc.DecodeString(parts[1])
+	if err := errors.Join(json.Unmarshal(h, &header), json.Unmarshal(c, &claims)); err != nil {
+		g.t.Error(err)
+	}
+	if header["alg"] != "RS256" || header["typ"] != "JWT" {
+		g.t.Errorf("got header %v", header)
+	}
+	if claims.Iss != "reader@project.iam.gserviceaccount.com" || claims.Scope != gcsReadOnlyScope ||
+		claims.Aud != g.srv.URL+"/token" || claims.Exp-claims.Iat != 3600 {
+		g.t.Errorf("got claims %+v", claims)
+	}
+	json.NewEncoder(w).Encode(map[string]any{"access_token": "token-1", "expires_in": 3600})
+}
+
+// writeGCSKeyFile writes a service account key file that fetches tokens
+// from tokenURI.
+func writeGCSKeyFile(t *testing.T, key *rsa.PrivateKey, tokenURI string) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(map[string]string{
+		"type":         "service_account",
+		"client_email": "reader@project.iam.gserviceaccount.com",
+		"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"token_uri":    tokenURI,
+	})
+	name := filepath.Join(t.TempDir(), "key.json")
+	if err := os.WriteFile(name, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestGCSReader(t *testing.T) {
+	fastRetry(t, 1)
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := testData(1000)
+	g := &gcsObject{t: t, obj: &httpObject{data: data}, generation: "7", key: &key.PublicKey}
+	g.srv = httptest.NewServer(g)
+	defer g.srv.Close()
+	ts, err := NewGCSTokenSource(writeGCSKeyFile(t, key, g.srv.URL+"/token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewGCSReader(context.Background(), "gs://bucket/dir/a b.parquet", g.srv.URL, ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]byte, 100)
+	if _, err := r.ReadAt(got, 900); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data[900:]) {
+		t.Error("got wrong bytes")
+	}
+	checkLog(t, g.obj, "GET bytes=900-999")
+	g.mu.Lock()
+	if g.tokens != 1 {
+		t.Errorf("fetched %d tokens, want 1 for all requests", g.tokens)
+	}
+	g.mu.Unlock()
+	if r.CacheKey() == "" {
+		t.Error("no cache key for an object with an etag")
+	}
+
+	g.mu.Lock()
+	g.generation = "8"
+	g.mu.Unlock()
+	if _, err := r.ReadAt(got, 0); !errors.Is(err, ErrObjectChanged) {
+		t.Errorf("read of an overwritten object: got %v, want ErrObjectChanged", err)
+	}
+}
+
+func TestNewGCSTokenSource(t *testing.T) {
+	dir := t.TempDir()
+	for _, tt := range []struct {
+		name string
+		data string
+		want string
+	}{
+		{name: "not json", data: "{", want: "error parsing gcs key file"},
+		{name: "user credentials", data: `{"type": "authorized_user"}`, want: "is not a service account key"},
+		{name: "no key", data: `{"type": "service_account"}`, want: "has no private key"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			name := filepath.Join(dir, "key.json")
+			if err := os.WriteFile(name, []byte(tt.data), 0600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := NewGCSTokenSource(name); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -1,59 +1,451 @@
 package reader
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/apache/arrow/go/v17/parquet"
 )
 
-var _ parquet.ReaderAtSeeker = (*HttpReader)(nil)
+var (
+	_ parquet.ReaderAtSeeker = (*HttpReader)(nil)
+	_ Prefetcher             = (*HttpReader)(nil)
+	_ Cacheable              = (*HttpReader)(nil)
+)
 
 type HttpReader struct {
-	url      string
+	ctx context.Context
+	url string
+	// location is the URL after redirects, later requests are sent there
+	// directly.
+	location string
 	fileSize int64
-	data     []byte
-	offset   int64
+	// mu guards rangeable and data, which a read answered with the whole
+	// file sets while prefetches and other reads run.
+	mu sync.Mutex
+	// rangeable is true when the server advertises byte range support,
+	// otherwise the whole file is downloaded on first access.
+	rangeable bool
+	data      []byte
+	// tail holds the end of the file from tailOff on when it was fetched
+	// to learn the size of the file.
+	tail    []byte
+	tailOff int64
+	offset  int64
+
+	etag    string
+	modTime time.Time
+
+	prefetch *prefetcher
 }
 
-func NewHttpReader(url string) (*HttpReader, error) {
-	resp, err := http.Head(url)
-	if err != nil {
+// footerProbeSize is the length of the suffix range requested when HEAD
+// gives no size. It is the footer read size of the parquet reader, so
+// opening the file needs no further request.
+const footerProbeSize = 64 << 10
+
+func NewHttpReader(ctx context.Context, url string) (*HttpReader, error) {
+	fd := &HttpReader{
+		ctx:      ctx,
+		url:      url,
+		location: url,
+		fileSize: -1,
+	}
+	err := retry.do(ctx, fd.head)
+	var statusErr *StatusError
+	if err != nil && !errors.As(err, &statusErr) {
 		return nil, err
 	}
+	if err != nil || fd.fileSize < 0 {
+		// HEAD is not allowed, e.g. for URLs presigned for GET, or the
+		// server sent no Content-Length. Ask for the footer instead and
+		// take the size from Content-Range.
+		if err := retry.do(ctx, fd.probe); err != nil {
+			return nil, err
+		}
+	}
+	fd.prefetch = newPrefetcher(fd.fileSize, fd.fetch)
+	return fd, nil
+}
+
+func (fd *HttpReader) head(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, fd.url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("server returned non-OK status: %v", resp.Status)
+		return newStatusError(resp, "server returned non-OK status")
+	}
+	fd.setVersion(resp)
+	fd.fileSize = resp.ContentLength
+	fd.rangeable = acceptRanges(resp.Header)
+	return nil
+}
+
+// probe learns the size of the file with a GET for its last bytes, which
+// are kept for the footer. Servers without suffix ranges are asked for the
+// first byte, servers without ranges send the whole file.
+func (fd *HttpReader) probe(ctx context.Context) error {
+	for _, rng := range []string{fmt.Sprintf("bytes=-%d", footerProbeSize), "bytes=0-0"} {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, fd.location, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Range", rng)
+		resp, err := fd.do(req)
+		if err != nil {
+			return err
+		}
+		done, err := fd.probeResponse(resp)
+		resp.Body.Close()
+		if done || err != nil {
+			return err
+		}
 	}
+	// neither range is satisfiable, the file is empty
+	fd.fileSize = 0
+	return nil
+}
 
-	fileSize := resp.ContentLength
-	if fileSize < 0 {
-		return nil, fmt.Errorf("server did not specify Content-Length")
+func (fd *HttpReader) probeResponse(resp *http.Response) (bool, error) {
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		first, last, total, err := parseContentRange(resp.Header.Get("Content-Range"))
+		if err != nil {
+			return false, err
+		}
+		if total < 0 {
+			return false, fmt.Errorf("server did not report the size of %s", fd.url)
+		}
+		data := make([]byte, last-first+1)
+		if _, err := readFull(resp.Body, data); err != nil {
+			return false, err
+		}
+		fd.setVersion(resp)
+		fd.fileSize = total
+		fd.rangeable = true
+		if last == total-1 {
+			fd.tail, fd.tailOff = data, first
+		}
+		return true, nil
+	case http.StatusRequestedRangeNotSatisfiable:
+		// the answer should carry "bytes */size", servers that omit it
+		// are asked for the next range
+		_, _, total, err := parseContentRange(resp.Header.Get("Content-Range"))
+		if err != nil || total < 0 {
+			return false, nil
+		}
+		fd.setVersion(resp)
+		fd.fileSize = total
+		fd.rangeable = true
+		return true, nil
+	case http.StatusOK:
+		data, err := readBody(resp)
+		if err != nil {
+			return false, err
+		}
+		fd.setVersion(resp)
+		fd.data = data
+		fd.fileSize = int64(len(data))
+		fd.rangeable = false
+		return true, nil
+	default:
+		return false, newStatusError(resp, "server returned non-OK status")
 	}
+}
 
-	return &HttpReader{
-		url:      url,
-		fileSize: fileSize,
-	}, nil
+// setVersion records the final URL of resp and the version of the file it
+// describes, later requests are pinned to it.
+func (fd *HttpReader) setVersion(resp *http.Response) {
+	fd.location = resp.Request.URL.String()
+	fd.etag = resp.Header.Get("ETag")
+	fd.modTime, _ = http.ParseTime(resp.Header.Get("Last-Modified"))
+}
+
+// parseContentRange parses "bytes first-last/total" and "bytes */total".
+// first and last are -1 in the latter form, total is -1 when the server
+// gives "*".
+func parseContentRange(s string) (first, last, total int64, err error) {
+	invalid := fmt.Errorf("invalid Content-Range %q", s)
+	rest, ok := strings.CutPrefix(s, "bytes ")
+	if !ok {
+		return 0, 0, 0, invalid
+	}
+	rng, size, ok := strings.Cut(rest, "/")
+	if !ok {
+		return 0, 0, 0, invalid
+	}
+	total = -1
+	if size != "*" {
+		if total, err = strconv.ParseInt(size, 10, 64); err != nil || total < 0 {
+			return 0, 0, 0, invalid
+		}
+	}
+	if rng == "*" {
+		return -1, -1, total, nil
+	}
+	a, b, ok := strings.Cut(rng, "-")
+	if !ok {
+		return 0, 0, 0, invalid
+	}
+	first, err1 := strconv.ParseInt(a, 10, 64)
+	last, err2 := strconv.ParseInt(b, 10, 64)
+	if err1 != nil || err2 != nil || first < 0 || last < first || (total >= 0 && last >= total) {
+		return 0, 0, 0, invalid
+	}
+	return first, last, total, nil
+}
+
+// acceptRanges reports whether the response header advertises byte ranges.
+func acceptRanges(h http.Header) bool {
+	for _, v := range strings.Split(h.Get("Accept-Ranges"), ",") {
+		if strings.EqualFold(strings.TrimSpace(v), "bytes") {
+			return true
+		}
+	}
+	return false
 }
 
 func (fd *HttpReader) ReadAt(p []byte, off int64) (n int, err error) {
 	if off >= fd.fileSize {
 		return 0, io.EOF
 	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	end := off + int64(len(p))
+	if end > fd.fileSize {
+		end = fd.fileSize
+	}
+
+	fd.mu.Lock()
+	rangeable, data := fd.rangeable, fd.data
+	fd.mu.Unlock()
+	if rangeable && len(data) == 0 {
+		buf := p[:end-off]
+		if fd.tail != nil && end > fd.tailOff {
+			// the end of the file was fetched by NewHttpReader, only the
+			// part before it is requested
+			head := max(fd.tailOff-off, 0)
+			copy(buf[head:], fd.tail[off+head-fd.tailOff:])
+			buf = buf[:head]
+		}
+		if len(buf) > 0 {
+			if _, ok := fd.prefetch.readAt(buf, off); !ok {
+				n, err := fd.readRange(buf, off)
+				if err == nil && n < len(buf) {
+					err = io.ErrUnexpectedEOF
+				}
+				if err != nil {
+					return n, err
+				}
+			}
+		}
+		n = int(end - off)
+		if n < len(p) {
+			return n, io.EOF
+		}
+		return n, nil
+	}
+
+	data, err = fd.body()
+	if err != nil {
+		return 0, err
+	}
+	// a body shorter than the size the server announced is an error, not
+	// the end of the file
+	if off >= int64(len(data)) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	n = copy(p, data[off:min(end, int64(len(data)))])
+	if int64(n) < end-off {
+		return n, io.ErrUnexpectedEOF
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// body returns the whole file, downloading it on first use.
+func (fd *HttpReader) body() ([]byte, error) {
+	fd.mu.Lock()
+	defer fd.mu.Unlock()
 	if len(fd.data) == 0 {
 		if err := fd.download(); err != nil {
+			return nil, err
+		}
+	}
+	return fd.data, nil
+}
+
+// setBody keeps the whole file a server sent instead of a range, later
+// reads are served from it.
+func (fd *HttpReader) setBody(data []byte) {
+	fd.mu.Lock()
+	defer fd.mu.Unlock()
+	fd.data = data
+	fd.rangeable = false
+}
+
+// readRange fetches len(p) bytes starting at off with a Range request.
+// If the server ignores the range and answers with the full body, the
+// body is kept and later reads are served from memory.
+func (fd *HttpReader) readRange(p []byte, off int64) (n int, err error) {
+	err = retry.do(fd.ctx, func(ctx context.Context) error {
+		n, err = fd.readRangeOnce(ctx, p, off)
+		return err
+	})
+	return n, err
+}
+
+func (fd *HttpReader) readRangeOnce(ctx context.Context, p []byte, off int64) (int, error) {
+	resp, err := fd.rangeRequest(ctx, off, int64(len(p)))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		if err := checkContentRange(resp, off); err != nil {
 			return 0, err
 		}
+		return readFull(resp.Body, p)
+	case http.StatusRequestedRangeNotSatisfiable:
+		return 0, io.EOF
+	case http.StatusOK:
+		data, err := readBody(resp)
+		if err != nil {
+			return 0, err
+		}
+		fd.setBody(data)
+		if off >= int64(len(data)) {
+			return 0, io.ErrUnexpectedEOF
+		}
+		return copy(p, data[off:]), nil
+	default:
+		return 0, newStatusError(resp, "server returned unexpected status for range request")
 	}
+}
 
-	end := off + int64(len(p))
-	if end > fd.fileSize {
-		end = fd.fileSize
+// readBody reads a whole response body, reporting a body shorter than
+// its Content-Length as io.ErrUnexpectedEOF.
+func readBody(resp *http.Response) ([]byte, error) {
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.ContentLength >= 0 && int64(len(data)) < resp.ContentLength {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return data, nil
+}
+
+// readRangeBody reads the answer to a range request starting at off into p.
+// A server that ignores the range answers 200 with the whole object, the
+// bytes before off are then skipped.
+func readRangeBody(resp *http.Response, p []byte, off int64) (int, error) {
+	if resp.StatusCode == http.StatusPartialContent {
+		if err := checkContentRange(resp, off); err != nil {
+			return 0, err
+		}
+	}
+	if resp.StatusCode == http.StatusOK && off > 0 {
+		if _, err := io.CopyN(io.Discard, resp.Body, off); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+	}
+	return readFull(resp.Body, p)
+}
+
+// checkContentRange rejects a partial response that does not start at off,
+// its bytes would be taken for those of off.
+func checkContentRange(resp *http.Response, off int64) error {
+	first, _, _, err := parseContentRange(resp.Header.Get("Content-Range"))
+	if err != nil {
+		return err
+	}
+	if first != off {
+		return fmt.Errorf("server returned bytes from %d for a range from %d", first, off)
+	}
+	return nil
+}
+
+func (fd *HttpReader) rangeRequest(ctx context.Context, off, length int64) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fd.location, nil)
+	if err != nil {
+		return nil, err
 	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+length-1))
+	return fd.do(req)
+}
+
+// do sends a request pinned to the version of the file seen by
+// NewHttpReader.
+func (fd *HttpReader) do(req *http.Request) (*http.Response, error) {
+	pinRequest(req, fd.etag, fd.modTime)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPinned(resp, fd.url, fd.etag); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
 
-	copy(p, fd.data[off:end])
-	return int(end - off), nil
+// Prefetch fetches the given ranges concurrently, later ReadAt calls
+// inside them are served from memory. It does nothing when the server
+// does not support ranges, since the whole file is downloaded anyway.
+func (fd *HttpReader) Prefetch(ranges []Range, opts PrefetchOptions) error {
+	fd.mu.Lock()
+	rangeable := fd.rangeable
+	fd.mu.Unlock()
+	if !rangeable {
+		return nil
+	}
+	return fd.prefetch.Prefetch(ranges, opts)
+}
+
+func (fd *HttpReader) fetch(off, length int64) ([]byte, error) {
+	data := make([]byte, length)
+	err := retry.do(fd.ctx, func(ctx context.Context) error {
+		resp, err := fd.rangeRequest(ctx, off, length)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusPartialContent {
+			return newStatusError(resp, "server returned unexpected status for range request")
+		}
+		if err := checkContentRange(resp, off); err != nil {
+			return err
+		}
+		_, err = readFull(resp.Body, data)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
 }
 
 func (fd *HttpReader) Seek(offset int64, whence int) (int64, error) {
@@ -77,22 +469,37 @@ func (fd *HttpReader) Seek(offset int64, whence int) (int64, error) {
 	return fd.offset, nil
 }
 
+func (fd *HttpReader) Size() (int64, error) {
+	return fd.fileSize, nil
+}
+
+func (fd *HttpReader) CacheKey() string {
+	return CacheKey(fd.url, fd.etag, fd.modTime)
+}
+
+// download fetches the whole file, fd.mu is held.
 func (fd *HttpReader) download() error {
-	resp, err := http.Get(fd.url)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
+	return retry.do(fd.ctx, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, fd.location, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := fd.do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("server returned non-OK status: %v", resp.Status)
-	}
+		if resp.StatusCode != http.StatusOK {
+			return newStatusError(resp, "server returned non-OK status")
+		}
 
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return err
-	}
+		data, err := readBody(resp)
+		if err != nil {
+			return err
+		}
 
-	fd.data = data
-	return nil
+		fd.data = data
+		return nil
+	})
 }
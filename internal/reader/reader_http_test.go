@@ -0,0 +1,384 @@
+package reader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// httpObject is a file served over HTTP with the quirks of the servers
+// the readers meet.
+type httpObject struct {
+	mu   sync.Mutex
+	data []byte
+	etag string
+	// noHead answers HEAD with 405, noLength sends no Content-Length.
+	noHead   bool
+	noLength bool
+	// noRanges ignores Range and sends the whole file without
+	// Accept-Ranges, ignoreRanges does the same but advertises ranges.
+	noRanges     bool
+	ignoreRanges bool
+	// noSuffix rejects suffix ranges with a 416 without Content-Range.
+	noSuffix bool
+	// ignoreIfMatch serves any version.
+	ignoreIfMatch bool
+	// short bytes are cut from range bodies, shift is added to the
+	// first byte of Content-Range.
+	short int
+	shift int64
+	// fail requests are answered with 503 first.
+	fail int
+
+	requests []string
+}
+
+func (o *httpObject) serve(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(o)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// log returns the requests received since the last call.
+func (o *httpObject) log() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	r := o.requests
+	o.requests = nil
+	return r
+}
+
+func (o *httpObject) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.requests = append(o.requests, strings.TrimSpace(r.Method+" "+r.Header.Get("Range")))
+	if o.fail > 0 {
+		o.fail--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	if o.etag != "" {
+		w.Header().Set("ETag", o.etag)
+	}
+	if m := r.Header.Get("If-Match"); m != "" && m != o.etag && !o.ignoreIfMatch {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+	size := int64(len(o.data))
+	if r.Method == http.MethodHead {
+		if o.noHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if !o.noRanges {
+			w.Header().Set("Accept-Ranges", "bytes")
+		}
+		if !o.noLength {
+			w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+		}
+		return
+	}
+	rng := r.Header.Get("Range")
+	if rng == "" || o.noRanges || o.ignoreRanges {
+		w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+		w.Write(o.data)
+		return
+	}
+	first, last, ok := o.parseRange(rng)
+	if !ok {
+		if !o.noSuffix || !strings.HasPrefix(rng, "bytes=-") {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+		}
+		w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+	w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", first+o.shift, last+o.shift, size))
+	w.Header().Set("Content-Length", strconv.FormatInt(last-first+1, 10))
+	w.WriteHeader(http.StatusPartialContent)
+	w.Write(o.data[first : last+1-int64(o.short)])
+}
+
+// parseRange resolves a single range of the file, false when it cannot
+// be satisfied.
+func (o *httpObject) parseRange(s string) (first, last int64, ok bool) {
+	size := int64(len(o.data))
+	a, b, _ := strings.Cut(strings.TrimPrefix(s, "bytes="), "-")
+	if a == "" {
+		n, _ := strconv.ParseInt(b, 10, 64)
+		if o.noSuffix || n == 0 || size == 0 {
+			return 0, 0, false
+		}
+		return max(size-n, 0), size - 1, true
+	}
+	first, _ = strconv.ParseInt(a, 10, 64)
+	last, _ = strconv.ParseInt(b, 10, 64)
+	if first >= size {
+		return 0, 0, false
+	}
+	return first, min(last, size-1), true
+}
+
+// fastRetry replaces the retry policy for the test.
+func fastRetry(t *testing.T, attempts int) {
+	prev := retry
+	SetRetryOptions(RetryOptions{MaxAttempts: attempts, Budget: 100})
+	t.Cleanup(func() { retry = prev })
+}
+
+func checkLog(t *testing.T, o *httpObject, want ...string) {
+	t.Helper()
+	if got := o.log(); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("got requests %q, want %q", got, want)
+	}
+}
+
+func TestHttpReader(t *testing.T) {
+	fastRetry(t, 1)
+	o := &httpObject{data: testData(1000), etag: `"v1"`}
+	srv := o.serve(t)
+	r, err := NewHttpReader(context.Background(), srv.URL+"/f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size, _ := r.Size(); size != 1000 {
+		t.Errorf("got size %d, want 1000", size)
+	}
+	checkLog(t, o, "HEAD")
+
+	p := make([]byte, 100)
+	if n, err := r.ReadAt(p, 10); n != 100 || err != nil || !bytes.Equal(p, o.data[10:110]) {
+		t.Errorf("got %d %v, want 100 bytes from 10", n, err)
+	}
+	if n, err := r.ReadAt(p, 950); n != 50 || err != io.EOF || !bytes.Equal(p[:50], o.data[950:]) {
+		t.Errorf("got %d %v at the end, want 50 bytes and EOF", n, err)
+	}
+	if n, err := r.ReadAt(p, 1000); n != 0 || err != io.EOF {
+		t.Errorf("got %d %v past the end, want EOF", n, err)
+	}
+	checkLog(t, o, "GET bytes=10-109", "GET bytes=950-999")
+	if r.CacheKey() == "" || r.CacheKey() != CacheKey(srv.URL+"/f", `"v1"`, time.Time{}) {
+		t.Errorf("got cache key %q", r.CacheKey())
+	}
+}
+
+// TestHttpReaderSize checks the requests that size a file when HEAD does
+// not.
+func TestHttpReaderSize(t *testing.T) {
+	fastRetry(t, 1)
+	small := testData(100)
+	large := testData(footerProbeSize + 1000)
+	tests := []struct {
+		name string
+		o    *httpObject
+		want []string
+		// reads of the footer after opening
+		footer []string
+	}{
+		{
+			name: "head",
+			o:    &httpObject{data: large},
+			want: []string{"HEAD"},
+			footer: []string{
+				fmt.Sprintf("GET bytes=%d-%d", len(large)-8, len(large)-1),
+			},
+		},
+		{
+			name: "no head",
+			o:    &httpObject{data: large, noHead: true},
+			want: []string{"HEAD", fmt.Sprintf("GET bytes=-%d", footerProbeSize)},
+		},
+		{
+			name: "no length",
+			o:    &httpObject{data: large, noLength: true},
+			want: []string{"HEAD", fmt.Sprintf("GET bytes=-%d", footerProbeSize)},
+		},
+		{
+			name: "smaller than the probe",
+			o:    &httpObject{data: small, noHead: true},
+			want: []string{"HEAD", fmt.Sprintf("GET bytes=-%d", footerProbeSize)},
+		},
+		{
+			name:   "no suffix ranges",
+			o:      &httpObject{data: large, noHead: true, noSuffix: true},
+			want:   []string{"HEAD", fmt.Sprintf("GET bytes=-%d", footerProbeSize), "GET bytes=0-0"},
+			footer: []string{fmt.Sprintf("GET bytes=%d-%d", len(large)-8, len(large)-1)},
+		},
+		{
+			name: "no ranges",
+			o:    &httpObject{data: large, noHead: true, noRanges: true},
+			want: []string{"HEAD", fmt.Sprintf("GET bytes=-%d", footerProbeSize)},
+		},
+		{
+			name: "empty",
+			o:    &httpObject{data: []byte{}, noHead: true},
+			want: []string{"HEAD", fmt.Sprintf("GET bytes=-%d", footerProbeSize)},
+		},
+		{
+			name: "empty without suffix ranges",
+			o:    &httpObject{data: []byte{}, noHead: true, noSuffix: true},
+			want: []string{"HEAD", fmt.Sprintf("GET bytes=-%d", footerProbeSize), "GET bytes=0-0"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := tt.o.serve(t)
+			r, err := NewHttpReader(context.Background(), srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkLog(t, tt.o, tt.want...)
+			size, _ := r.Size()
+			if size != int64(len(tt.o.data)) {
+				t.Fatalf("got size %d, want %d", size, len(tt.o.data))
+			}
+			if size == 0 {
+				return
+			}
+			p := make([]byte, 8)
+			if _, err := r.ReadAt(p, size-8); err != nil || !bytes.Equal(p, tt.o.data[size-8:]) {
+				t.Errorf("got footer %v %v, want %v", p, err, tt.o.data[size-8:])
+			}
+			checkLog(t, tt.o, tt.footer...)
+		})
+	}
+}
+
+func TestHttpReaderFullBody(t *testing.T) {
+	fastRetry(t, 1)
+	for _, o := range []*httpObject{
+		{data: testData(1000), noRanges: true},
+		{data: testData(1000), ignoreRanges: true},
+	} {
+		srv := o.serve(t)
+		r, err := NewHttpReader(context.Background(), srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, off := range []int64{500, 0, 990} {
+			p := make([]byte, 10)
+			if _, err := r.ReadAt(p, off); err != nil || !bytes.Equal(p, o.data[off:off+10]) {
+				t.Errorf("ignoreRanges=%v offset %d: got %v %v", o.ignoreRanges, off, p, err)
+			}
+		}
+		// the file is fetched once and served from memory
+		want := []string{"HEAD", "GET"}
+		if o.ignoreRanges {
+			want[1] = "GET bytes=500-509"
+		}
+		checkLog(t, o, want...)
+	}
+}
+
+func TestHttpReaderErrors(t *testing.T) {
+	fastRetry(t, 2)
+	tests := []struct {
+		name string
+		o    *httpObject
+		want string
+		// requests of the read, the retries included
+		reads int
+	}{
+		{"short body", &httpObject{short: 3}, "unexpected EOF", 2},
+		{"wrong range", &httpObject{shift: 1}, "server returned bytes from 11 for a range from 10", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.o.data, tt.o.etag = testData(100), `"v1"`
+			srv := tt.o.serve(t)
+			r, err := NewHttpReader(context.Background(), srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.o.log()
+			_, err = r.ReadAt(make([]byte, 10), 10)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want %q", err, tt.want)
+			}
+			if n := len(tt.o.log()); n != tt.reads {
+				t.Errorf("got %d requests, want %d", n, tt.reads)
+			}
+		})
+	}
+}
+
+func TestHttpReaderRetry(t *testing.T) {
+	fastRetry(t, 3)
+	o := &httpObject{data: testData(100), fail: 2}
+	srv := o.serve(t)
+	r, err := NewHttpReader(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.fail = 2
+	p := make([]byte, 10)
+	if _, err := r.ReadAt(p, 0); err != nil || !bytes.Equal(p, o.data[:10]) {
+		t.Errorf("got %v %v", p, err)
+	}
+	checkLog(t, o, "HEAD", "HEAD", "HEAD", "GET bytes=0-9", "GET bytes=0-9", "GET bytes=0-9")
+
+	o.fail = 3
+	var statusErr *StatusError
+	if _, err := r.ReadAt(p, 0); !errors.As(err, &statusErr) || statusErr.StatusCode != 503 {
+		t.Errorf("got %v, want the 503 after 3 attempts", err)
+	}
+}
+
+func TestParseContentRange(t *testing.T) {
+	tests := []struct {
+		in                 string
+		first, last, total int64
+		ok                 bool
+	}{
+		{"bytes 0-9/100", 0, 9, 100, true},
+		{"bytes 90-99/100", 90, 99, 100, true},
+		{"bytes 0-0/1", 0, 0, 1, true},
+		{"bytes 5-9/*", 5, 9, -1, true},
+		{"bytes */100", -1, -1, 100, true},
+		{"bytes */*", -1, -1, -1, true},
+		{"bytes 0-100/100", 0, 0, 0, false},
+		{"bytes 9-5/100", 0, 0, 0, false},
+		{"bytes -1-5/100", 0, 0, 0, false},
+		{"bytes 0-9/-1", 0, 0, 0, false},
+		{"bytes 0-9", 0, 0, 0, false},
+		{"bytes 0/100", 0, 0, 0, false},
+		{"items 0-9/100", 0, 0, 0, false},
+		{"", 0, 0, 0, false},
+	}
+	for _, tt := range tests {
+		first, last, total, err := parseContentRange(tt.in)
+		if (err == nil) != tt.ok {
+			t.Errorf("%q: got %v", tt.in, err)
+			continue
+		}
+		if tt.ok && (first != tt.first || last != tt.last || total != tt.total) {
+			t.Errorf("%q: got %d %d %d, want %d %d %d", tt.in, first, last, total, tt.first, tt.last, tt.total)
+		}
+	}
+}
+
+// TestHttpReaderTail checks that only the part of a read before the end
+// fetched by the size probe is requested.
+func TestHttpReaderTail(t *testing.T) {
+	fastRetry(t, 1)
+	o := &httpObject{data: testData(footerProbeSize + 1000), noHead: true}
+	srv := o.serve(t)
+	r, err := NewHttpReader(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.log()
+	p := make([]byte, 30)
+	if _, err := r.ReadAt(p, 990); err != nil || !bytes.Equal(p, o.data[990:1020]) {
+		t.Errorf("got %v %v", p, err)
+	}
+	checkLog(t, o, "GET bytes=990-999")
+}
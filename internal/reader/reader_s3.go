@@ -5,14 +5,16 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"net/url"
 	"path"
+	"slices"
 	"strings"
-	"syscall"
 	"time"
 
 	"github.com/apache/arrow/go/v17/parquet"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 	"github.com/jimyag/log"
@@ -20,6 +22,8 @@ import (
 
 var (
 	_                parquet.ReaderAtSeeker = (*S3Reader)(nil)
+	_                Prefetcher             = (*S3Reader)(nil)
+	_                Cacheable              = (*S3Reader)(nil)
 	ErrInvalidS3Path                        = errors.New("path is not a valid s3 path")
 )
 
@@ -28,9 +32,18 @@ type S3Reader struct {
 	ctx    context.Context
 	bucket string
 	key    string
-	size   int64
-	offset int64
-	body   io.ReadCloser
+	// versionID is empty for the latest version.
+	versionID string
+	size      int64
+	offset    int64
+	body      io.ReadCloser
+
+	etag    string
+	modTime time.Time
+	// sseCustomer is set for SSE-C encrypted objects.
+	sseCustomer bool
+
+	prefetch *prefetcher
 }
 
 func ParsePath(path string) (bucket, key string, err error) {
@@ -48,18 +61,43 @@ func ParsePath(path string) (bucket, key string, err error) {
 	return
 }
 
+// ParseVersionID returns the versionId parameter of
+// s3://bucket/key?versionId=..., which selects a version of an object in
+// a versioned bucket.
+func ParseVersionID(path string) string {
+	u, err := url.Parse(path)
+	if err != nil {
+		return ""
+	}
+	return u.Query().Get("versionId")
+}
+
 type fileInfo struct {
 	Name    string
 	Size    int64
 	ModTime time.Time
+	ETag    string
 	IsDir   bool
+	// SSECustomer is set for SSE-C encrypted objects, or when the request
+	// carried an SSE-C key.
+	SSECustomer bool
 }
 
-func head(ctx context.Context, bucket, key string, client s3iface.S3API) (*s3.HeadObjectOutput, error) {
-	return client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+// head sends a HEAD request for an object. sseCustomer reports whether
+// ApplyS3Options added an SSE-C key to it.
+func head(ctx context.Context, bucket, key, versionID string, client s3iface.S3API) (h *s3.HeadObjectOutput, sseCustomer bool, err error) {
+	input := &s3.HeadObjectInput{
 		Bucket: aws.String(bucket),
 		Key:    aws.String(key),
+	}
+	if versionID != "" {
+		input.VersionId = aws.String(versionID)
+	}
+	err = retry.do(ctx, func(ctx context.Context) error {
+		h, err = client.HeadObjectWithContext(ctx, input)
+		return err
 	})
+	return h, input.SSECustomerKey != nil, err
 }
 
 func Stat(ctx context.Context, uri string, client s3iface.S3API) (*fileInfo, error) {
@@ -67,18 +105,113 @@ func Stat(ctx context.Context, uri string, client s3iface.S3API) (*fileInfo, err
 	if err != nil {
 		return nil, err
 	}
-	h, err := head(ctx, bucket, key, client)
+	h, sseCustomer, err := head(ctx, bucket, key, ParseVersionID(uri), client)
 	if err != nil {
 		return nil, err
 	}
 	return &fileInfo{
 		Name:    path.Base(key),
 		Size:    *h.ContentLength,
-		ModTime: *h.LastModified,
+		ModTime: aws.TimeValue(h.LastModified),
+		ETag:    aws.StringValue(h.ETag),
 		IsDir:   false,
+
+		SSECustomer: h.SSECustomerAlgorithm != nil || sseCustomer,
 	}, nil
 }
 
+// List returns the keys of the objects under prefix, following the
+// ListObjectsV2 pagination. A failed page is retried from its
+// continuation token.
+func List(ctx context.Context, bucket, prefix string, client s3iface.S3API) ([]string, error) {
+	var keys []string
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	}
+	for {
+		var page *s3.ListObjectsV2Output
+		err := retry.do(ctx, func(ctx context.Context) error {
+			var err error
+			page, err = client.ListObjectsV2WithContext(ctx, input)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range page.Contents {
+			keys = append(keys, aws.StringValue(obj.Key))
+		}
+		if !aws.BoolValue(page.IsTruncated) || page.NextContinuationToken == nil {
+			return keys, nil
+		}
+		input.ContinuationToken = page.NextContinuationToken
+	}
+}
+
+// ObjectVersion is a version of an object in a versioned bucket.
+type ObjectVersion struct {
+	VersionID    string
+	Size         int64
+	ModTime      time.Time
+	ETag         string
+	IsLatest     bool
+	DeleteMarker bool
+}
+
+// ListVersions returns the versions and delete markers of key, newest
+// first. Unversioned buckets return a single version with ID "null".
+func ListVersions(ctx context.Context, bucket, key string, client s3iface.S3API) ([]ObjectVersion, error) {
+	var versions []ObjectVersion
+	input := &s3.ListObjectVersionsInput{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(key),
+	}
+	for {
+		var page *s3.ListObjectVersionsOutput
+		err := retry.do(ctx, func(ctx context.Context) error {
+			var err error
+			page, err = client.ListObjectVersionsWithContext(ctx, input)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range page.Versions {
+			if aws.StringValue(v.Key) != key {
+				continue
+			}
+			versions = append(versions, ObjectVersion{
+				VersionID: aws.StringValue(v.VersionId),
+				Size:      aws.Int64Value(v.Size),
+				ModTime:   aws.TimeValue(v.LastModified),
+				ETag:      aws.StringValue(v.ETag),
+				IsLatest:  aws.BoolValue(v.IsLatest),
+			})
+		}
+		for _, m := range page.DeleteMarkers {
+			if aws.StringValue(m.Key) != key {
+				continue
+			}
+			versions = append(versions, ObjectVersion{
+				VersionID:    aws.StringValue(m.VersionId),
+				ModTime:      aws.TimeValue(m.LastModified),
+				IsLatest:     aws.BoolValue(m.IsLatest),
+				DeleteMarker: true,
+			})
+		}
+		if !aws.BoolValue(page.IsTruncated) {
+			break
+		}
+		input.KeyMarker = page.NextKeyMarker
+		input.VersionIdMarker = page.NextVersionIdMarker
+	}
+	slices.SortStableFunc(versions, func(a, b ObjectVersion) int {
+		return b.ModTime.Compare(a.ModTime)
+	})
+	return versions, nil
+}
+
 func NewS3Reader(ctx context.Context, filepath string, client s3iface.S3API) (*S3Reader, error) {
 	info, err := Stat(ctx, filepath, client)
 	if err != nil {
@@ -88,13 +221,21 @@ func NewS3Reader(ctx context.Context, filepath string, client s3iface.S3API) (*S
 	if err != nil {
 		return nil, err
 	}
-	return &S3Reader{
-		client: client,
-		ctx:    ctx,
-		bucket: bucket,
-		key:    key,
-		size:   info.Size,
-	}, nil
+	r := &S3Reader{
+		client:    client,
+		ctx:       ctx,
+		bucket:    bucket,
+		key:       key,
+		versionID: ParseVersionID(filepath),
+		size:      info.Size,
+
+		etag:    info.ETag,
+		modTime: info.ModTime,
+
+		sseCustomer: info.SSECustomer,
+	}
+	r.prefetch = newPrefetcher(r.size, r.fetch)
+	return r, nil
 }
 
 func (r *S3Reader) Seek(offset int64, whence int) (int64, error) {
@@ -125,28 +266,38 @@ func (r *S3Reader) Read(p []byte) (int, error) {
 	if r.offset >= r.size {
 		return 0, io.EOF
 	}
-request:
-	if r.body == nil {
-		body, err := r.makeRequest(r.offset, r.size-r.offset)
+	var n int
+	err := retry.do(r.ctx, func(context.Context) error {
+		if r.body == nil {
+			// The body outlives the attempt, so it is not bound by the
+			// request timeout.
+			body, err := r.makeRequest(r.ctx, r.offset, r.size-r.offset)
+			if err != nil {
+				return err
+			}
+			r.body = body
+		}
+		var err error
+		n, err = r.body.Read(p)
+		if err == io.EOF {
+			err = nil
+			if n == 0 {
+				err = io.ErrUnexpectedEOF
+			}
+		}
 		if err != nil {
-			return 0, err
+			// reopen at the current offset, see
+			// https://github.com/aws/aws-sdk-go/issues/1242
+			r.body.Close()
+			r.body = nil
 		}
-		r.body = body
-	}
-
-	n, err := r.body.Read(p)
-	if errors.Is(err, syscall.ECONNRESET) {
-		// See: https://github.com/aws/aws-sdk-go/issues/1242
-		r.body = nil
-		goto request
-	}
-	if err == io.EOF {
-		err = nil
-	}
-	if err == nil {
-		r.offset += int64(n)
+		return err
+	})
+	if err != nil {
+		return 0, err
 	}
-	return n, err
+	r.offset += int64(n)
+	return n, nil
 }
 
 func (r *S3Reader) ReadAt(p []byte, off int64) (int, error) {
@@ -160,12 +311,40 @@ func (r *S3Reader) ReadAt(p []byte, off int64) (int, error) {
 	if off+count >= r.size {
 		count = r.size - off
 	}
-	b, err := r.makeRequest(off, count)
-	if err != nil {
+	if n, ok := r.prefetch.readAt(p[:count], off); ok {
+		return n, nil
+	}
+	if err := r.read(p[:count], off); err != nil {
 		return 0, err
 	}
-	defer b.Close()
-	return io.ReadAtLeast(b, p, int(count))
+	return int(count), nil
+}
+
+// read fills p from off, retrying failed requests and truncated bodies.
+func (r *S3Reader) read(p []byte, off int64) error {
+	return retry.do(r.ctx, func(ctx context.Context) error {
+		b, err := r.makeRequest(ctx, off, int64(len(p)))
+		if err != nil {
+			return err
+		}
+		defer b.Close()
+		_, err = readFull(b, p)
+		return err
+	})
+}
+
+// Prefetch fetches the given ranges concurrently, later ReadAt calls
+// inside them are served from memory.
+func (r *S3Reader) Prefetch(ranges []Range, opts PrefetchOptions) error {
+	return r.prefetch.Prefetch(ranges, opts)
+}
+
+func (r *S3Reader) fetch(off, length int64) ([]byte, error) {
+	data := make([]byte, length)
+	if err := r.read(data, off); err != nil {
+		return nil, err
+	}
+	return data, nil
 }
 
 func (r *S3Reader) Close() error {
@@ -177,19 +356,49 @@ func (r *S3Reader) Close() error {
 	return err
 }
 
-func (r *S3Reader) makeRequest(off int64, count int64) (io.ReadCloser, error) {
+func (r *S3Reader) makeRequest(ctx context.Context, off int64, count int64) (io.ReadCloser, error) {
 	input := &s3.GetObjectInput{
 		Bucket: aws.String(r.bucket),
 		Key:    aws.String(r.key),
 		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", off, off+count-1)),
 	}
-	res, err := r.client.GetObjectWithContext(r.ctx, input)
+	if r.versionID != "" {
+		input.VersionId = aws.String(r.versionID)
+	}
+	// Every request is an independent GET, pin the object seen by Stat so
+	// that an overwrite fails the read instead of mixing two versions.
+	if r.etag != "" {
+		input.IfMatch = aws.String(r.etag)
+	}
+	res, err := r.client.GetObjectWithContext(ctx, input)
+	var reqErr awserr.RequestFailure
+	if errors.As(err, &reqErr) && reqErr.StatusCode() == http.StatusPreconditionFailed {
+		return nil, objectChanged(r.uri(), r.etag)
+	}
 	if err != nil {
 		return nil, err
 	}
 	return res.Body, nil
 }
 
+func (r *S3Reader) uri() string {
+	uri := "s3://" + r.bucket + "/" + r.key
+	if r.versionID != "" {
+		uri += "?versionId=" + url.QueryEscape(r.versionID)
+	}
+	return uri
+}
+
 func (r *S3Reader) Size() (int64, error) {
 	return r.size, nil
 }
+
+func (r *S3Reader) CacheKey() string {
+	return CacheKey(r.uri(), r.etag, r.modTime)
+}
+
+// Private reports whether the object is SSE-C encrypted, its plaintext is
+// then never cached on disk.
+func (r *S3Reader) Private() bool {
+	return r.sseCustomer
+}
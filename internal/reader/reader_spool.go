@@ -0,0 +1,122 @@
+package reader
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/apache/arrow/go/v17/parquet"
+)
+
+var (
+	_              parquet.ReaderAtSeeker = (*SpoolReader)(nil)
+	ErrSpoolTooBig                        = errors.New("input is larger than the spool size limit")
+)
+
+// SpoolReader reads a non-seekable stream such as stdin or a pipe once and
+// exposes it as a parquet.ReaderAtSeeker. Up to memLimit bytes are kept in
+// memory, larger streams are spilled to a temporary file which Close
+// removes.
+type SpoolReader struct {
+	mem    *bytes.Reader
+	file   *os.File
+	size   int64
+	offset int64
+}
+
+// NewSpoolReader reads r until EOF. maxSize bounds the total size of the
+// stream, 0 means no limit.
+func NewSpoolReader(r io.Reader, memLimit, maxSize int64) (*SpoolReader, error) {
+	limit := func(n int64) int64 {
+		if maxSize > 0 && maxSize < n {
+			return maxSize
+		}
+		return n
+	}
+
+	var buf bytes.Buffer
+	n, err := io.CopyN(&buf, r, limit(memLimit)+1)
+	if errors.Is(err, io.EOF) {
+		return &SpoolReader{mem: bytes.NewReader(buf.Bytes()), size: n}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if maxSize > 0 && n > maxSize {
+		return nil, fmt.Errorf("%w (%d bytes)", ErrSpoolTooBig, maxSize)
+	}
+
+	f, err := os.CreateTemp("", "parquet-tools-spool-*.parquet")
+	if err != nil {
+		return nil, err
+	}
+	s := &SpoolReader{file: f}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		s.Close()
+		return nil, err
+	}
+	var rest io.Reader = r
+	if maxSize > 0 {
+		rest = io.LimitReader(r, maxSize-n+1)
+	}
+	m, err := io.Copy(f, rest)
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	s.size = n + m
+	if maxSize > 0 && s.size > maxSize {
+		s.Close()
+		return nil, fmt.Errorf("%w (%d bytes)", ErrSpoolTooBig, maxSize)
+	}
+	return s, nil
+}
+
+func (s *SpoolReader) ReadAt(p []byte, off int64) (int, error) {
+	switch {
+	case s.file != nil:
+		return s.file.ReadAt(p, off)
+	case s.mem != nil:
+		return s.mem.ReadAt(p, off)
+	}
+	return 0, os.ErrClosed
+}
+
+func (s *SpoolReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += s.offset
+	case io.SeekEnd:
+		offset += s.size
+	default:
+		return 0, errors.New("reader.SpoolReader.Seek: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("reader.SpoolReader.Seek: negative position")
+	}
+	s.offset = offset
+	return s.offset, nil
+}
+
+func (s *SpoolReader) Size() (int64, error) {
+	return s.size, nil
+}
+
+// Close removes the temporary file, if any.
+func (s *SpoolReader) Close() error {
+	if s.file == nil {
+		s.mem = nil
+		return nil
+	}
+	name := s.file.Name()
+	err := s.file.Close()
+	s.file = nil
+	s.mem = nil
+	if rmErr := os.Remove(name); err == nil {
+		err = rmErr
+	}
+	return err
+}
@@ -0,0 +1,133 @@
+package reader
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"testing"
+)
+
+// spoolFiles returns the temporary files left in dir.
+func spoolFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+// failingReader returns data, then err instead of io.EOF.
+type failingReader struct {
+	data []byte
+	err  error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, r.err
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestSpoolReader(t *testing.T) {
+	data := testData(1000)
+	for _, tt := range []struct {
+		name     string
+		size     int
+		memLimit int64
+		maxSize  int64
+		spilled  bool
+	}{
+		{name: "memory", size: 1000, memLimit: 1000},
+		{name: "empty", size: 0, memLimit: 10},
+		{name: "spilled", size: 1000, memLimit: 999, spilled: true},
+		{name: "no memory", size: 1000, memLimit: 0, spilled: true},
+		{name: "at max size in memory", size: 1000, memLimit: 2000, maxSize: 1000},
+		{name: "at max size spilled", size: 1000, memLimit: 10, maxSize: 1000, spilled: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			t.Setenv("TMPDIR", dir)
+			s, err := NewSpoolReader(bytes.NewReader(data[:tt.size]), tt.memLimit, tt.maxSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if files := spoolFiles(t, dir); (len(files) == 1) != tt.spilled {
+				t.Errorf("got temporary files %q, spilled %v", files, tt.spilled)
+			}
+			if size, _ := s.Size(); size != int64(tt.size) {
+				t.Errorf("got size %d, want %d", size, tt.size)
+			}
+			got, err := io.ReadAll(io.NewSectionReader(s, 0, int64(tt.size)))
+			if err != nil || !bytes.Equal(got, data[:tt.size]) {
+				t.Errorf("read %d bytes, %v, want the stream back", len(got), err)
+			}
+			if n, err := s.Seek(0, io.SeekEnd); err != nil || n != int64(tt.size) {
+				t.Errorf("seek to the end: got %d, %v, want %d", n, err, tt.size)
+			}
+			if err := s.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if files := spoolFiles(t, dir); len(files) != 0 {
+				t.Errorf("got temporary files %q after Close", files)
+			}
+			if _, err := s.ReadAt(make([]byte, 1), 0); !errors.Is(err, os.ErrClosed) {
+				t.Errorf("read after Close: got %v, want os.ErrClosed", err)
+			}
+		})
+	}
+}
+
+func TestSpoolReaderTooBig(t *testing.T) {
+	data := testData(1000)
+	for _, tt := range []struct {
+		name     string
+		memLimit int64
+	}{
+		{name: "memory", memLimit: 2000},
+		{name: "spilled", memLimit: 10},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			t.Setenv("TMPDIR", dir)
+			_, err := NewSpoolReader(bytes.NewReader(data), tt.memLimit, 999)
+			if !errors.Is(err, ErrSpoolTooBig) {
+				t.Errorf("got %v, want ErrSpoolTooBig", err)
+			}
+			if files := spoolFiles(t, dir); len(files) != 0 {
+				t.Errorf("got temporary files %q after the error", files)
+			}
+		})
+	}
+}
+
+func TestSpoolReaderError(t *testing.T) {
+	want := errors.New("broken pipe")
+	for _, tt := range []struct {
+		name     string
+		memLimit int64
+	}{
+		{name: "memory", memLimit: 2000},
+		{name: "spilled", memLimit: 10},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			t.Setenv("TMPDIR", dir)
+			_, err := NewSpoolReader(&failingReader{data: testData(100), err: want}, tt.memLimit, 0)
+			if !errors.Is(err, want) {
+				t.Errorf("got %v, want %v", err, want)
+			}
+			if files := spoolFiles(t, dir); len(files) != 0 {
+				t.Errorf("got temporary files %q after the error", files)
+			}
+		})
+	}
+}
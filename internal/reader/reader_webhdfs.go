@@ -0,0 +1,161 @@
+package reader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/apache/arrow/go/v17/parquet"
+)
+
+var (
+	_                     parquet.ReaderAtSeeker = (*WebHDFSReader)(nil)
+	_                     Prefetcher             = (*WebHDFSReader)(nil)
+	_                     Cacheable              = (*WebHDFSReader)(nil)
+	ErrInvalidWebHDFSPath                        = errors.New("path is not a valid webhdfs path")
+)
+
+// WebHDFSReader reads a file through the WebHDFS or HttpFS REST API, using
+// GETFILESTATUS for the size and OPEN with offset/length for ranged reads.
+type WebHDFSReader struct {
+	*rangedReader
+
+	ctx     context.Context
+	client  *http.Client
+	baseURL string
+	user    string
+	path    string
+
+	modTime time.Time
+}
+
+// NewWebHDFSReader opens webhdfs://namenode:port/path, or swebhdfs:// over
+// https. user is sent as user.name for simple (pseudo) authentication and
+// may be empty.
+func NewWebHDFSReader(ctx context.Context, path, user string) (*WebHDFSReader, error) {
+	u, err := url.Parse(path)
+	if err != nil {
+		return nil, err
+	}
+	scheme := "http"
+	switch u.Scheme {
+	case "webhdfs":
+	case "swebhdfs":
+		scheme = "https"
+	default:
+		return nil, ErrInvalidWebHDFSPath
+	}
+	if u.Host == "" || u.Path == "" {
+		return nil, ErrInvalidWebHDFSPath
+	}
+	r := &WebHDFSReader{
+		ctx:     ctx,
+		client:  httpClient,
+		baseURL: scheme + "://" + u.Host + "/webhdfs/v1" + u.EscapedPath(),
+		user:    user,
+		path:    path,
+	}
+	size, err := r.stat()
+	if err != nil {
+		return nil, err
+	}
+	r.rangedReader = newRangedReader(ctx, size, r.rangeRequest)
+	return r, nil
+}
+
+func (r *WebHDFSReader) opURL(op string, params url.Values) string {
+	if params == nil {
+		params = url.Values{}
+	}
+	params.Set("op", op)
+	if r.user != "" {
+		params.Set("user.name", r.user)
+	}
+	return r.baseURL + "?" + params.Encode()
+}
+
+// remoteError extracts the RemoteException message WebHDFS sends along
+// with error statuses.
+func (r *WebHDFSReader) remoteError(resp *http.Response) error {
+	var body struct {
+		RemoteException struct {
+			Exception string `json:"exception"`
+			Message   string `json:"message"`
+		} `json:"RemoteException"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err == nil && body.RemoteException.Message != "" {
+		return newStatusError(resp, fmt.Sprintf("webhdfs %s: %s", body.RemoteException.Exception, body.RemoteException.Message))
+	}
+	return newStatusError(resp, "webhdfs returned unexpected status for "+r.path)
+}
+
+func (r *WebHDFSReader) stat() (size int64, err error) {
+	err = retry.do(r.ctx, func(ctx context.Context) error {
+		size, err = r.statOnce(ctx)
+		return err
+	})
+	return size, err
+}
+
+func (r *WebHDFSReader) statOnce(ctx context.Context) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.opURL("GETFILESTATUS", nil), nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, r.remoteError(resp)
+	}
+	var status struct {
+		FileStatus struct {
+			Length           int64  `json:"length"`
+			ModificationTime int64  `json:"modificationTime"`
+			Type             string `json:"type"`
+		} `json:"FileStatus"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return 0, err
+	}
+	if status.FileStatus.Type != "FILE" {
+		return 0, fmt.Errorf("%s is not a file", r.path)
+	}
+	r.modTime = time.UnixMilli(status.FileStatus.ModificationTime)
+	return status.FileStatus.Length, nil
+}
+
+func (r *WebHDFSReader) rangeRequest(ctx context.Context, off, length int64) (*http.Response, error) {
+	params := url.Values{
+		"offset": {strconv.FormatInt(off, 10)},
+		"length": {strconv.FormatInt(length, 10)},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.opURL("OPEN", params), nil)
+	if err != nil {
+		return nil, err
+	}
+	// The namenode redirects to a datanode, which the client follows.
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, r.remoteError(resp)
+	}
+	// OPEN answers 200 with exactly the requested bytes, unlike a plain
+	// HTTP server ignoring a Range header.
+	resp.StatusCode = http.StatusPartialContent
+	return resp, nil
+}
+
+func (r *WebHDFSReader) CacheKey() string {
+	return CacheKey(r.path, "", r.modTime)
+}
@@ -0,0 +1,193 @@
+package reader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/jimyag/log"
+)
+
+// RetryOptions controls how failed remote requests are retried.
+type RetryOptions struct {
+	// MaxAttempts is the number of tries per request, including the first.
+	MaxAttempts int
+	// BaseDelay and MaxDelay bound the exponential backoff, the actual
+	// delay is chosen at random below the bound (full jitter).
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Budget is the number of retries shared by all requests, so a store
+	// that keeps failing does not multiply the run time by MaxAttempts.
+	Budget int64
+	// RequestTimeout bounds each attempt including reading the response
+	// body, 0 means no limit.
+	RequestTimeout time.Duration
+}
+
+var DefaultRetryOptions = RetryOptions{
+	MaxAttempts:    5,
+	BaseDelay:      100 * time.Millisecond,
+	MaxDelay:       5 * time.Second,
+	Budget:         100,
+	RequestTimeout: time.Minute,
+}
+
+type retryPolicy struct {
+	opts   RetryOptions
+	budget atomic.Int64
+}
+
+func newRetryPolicy(opts RetryOptions) *retryPolicy {
+	p := &retryPolicy{opts: opts}
+	p.budget.Store(opts.Budget)
+	return p
+}
+
+var retry = newRetryPolicy(DefaultRetryOptions)
+
+// SetRetryOptions replaces the retry policy of all readers. It must be
+// called before any reader is created.
+func SetRetryOptions(opts RetryOptions) {
+	retry = newRetryPolicy(opts)
+}
+
+// do runs attempt until it succeeds, fails with an error that is not
+// worth retrying or runs out of attempts. Each attempt gets a context
+// bounded by RequestTimeout, the delays between attempts are cut short
+// when ctx is done.
+func (p *retryPolicy) do(ctx context.Context, attempt func(ctx context.Context) error) error {
+	for i := 1; ; i++ {
+		err := p.try(ctx, attempt)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil || i >= p.opts.MaxAttempts || !Retryable(err) {
+			return err
+		}
+		if p.budget.Add(-1) < 0 {
+			return fmt.Errorf("retry budget exhausted: %w", err)
+		}
+		delay := p.backoff(i)
+		log.Warn(err).Int("attempt", i).Dur("delay", delay).Msg("retrying request")
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+	}
+}
+
+func (p *retryPolicy) try(ctx context.Context, attempt func(ctx context.Context) error) error {
+	if p.opts.RequestTimeout <= 0 {
+		return attempt(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, p.opts.RequestTimeout)
+	defer cancel()
+	return attempt(ctx)
+}
+
+func (p *retryPolicy) backoff(attempt int) time.Duration {
+	bound := p.opts.MaxDelay
+	if shift := attempt - 1; shift < 32 && p.opts.BaseDelay<<shift < bound {
+		bound = p.opts.BaseDelay << shift
+	}
+	if bound <= 0 {
+		return 0
+	}
+	return rand.N(bound)
+}
+
+// StatusError is returned when a server answers with an unexpected HTTP
+// status.
+type StatusError struct {
+	StatusCode int
+	Status     string
+	Msg        string
+}
+
+func newStatusError(resp *http.Response, msg string) *StatusError {
+	return &StatusError{StatusCode: resp.StatusCode, Status: resp.Status, Msg: msg}
+}
+
+func (e *StatusError) Error() string {
+	return e.Msg + ": " + e.Status
+}
+
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
+
+var retryableS3Codes = map[string]bool{
+	"SlowDown":                               true,
+	"RequestTimeout":                         true,
+	"InternalError":                          true,
+	"ServiceUnavailable":                     true,
+	"Throttling":                             true,
+	"ThrottlingException":                    true,
+	"RequestThrottled":                       true,
+	"RequestLimitExceeded":                   true,
+	"ProvisionedThroughputExceededException": true,
+}
+
+// Retryable reports whether err is transient: a 5xx or 429 status, an S3
+// throttling error such as SlowDown, a reset or timed out connection, or
+// a response body that ended early.
+func Retryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return retryableStatus(statusErr.StatusCode)
+	}
+	var reqErr awserr.RequestFailure
+	if errors.As(err, &reqErr) && retryableStatus(reqErr.StatusCode()) {
+		return true
+	}
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		if retryableS3Codes[awsErr.Code()] {
+			return true
+		}
+		// awserr.Error does not implement Unwrap, transport failures are
+		// wrapped in a RequestError
+		return awsErr.OrigErr() != nil && Retryable(awsErr.OrigErr())
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTimeout || dnsErr.IsTemporary
+	}
+	// other dial and TLS failures, such as no route to host or a bad
+	// proxy, are configuration errors and fail at once
+	var netErr net.Error
+	switch {
+	case errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.EPIPE),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return true
+	}
+	return false
+}
+
+// readFull is io.ReadFull, except that a body ending before p is filled
+// is always reported as io.ErrUnexpectedEOF so that it is retried.
+func readFull(r io.Reader, p []byte) (int, error) {
+	n, err := io.ReadFull(r, p)
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
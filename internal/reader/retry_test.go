@@ -0,0 +1,127 @@
+package reader
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestRetryable(t *testing.T) {
+	dial := func(err error) error {
+		return &url.Error{Op: "Get", URL: "http://h/x", Err: &net.OpError{Op: "dial", Net: "tcp", Err: err}}
+	}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"canceled", fmt.Errorf("x: %w", context.Canceled), false},
+		{"deadline", fmt.Errorf("x: %w", context.DeadlineExceeded), true},
+		{"503", &StatusError{StatusCode: 503}, true},
+		{"429", fmt.Errorf("x: %w", &StatusError{StatusCode: 429}), true},
+		{"404", &StatusError{StatusCode: 404}, false},
+		{"412", &StatusError{StatusCode: 412}, false},
+		{"short body", fmt.Errorf("x: %w", io.ErrUnexpectedEOF), true},
+		{"eof", io.EOF, false},
+		{"reset", dial(os.NewSyscallError("read", syscall.ECONNRESET)), true},
+		{"refused", dial(os.NewSyscallError("connect", syscall.ECONNREFUSED)), true},
+		{"broken pipe", dial(os.NewSyscallError("write", syscall.EPIPE)), true},
+		{"timeout", dial(timeoutError{}), true},
+		{"no route", dial(os.NewSyscallError("connect", syscall.EHOSTUNREACH)), false},
+		{"tls", dial(tls.RecordHeaderError{Msg: "first record does not look like a TLS handshake"}), false},
+		{"bad proxy", &url.Error{Op: "proxyconnect", URL: "http://h", Err: &net.OpError{Op: "proxyconnect", Err: errors.New("unknown")}}, false},
+		{"dns not found", dial(&net.DNSError{Err: "no such host", Name: "h", IsNotFound: true}), false},
+		{"dns timeout", dial(&net.DNSError{Err: "timeout", Name: "h", IsTimeout: true}), true},
+		{"slow down", awserr.New("SlowDown", "slow down", nil), true},
+		{"s3 500", awserr.NewRequestFailure(awserr.New("InternalError", "", nil), 500, "id"), true},
+		{"s3 403", awserr.NewRequestFailure(awserr.New("AccessDenied", "", nil), 403, "id"), false},
+		{"s3 transport", awserr.New("RequestError", "send request failed", dial(os.NewSyscallError("read", syscall.ECONNRESET))), true},
+		{"s3 bad endpoint", awserr.New("RequestError", "send request failed", dial(os.NewSyscallError("connect", syscall.EHOSTUNREACH))), false},
+	}
+	for _, tt := range tests {
+		if got := Retryable(tt.err); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	transient := &StatusError{StatusCode: 503, Status: "503 Service Unavailable", Msg: "x"}
+	p := newRetryPolicy(RetryOptions{MaxAttempts: 3, Budget: 4})
+	run := func(fail int, err error) (int, error) {
+		n := 0
+		got := p.do(context.Background(), func(context.Context) error {
+			n++
+			if n <= fail {
+				return err
+			}
+			return nil
+		})
+		return n, got
+	}
+
+	if n, err := run(2, transient); n != 3 || err != nil {
+		t.Errorf("got %d attempts and %v, want 3 and success", n, err)
+	}
+	if n, err := run(5, transient); n != 3 || !errors.Is(err, transient) {
+		t.Errorf("got %d attempts and %v, want 3 and the last error", n, err)
+	}
+	if n, err := run(5, io.EOF); n != 1 || err != io.EOF {
+		t.Errorf("got %d attempts and %v for a permanent error", n, err)
+	}
+	// 4 retries were spent above
+	if n, err := run(5, transient); n != 1 || err == nil || !strings.Contains(err.Error(), "retry budget exhausted") {
+		t.Errorf("got %d attempts and %v, want an exhausted budget", n, err)
+	}
+}
+
+func TestRetryPolicyContext(t *testing.T) {
+	p := newRetryPolicy(RetryOptions{MaxAttempts: 10, Budget: 10, BaseDelay: time.Hour, MaxDelay: time.Hour, RequestTimeout: 10 * time.Millisecond})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	n := 0
+	start := time.Now()
+	err := p.do(ctx, func(ctx context.Context) error {
+		n++
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > 10*time.Second {
+		t.Errorf("got %v after %v", err, time.Since(start))
+	}
+	if n > 2 {
+		t.Errorf("got %d attempts during an hour of backoff", n)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := newRetryPolicy(RetryOptions{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second})
+	for attempt, bound := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 4: 800 * time.Millisecond, 5: time.Second, 60: time.Second} {
+		for range 100 {
+			if d := p.backoff(attempt); d < 0 || d >= bound {
+				t.Fatalf("attempt %d: got %v, want below %v", attempt, d, bound)
+			}
+		}
+	}
+	if d := newRetryPolicy(RetryOptions{}).backoff(1); d != 0 {
+		t.Errorf("got %v without delays", d)
+	}
+}
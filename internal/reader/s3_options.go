@@ -0,0 +1,54 @@
+package reader
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// S3Options are settings some buckets require on every request.
+type S3Options struct {
+	// SSECustomerKey is the 256 bit key of objects encrypted with SSE-C.
+	// It is sent with HEAD and GET requests, which S3 only allows over
+	// https.
+	SSECustomerKey []byte
+	// RequesterPays acknowledges that the requester is charged for
+	// requests to a requester-pays bucket.
+	RequesterPays bool
+}
+
+// ApplyS3Options adds opts to the HEAD, GET and LIST requests made with
+// client, including those of S3Reader, Stat, List and ListVersions.
+func ApplyS3Options(client *s3.S3, opts S3Options) {
+	if len(opts.SSECustomerKey) == 0 && !opts.RequesterPays {
+		return
+	}
+	var payer *string
+	if opts.RequesterPays {
+		payer = aws.String(s3.RequestPayerRequester)
+	}
+	var algorithm, key *string
+	if len(opts.SSECustomerKey) > 0 {
+		algorithm = aws.String(s3.ServerSideEncryptionAes256)
+		key = aws.String(string(opts.SSECustomerKey))
+	}
+	// The inputs are filled before validation, so that the SDK checks them
+	// and computes the key MD5 as for keys set by the caller.
+	client.Handlers.Validate.PushFrontNamed(request.NamedHandler{
+		Name: "parquet-tools.S3Options",
+		Fn: func(r *request.Request) {
+			switch in := r.Params.(type) {
+			case *s3.HeadObjectInput:
+				in.SSECustomerAlgorithm, in.SSECustomerKey = algorithm, key
+				in.RequestPayer = payer
+			case *s3.GetObjectInput:
+				in.SSECustomerAlgorithm, in.SSECustomerKey = algorithm, key
+				in.RequestPayer = payer
+			case *s3.ListObjectsV2Input:
+				in.RequestPayer = payer
+			case *s3.ListObjectVersionsInput:
+				in.RequestPayer = payer
+			}
+		},
+	})
+}
@@ -0,0 +1,152 @@
+package reader
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// s3Bucket serves an httpObject as bucket/dir/a.parquet through the S3
+// REST API and records the headers of each kind of request.
+type s3Bucket struct {
+	obj *httpObject
+
+	mu      sync.Mutex
+	headers map[string]http.Header
+}
+
+func (b *s3Bucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	kind := r.Method
+	switch q := r.URL.Query(); {
+	case q.Has("versions"):
+		kind = "VERSIONS"
+	case q.Has("list-type"):
+		kind = "LIST"
+	}
+	b.mu.Lock()
+	b.headers[kind] = r.Header.Clone()
+	b.mu.Unlock()
+
+	if r.Header.Get("x-amz-server-side-encryption-customer-algorithm") != "" {
+		w.Header().Set("x-amz-server-side-encryption-customer-algorithm", "AES256")
+	}
+	switch kind {
+	case "VERSIONS":
+		fmt.Fprint(w, `<ListVersionsResult><Version><Key>dir/a.parquet</Key><VersionId>v1</VersionId><IsLatest>true</IsLatest><Size>1000</Size></Version></ListVersionsResult>`)
+	case "LIST":
+		fmt.Fprint(w, `<ListBucketResult><Contents><Key>dir/a.parquet</Key><Size>1000</Size></Contents></ListBucketResult>`)
+	case http.MethodHead:
+		w.Header().Set("ETag", b.obj.etag)
+		w.Header().Set("Last-Modified", "Sun, 18 Oct 2026 10:00:00 GMT")
+		w.Header().Set("Content-Length", strconv.Itoa(len(b.obj.data)))
+	default:
+		b.obj.ServeHTTP(w, r)
+	}
+}
+
+func TestApplyS3Options(t *testing.T) {
+	fastRetry(t, 1)
+	// a CA bundle from the environment would replace the roots of the
+	// test server client
+	t.Setenv("AWS_CA_BUNDLE", "")
+	data := testData(1000)
+	sseKey := bytes.Repeat([]byte{'k'}, 32)
+	sum := md5.Sum(sseKey)
+	sseHeaders := map[string]string{
+		"X-Amz-Server-Side-Encryption-Customer-Algorithm": "AES256",
+		"X-Amz-Server-Side-Encryption-Customer-Key":       base64.StdEncoding.EncodeToString(sseKey),
+		"X-Amz-Server-Side-Encryption-Customer-Key-Md5":   base64.StdEncoding.EncodeToString(sum[:]),
+	}
+	for _, tt := range []struct {
+		name string
+		opts S3Options
+	}{
+		{name: "none"},
+		{name: "sse-c", opts: S3Options{SSECustomerKey: sseKey}},
+		{name: "requester pays", opts: S3Options{RequesterPays: true}},
+		{name: "both", opts: S3Options{SSECustomerKey: sseKey, RequesterPays: true}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &s3Bucket{obj: &httpObject{data: data, etag: `"v1"`}, headers: map[string]http.Header{}}
+			// the SDK refuses to send SSE-C keys over http
+			srv := httptest.NewTLSServer(b)
+			defer srv.Close()
+			sess, err := session.NewSession(&aws.Config{
+				Endpoint:         aws.String(srv.URL),
+				Region:           aws.String("us-east-1"),
+				S3ForcePathStyle: aws.Bool(true),
+				Credentials:      credentials.NewStaticCredentials("ak", "sk", ""),
+				HTTPClient:       srv.Client(),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			client := s3.New(sess)
+			ApplyS3Options(client, tt.opts)
+
+			ctx := context.Background()
+			r, err := NewS3Reader(ctx, "s3://bucket/dir/a.parquet", client)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make([]byte, 100)
+			if _, err := r.ReadAt(got, 900); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data[900:]) {
+				t.Error("got wrong bytes")
+			}
+			if _, err := List(ctx, "bucket", "dir/", client); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := ListVersions(ctx, "bucket", "dir/a.parquet", client); err != nil {
+				t.Fatal(err)
+			}
+
+			sseC := len(tt.opts.SSECustomerKey) > 0
+			if r.Private() != sseC {
+				t.Errorf("got Private %v, want %v", r.Private(), sseC)
+			}
+			for _, kind := range []string{http.MethodHead, http.MethodGet, "LIST", "VERSIONS"} {
+				h := b.headers[kind]
+				if h == nil {
+					t.Errorf("%s: no request", kind)
+					continue
+				}
+				// SSE-C keys only go with object requests
+				objectRequest := kind == http.MethodHead || kind == http.MethodGet
+				for name, value := range sseHeaders {
+					want := ""
+					if sseC && objectRequest {
+						want = value
+					}
+					if h.Get(name) != want {
+						t.Errorf("%s: got %s %q, want %q", kind, name, h.Get(name), want)
+					}
+				}
+				want := ""
+				if tt.opts.RequesterPays {
+					want = "requester"
+				}
+				if h.Get("X-Amz-Request-Payer") != want {
+					t.Errorf("%s: got X-Amz-Request-Payer %q, want %q", kind, h.Get("X-Amz-Request-Payer"), want)
+				}
+			}
+			if got := b.headers[http.MethodGet].Get("If-Match"); got != `"v1"` {
+				t.Errorf("GET: got If-Match %q, want the etag of the HEAD", got)
+			}
+		})
+	}
+}
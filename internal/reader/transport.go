@@ -0,0 +1,216 @@
+package reader
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+// httpClient is shared by all HTTP based readers and, through the cmd
+// package, by the S3 client.
+var httpClient = http.DefaultClient
+
+// SetHTTPClient replaces the client of all readers. It must be called
+// before any reader is created.
+func SetHTTPClient(c *http.Client) {
+	httpClient = c
+}
+
+// HTTPOptions configures the transport of the shared HTTP client.
+type HTTPOptions struct {
+	// CABundles are PEM files with certificates trusted in addition to
+	// the system roots.
+	CABundles []string
+	// ClientCert and ClientKey are PEM files for TLS client
+	// authentication.
+	ClientCert         string
+	ClientKey          string
+	InsecureSkipVerify bool
+	// HTTPProxy, HTTPSProxy and NoProxy override HTTP_PROXY, HTTPS_PROXY
+	// and NO_PROXY. Hosts in NoProxy are reached directly.
+	HTTPProxy  string
+	HTTPSProxy string
+	NoProxy    string
+	Hosts      []HostOptions
+}
+
+// HostOptions adds headers and credentials to the requests sent to hosts
+// matching one of Hosts, which are path.Match patterns for the host name
+// or host:port. They never replace headers set by a reader, such as the
+// S3 or Azure request signature, or by an earlier HostOptions, and are
+// not added to redirects that leave the host of the first request.
+type HostOptions struct {
+	Hosts       []string
+	Header      http.Header
+	Username    string
+	Password    string
+	BearerToken string
+}
+
+func (h HostOptions) match(u *url.URL) bool {
+	for _, pattern := range h.Hosts {
+		if ok, _ := path.Match(pattern, u.Hostname()); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, u.Host); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// NewHTTPClient builds a client from opts on top of the settings of
+// http.DefaultTransport.
+func NewHTTPClient(opts HTTPOptions) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	tlsConfig := &tls.Config{InsecureSkipVerify: opts.InsecureSkipVerify}
+	if len(opts.CABundles) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		for _, bundle := range opts.CABundles {
+			pem, err := os.ReadFile(bundle)
+			if err != nil {
+				return nil, err
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates found in %s", bundle)
+			}
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if opts.ClientCert != "" || opts.ClientKey != "" {
+		cert, err := tls.LoadX509KeyPair(opts.ClientCert, opts.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("error loading client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	transport.TLSClientConfig = tlsConfig
+	if opts.HTTPProxy != "" || opts.HTTPSProxy != "" || opts.NoProxy != "" {
+		proxy, err := proxyFunc(opts.HTTPProxy, opts.HTTPSProxy, opts.NoProxy)
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = proxy
+	}
+
+	var rt http.RoundTripper = transport
+	if len(opts.Hosts) > 0 {
+		rt = &hostTransport{base: transport, hosts: opts.Hosts}
+	}
+	return &http.Client{Transport: rt}, nil
+}
+
+func proxyFunc(httpProxy, httpsProxy, noProxy string) (func(*http.Request) (*url.URL, error), error) {
+	parse := func(s string) (*url.URL, error) {
+		if s == "" {
+			return nil, nil
+		}
+		if !strings.Contains(s, "://") {
+			s = "http://" + s
+		}
+		u, err := url.Parse(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy %q: %w", s, err)
+		}
+		return u, nil
+	}
+	env := func(value string, names ...string) string {
+		for _, name := range names {
+			if value == "" {
+				value = os.Getenv(name)
+			}
+		}
+		return value
+	}
+	httpProxy = env(httpProxy, "HTTP_PROXY", "http_proxy")
+	httpsProxy = env(httpsProxy, "HTTPS_PROXY", "https_proxy")
+	noProxy = env(noProxy, "NO_PROXY", "no_proxy")
+	httpURL, err := parse(httpProxy)
+	if err != nil {
+		return nil, err
+	}
+	httpsURL, err := parse(httpsProxy)
+	if err != nil {
+		return nil, err
+	}
+	var bypass []string
+	for _, h := range strings.Split(noProxy, ",") {
+		if h = strings.ToLower(strings.TrimSpace(h)); h != "" {
+			bypass = append(bypass, h)
+		}
+	}
+	return func(req *http.Request) (*url.URL, error) {
+		host := strings.ToLower(req.URL.Hostname())
+		for _, b := range bypass {
+			if b == "*" || b == host || b == strings.ToLower(req.URL.Host) {
+				return nil, nil
+			}
+			if _, _, err := net.SplitHostPort(b); err != nil && strings.HasSuffix(host, "."+strings.TrimPrefix(b, ".")) {
+				return nil, nil
+			}
+		}
+		if req.URL.Scheme == "https" {
+			return httpsURL, nil
+		}
+		return httpURL, nil
+	}, nil
+}
+
+// hostTransport adds the headers and credentials of the matching
+// HostOptions to each request.
+type hostTransport struct {
+	base  http.RoundTripper
+	hosts []HostOptions
+}
+
+func (t *hostTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// net/http drops the authorization of a redirect to another host,
+	// presigned and CDN URLs must not get it back from here
+	if first := firstRequest(req); !strings.EqualFold(first.URL.Host, req.URL.Host) {
+		return t.base.RoundTrip(req)
+	}
+	cloned := false
+	for _, h := range t.hosts {
+		if !h.match(req.URL) {
+			continue
+		}
+		// a RoundTripper must not modify the caller's request
+		if !cloned {
+			req = req.Clone(req.Context())
+			cloned = true
+		}
+		for k, vs := range h.Header {
+			if _, ok := req.Header[k]; !ok {
+				req.Header[k] = vs
+			}
+		}
+		if req.Header.Get("Authorization") != "" {
+			continue
+		}
+		switch {
+		case h.BearerToken != "":
+			req.Header.Set("Authorization", "Bearer "+h.BearerToken)
+		case h.Username != "":
+			req.SetBasicAuth(h.Username, h.Password)
+		}
+	}
+	return t.base.RoundTrip(req)
+}
+
+// firstRequest returns the request that started the redirects leading to
+// req, req itself when it is not a redirect.
+func firstRequest(req *http.Request) *http.Request {
+	for req.Response != nil && req.Response.Request != nil {
+		req = req.Response.Request
+	}
+	return req
+}
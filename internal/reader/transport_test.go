@@ -0,0 +1,79 @@
+package reader
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHostTransport(t *testing.T) {
+	type seen struct{ auth, team string }
+	var other, origin []seen
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		other = append(other, seen{r.Header.Get("Authorization"), r.Header.Get("X-Team")})
+	}))
+	defer target.Close()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin = append(origin, seen{r.Header.Get("Authorization"), r.Header.Get("X-Team")})
+		switch r.URL.Path {
+		case "/away":
+			http.Redirect(w, r, target.URL+"/data", http.StatusFound)
+		case "/here":
+			http.Redirect(w, r, "/data", http.StatusFound)
+		}
+	}))
+	defer srv.Close()
+
+	client, err := NewHTTPClient(HTTPOptions{Hosts: []HostOptions{{
+		Hosts:       []string{"*"},
+		Header:      http.Header{"X-Team": {"data"}},
+		BearerToken: "secret",
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"/away", "/here"} {
+		resp, err := client.Get(srv.URL + p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	want := seen{"Bearer secret", "data"}
+	if len(origin) != 3 || origin[0] != want || origin[1] != want || origin[2] != want {
+		t.Errorf("origin got %v, want %v three times", origin, want)
+	}
+	if len(other) != 1 || other[0] != (seen{}) {
+		t.Errorf("redirect target got %v, want no credentials or headers", other)
+	}
+
+	// a reader's own authorization is kept
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 sig")
+	origin = nil
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if origin[0].auth != "AWS4-HMAC-SHA256 sig" {
+		t.Errorf("got authorization %q", origin[0].auth)
+	}
+}
+
+func TestHostOptionsMatch(t *testing.T) {
+	h := HostOptions{Hosts: []string{"*.example.com", "artifacts:8080", `\[::1\]:9000`}}
+	for host, want := range map[string]bool{
+		"a.example.com":  true,
+		"example.com":    false,
+		"artifacts:8080": true,
+		"artifacts:9090": false,
+		"[::1]:9000":     true,
+		"[::1]:9001":     false,
+	} {
+		req, _ := http.NewRequest(http.MethodGet, "http://"+host+"/x", nil)
+		if got := h.match(req.URL); got != want {
+			t.Errorf("%s: got %v, want %v", host, got, want)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package parquettools
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/jimyag/parquet-tools/internal/reader"
+)
+
+const (
+	zipScheme = "zip"
+	tarScheme = "tar"
+
+	// archiveSep separates the archive from the entry in zip:// and
+	// tar:// URIs.
+	archiveSep = "!/"
+)
+
+func init() {
+	Register(zipScheme, OpenerFunc(openZip))
+	Register(tarScheme, OpenerFunc(openTar))
+}
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// splitArchive splits zip://<archive>!/<entry> into the URI of the
+// archive, itself a local path or a URI of any scheme, and the entry.
+func splitArchive(uri, scheme string) (archive, entry string, err error) {
+	rest := strings.TrimPrefix(uri, scheme+"://")
+	i := strings.LastIndex(rest, archiveSep)
+	if i <= 0 || i+len(archiveSep) == len(rest) {
+		return "", "", fmt.Errorf("invalid %s url %s, expected %s://<archive>%s<entry>", scheme, uri, scheme, archiveSep)
+	}
+	return rest[:i], entryName(rest[i+len(archiveSep):]), nil
+}
+
+// entryName normalizes the path of an archive entry.
+func entryName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// openArchive opens the archive with the opener of its scheme.
+func openArchive(ctx context.Context, archive string, opts Options) (*Object, error) {
+	u, err := url.Parse(archive)
+	if err != nil {
+		return nil, err
+	}
+	o, err := openerFor(u.Scheme)
+	if err != nil {
+		return nil, err
+	}
+	return o.Open(ctx, archive, opts)
+}
+
+// openZip reads stored entries in place and spools compressed ones.
+func openZip(ctx context.Context, uri string, opts Options) (*Object, error) {
+	archive, entry, err := splitArchive(uri, zipScheme)
+	if err != nil {
+		return nil, err
+	}
+	src, err := openArchive(ctx, archive, opts)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := zip.NewReader(src.Reader, src.Size)
+	if err != nil {
+		closeObject(src)
+		return nil, fmt.Errorf("error reading zip %s: %w", archive, err)
+	}
+	for _, f := range zr.File {
+		if entryName(f.Name) != entry || f.FileInfo().IsDir() {
+			continue
+		}
+		if f.Method == zip.Store {
+			off, err := f.DataOffset()
+			if err != nil {
+				closeObject(src)
+				return nil, err
+			}
+			return entryObject(src, entry, off, int64(f.UncompressedSize64)), nil
+		}
+		rc, err := f.Open()
+		if err != nil {
+			closeObject(src)
+			return nil, err
+		}
+		defer rc.Close()
+		return spoolEntry(src, rc, opts)
+	}
+	closeObject(src)
+	return nil, fmt.Errorf("no entry %s in %s", entry, archive)
+}
+
+// openTar reads the entries of an uncompressed tar in place, skipping
+// over the other entries without reading them. The entries of a gzipped
+// tar are spooled.
+func openTar(ctx context.Context, uri string, opts Options) (*Object, error) {
+	archive, entry, err := splitArchive(uri, tarScheme)
+	if err != nil {
+		return nil, err
+	}
+	src, err := openArchive(ctx, archive, opts)
+	if err != nil {
+		return nil, err
+	}
+	sr := io.NewSectionReader(src.Reader, 0, src.Size)
+	magic := make([]byte, len(gzipMagic))
+	if _, err := sr.ReadAt(magic, 0); err == nil && bytes.Equal(magic, gzipMagic) {
+		gz, err := gzip.NewReader(bufio.NewReaderSize(sr, 1<<20))
+		if err != nil {
+			closeObject(src)
+			return nil, fmt.Errorf("error reading tar %s: %w", archive, err)
+		}
+		tr := tar.NewReader(gz)
+		if _, err := findTarEntry(tr, entry, archive); err != nil {
+			closeObject(src)
+			return nil, err
+		}
+		return spoolEntry(src, tr, opts)
+	}
+	hdr, err := findTarEntry(tar.NewReader(sr), entry, archive)
+	if err != nil {
+		closeObject(src)
+		return nil, err
+	}
+	// the tar reader stops right after the header of the entry
+	off, err := sr.Seek(0, io.SeekCurrent)
+	if err != nil {
+		closeObject(src)
+		return nil, err
+	}
+	return entryObject(src, entry, off, hdr.Size), nil
+}
+
+// findTarEntry advances tr to the regular file named entry.
+func findTarEntry(tr *tar.Reader, entry, archive string) (*tar.Header, error) {
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("no entry %s in %s", entry, archive)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading tar %s: %w", archive, err)
+		}
+		if hdr.Typeflag == tar.TypeReg && entryName(hdr.Name) == entry {
+			return hdr, nil
+		}
+	}
+}
+
+// spoolEntry reads a compressed entry and closes the archive.
+func spoolEntry(src *Object, r io.Reader, opts Options) (*Object, error) {
+	defer closeObject(src)
+	spool, err := reader.NewSpoolReader(r, opts.SpoolMemory, opts.SpoolMaxSize)
+	if err != nil {
+		return nil, err
+	}
+	size, err := spool.Size()
+	if err != nil {
+		spool.Close()
+		return nil, err
+	}
+	return &Object{Reader: spool, Size: size}, nil
+}
+
+// entryObject reads an uncompressed entry at its offset in the archive.
+// It keeps the version and remoteness of the archive, so entries of
+// remote archives go through the block cache.
+func entryObject(src *Object, entry string, off, size int64) *Object {
+	return &Object{
+		Reader: &entryReader{
+			SectionReader: io.NewSectionReader(src.Reader, off, size),
+			src:           src.Reader,
+			off:           off,
+			entry:         entry,
+		},
+		Size:    size,
+		ETag:    src.ETag,
+		ModTime: src.ModTime,
+		Remote:  src.Remote,
+	}
+}
+
+func closeObject(obj *Object) {
+	if c, ok := obj.Reader.(io.Closer); ok {
+		c.Close()
+	}
+}
+
+// entryReader reads an entry stored at off in an archive.
+type entryReader struct {
+	*io.SectionReader
+	src   io.ReaderAt
+	off   int64
+	entry string
+}
+
+// Prefetch shifts the ranges to the entry and hands them to the archive
+// reader when it supports prefetching. Fetched sees them shifted back.
+func (r *entryReader) Prefetch(ranges []reader.Range, opts PrefetchOptions) error {
+	p, ok := r.src.(reader.Prefetcher)
+	if !ok {
+		return nil
+	}
+	shifted := make([]reader.Range, len(ranges))
+	for i, rg := range ranges {
+		shifted[i] = reader.Range{Offset: r.off + rg.Offset, Length: rg.Length}
+	}
+	if fetched := opts.Fetched; fetched != nil {
+		opts.Fetched = func(rg reader.Range, n int64, start time.Time, err error) {
+			fetched(reader.Range{Offset: rg.Offset - r.off, Length: rg.Length}, n, start, err)
+		}
+	}
+	return p.Prefetch(shifted, opts)
+}
+
+// CacheKey derives the key of the entry from the key of the archive, an
+// empty key when the archive cannot be identified.
+func (r *entryReader) CacheKey() string {
+	c, ok := r.src.(reader.Cacheable)
+	if !ok {
+		return ""
+	}
+	key := c.CacheKey()
+	if key == "" {
+		return ""
+	}
+	return key + archiveSep + r.entry
+}
+
+func (r *entryReader) Close() error {
+	if c, ok := r.src.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package parquettools
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/apache/arrow/go/v17/parquet/schema"
+)
+
+// SelectColumns returns the indexes of the leaf columns matching any of
+// the selectors, in schema order. A selector is a dot separated column
+// path such as "a.b" or "list.list.element", matched against the path of
+// the leaf columns segment by segment. It selects the columns it names and
+// all leaf columns below a group it names, and a segment can hold the
+// wildcards of path.Match, as in "*.id". A selector that matches no column
+// is an error.
+func SelectColumns(sc *schema.Schema, selectors []string) ([]int, error) {
+	matched := make([]bool, sc.NumColumns())
+	for _, sel := range selectors {
+		found := false
+		for c := range sc.NumColumns() {
+			if matchColumn(sel, sc.Column(c).ColumnPath()) {
+				matched[c], found = true, true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no column matches %q", sel)
+		}
+	}
+	var cols []int
+	for c, ok := range matched {
+		if ok {
+			cols = append(cols, c)
+		}
+	}
+	return cols, nil
+}
+
+// matchColumn reports whether the selector matches the column path or a
+// group above it.
+func matchColumn(sel string, colPath []string) bool {
+	segs := strings.Split(sel, ".")
+	if len(segs) > len(colPath) {
+		return false
+	}
+	for i, seg := range segs {
+		if ok, _ := path.Match(seg, colPath[i]); !ok {
+			return false
+		}
+	}
+	return true
+}
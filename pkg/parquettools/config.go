@@ -0,0 +1,164 @@
+package parquettools
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+
+	"github.com/BurntSushi/toml"
+	"github.com/jimyag/log"
+
+	"github.com/jimyag/parquet-tools/internal/reader"
+)
+
+// Config holds the entries used to reach remote files, as found in the
+// toml config file of parquet-tools.
+type Config struct {
+	S3      []S3Config      `toml:"s3" json:"s3"`
+	GCS     []GCSConfig     `toml:"gcs" json:"gcs"`
+	Azure   []AzureConfig   `toml:"azure" json:"azure"`
+	WebHDFS []WebHDFSConfig `toml:"webhdfs" json:"webhdfs"`
+
+	Transport TransportConfig `toml:"transport,omitempty" json:"transport,omitempty"`
+	HTTP      []HTTPConfig    `toml:"http,omitempty" json:"http,omitempty"`
+}
+
+// LoadConfig decodes a config file. A missing file is an empty config.
+func LoadConfig(name string) (Config, error) {
+	cfg := Config{}
+	if _, err := toml.DecodeFile(name, &cfg); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+const (
+	CredentialSourceStatic            = "static"
+	CredentialSourceSessionToken      = "session_token"
+	CredentialSourceEnv               = "env"
+	CredentialSourceProfile           = "profile"
+	CredentialSourceAssumeRole        = "assume_role"
+	CredentialSourceCredentialProcess = "credential_process"
+	CredentialSourceDefault           = "default"
+)
+
+type S3Config struct {
+	// Name selects the entry with s3://name@bucket/key or Options.S3Profile.
+	Name           string `toml:"name,omitempty" json:"name,omitempty"`
+	Region         string `toml:"region" json:"region"`
+	AccessKey      string `toml:"access_key" json:"access_key"`
+	SecretKey      string `toml:"secret_key" json:"secret_key"`
+	DisableSSL     bool   `toml:"disable_ssl" json:"disable_ssl"`
+	ForcePathStyle bool   `toml:"force_path_style" json:"force_path_style"`
+	EndPoint       string `toml:"endpoint" json:"endpoint"`
+	// Scopes are bucket names or path.Match patterns such as "logs-*".
+	Scopes []string `toml:"scopes" json:"scopes"`
+
+	// CredentialSource selects how credentials are obtained. When empty the
+	// static keys are used if set, the default AWS chain otherwise.
+	CredentialSource  string `toml:"credential_source,omitempty" json:"credential_source,omitempty"`
+	SessionToken      string `toml:"session_token,omitempty" json:"session_token,omitempty"`
+	Profile           string `toml:"profile,omitempty" json:"profile,omitempty"`
+	RoleARN           string `toml:"role_arn,omitempty" json:"role_arn,omitempty"`
+	ExternalID        string `toml:"external_id,omitempty" json:"external_id,omitempty"`
+	RoleSessionName   string `toml:"role_session_name,omitempty" json:"role_session_name,omitempty"`
+	CredentialProcess string `toml:"credential_process,omitempty" json:"credential_process,omitempty"`
+
+	// SSECustomerKey is the base64 encoded 256 bit SSE-C key. It can also
+	// be read from a file holding the raw or base64 encoded key, or from
+	// an environment variable holding the base64 encoded key.
+	SSECustomerKey     string `toml:"sse_customer_key,omitempty" json:"sse_customer_key,omitempty"`
+	SSECustomerKeyFile string `toml:"sse_customer_key_file,omitempty" json:"sse_customer_key_file,omitempty"`
+	SSECustomerKeyEnv  string `toml:"sse_customer_key_env,omitempty" json:"sse_customer_key_env,omitempty"`
+	RequesterPays      bool   `toml:"requester_pays,omitempty" json:"requester_pays,omitempty"`
+}
+
+type GCSConfig struct {
+	// EndPoint defaults to the public GCS endpoint, set it to use a
+	// fake-gcs-server.
+	EndPoint        string   `toml:"endpoint" json:"endpoint"`
+	CredentialsFile string   `toml:"credentials_file" json:"credentials_file"`
+	Scopes          []string `toml:"scopes" json:"scopes"`
+}
+
+type AzureConfig struct {
+	Account    string `toml:"account" json:"account"`
+	AccountKey string `toml:"account_key" json:"account_key"`
+	SASToken   string `toml:"sas_token" json:"sas_token"`
+	// EndPoint defaults to https://<account>.blob.core.windows.net, set it
+	// to use Azurite.
+	EndPoint string   `toml:"endpoint" json:"endpoint"`
+	Scopes   []string `toml:"scopes" json:"scopes"`
+}
+
+type WebHDFSConfig struct {
+	// User is sent as user.name for simple (pseudo) authentication.
+	User string `toml:"user" json:"user"`
+	// Scopes are namenode host:port pairs.
+	Scopes []string `toml:"scopes" json:"scopes"`
+}
+
+type TransportConfig struct {
+	CABundle           string `toml:"ca_bundle,omitempty" json:"ca_bundle,omitempty"`
+	ClientCert         string `toml:"client_cert,omitempty" json:"client_cert,omitempty"`
+	ClientKey          string `toml:"client_key,omitempty" json:"client_key,omitempty"`
+	InsecureSkipVerify bool   `toml:"insecure_skip_verify,omitempty" json:"insecure_skip_verify,omitempty"`
+	HTTPProxy          string `toml:"http_proxy,omitempty" json:"http_proxy,omitempty"`
+	HTTPSProxy         string `toml:"https_proxy,omitempty" json:"https_proxy,omitempty"`
+	NoProxy            string `toml:"no_proxy,omitempty" json:"no_proxy,omitempty"`
+}
+
+// HTTPConfig adds headers and credentials to the requests sent to the hosts
+// matching Hosts.
+type HTTPConfig struct {
+	// Hosts are path.Match patterns such as "*.example.com" or
+	// "artifacts:8080".
+	Hosts       []string          `toml:"hosts" json:"hosts"`
+	Headers     map[string]string `toml:"headers,omitempty" json:"headers,omitempty"`
+	Username    string            `toml:"username,omitempty" json:"username,omitempty"`
+	Password    string            `toml:"password,omitempty" json:"password,omitempty"`
+	BearerToken string            `toml:"bearer_token,omitempty" json:"bearer_token,omitempty"`
+	// BearerTokenEnv names an environment variable holding the token.
+	BearerTokenEnv string `toml:"bearer_token_env,omitempty" json:"bearer_token_env,omitempty"`
+}
+
+// NewHTTPClient builds a client from the [transport] and [[http]] sections
+// of cfg. AWS_CA_BUNDLE is trusted in addition to the CA bundle, and
+// earlier [[http]] entries win over later ones.
+func NewHTTPClient(cfg Config) (*http.Client, error) {
+	t := cfg.Transport
+	opts := reader.HTTPOptions{
+		ClientCert:         t.ClientCert,
+		ClientKey:          t.ClientKey,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+		HTTPProxy:          t.HTTPProxy,
+		HTTPSProxy:         t.HTTPSProxy,
+		NoProxy:            t.NoProxy,
+	}
+	for _, bundle := range []string{t.CABundle, os.Getenv("AWS_CA_BUNDLE")} {
+		if bundle != "" {
+			opts.CABundles = append(opts.CABundles, bundle)
+		}
+	}
+	for _, c := range cfg.HTTP {
+		h := reader.HostOptions{
+			Hosts:       c.Hosts,
+			Header:      http.Header{},
+			Username:    c.Username,
+			Password:    c.Password,
+			BearerToken: c.BearerToken,
+		}
+		for name, value := range c.Headers {
+			h.Header.Set(name, value)
+		}
+		if c.BearerTokenEnv != "" {
+			h.BearerToken = os.Getenv(c.BearerTokenEnv)
+			if h.BearerToken == "" {
+				log.Warn().Msgf("bearer_token_env %s is not set", c.BearerTokenEnv)
+			}
+		}
+		opts.Hosts = append(opts.Hosts, h)
+	}
+	return reader.NewHTTPClient(opts)
+}
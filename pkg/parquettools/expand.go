@@ -0,0 +1,214 @@
+package parquettools
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Expand replaces local directories, shell-style globs (with ** for any
+// number of directories), and the prefixes ending in "/" and globs of the
+// schemes whose Opener is a Lister, such as s3, with the files they
+// contain, in lexical order. Explicitly named files are kept as they are.
+// Found files are filtered with opts.Include and opts.Exclude, glob
+// patterns that apply to the base name when they have no slash and to the
+// path relative to the expanded root otherwise.
+func Expand(ctx context.Context, args []string, opts Options) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		u, err := url.Parse(arg)
+		if err != nil {
+			return nil, err
+		}
+		var expanded []string
+		if u.Scheme == "" || u.Scheme == localScheme {
+			expanded, err = expandLocal(arg, opts)
+		} else if l, ok := listerFor(u); ok {
+			expanded, err = expandRemote(ctx, u, l, opts)
+		} else {
+			expanded = []string{arg}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(expanded) == 0 {
+			return nil, fmt.Errorf("no files found in %s", arg)
+		}
+		files = append(files, expanded...)
+	}
+	return files, nil
+}
+
+// listerFor returns the Lister of the scheme of u when u is a prefix
+// ending in "/", the root of a bucket or a glob.
+func listerFor(u *url.URL) (Lister, bool) {
+	key := strings.TrimPrefix(u.Path, "/")
+	if !hasGlobMeta(key) && key != "" && !strings.HasSuffix(key, "/") {
+		return nil, false
+	}
+	o, err := openerFor(u.Scheme)
+	if err != nil {
+		return nil, false
+	}
+	l, ok := o.(Lister)
+	return l, ok
+}
+
+func expandLocal(arg string, opts Options) ([]string, error) {
+	name := strings.TrimPrefix(arg, localScheme+"://")
+	if !hasGlobMeta(name) {
+		info, err := os.Stat(name)
+		if err != nil || !info.IsDir() {
+			return []string{arg}, nil
+		}
+		return walkLocal(name, "**", opts)
+	}
+	base, pattern := splitGlob(filepath.ToSlash(name))
+	if base == "" {
+		base = "."
+		if strings.HasPrefix(name, "/") {
+			base = "/"
+		}
+	}
+	return walkLocal(filepath.FromSlash(base), pattern, opts)
+}
+
+// walkLocal returns the files below root whose slash separated path
+// relative to root matches pattern.
+func walkLocal(root, pattern string, opts Options) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		if keepExpanded(filepath.ToSlash(rel), pattern, opts) {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(files)
+	return files, nil
+}
+
+// expandRemote expands the prefixes and globs of a scheme whose opener is
+// a Lister. The path of the URL is the key, the host the bucket.
+func expandRemote(ctx context.Context, u *url.URL, l Lister, opts Options) ([]string, error) {
+	key := strings.TrimPrefix(u.Path, "/")
+	prefix, pattern := key, "**"
+	if hasGlobMeta(key) {
+		prefix, pattern = splitGlob(key)
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	// keep the s3://profile@ override on the expanded files
+	host := u.Host
+	if u.User != nil {
+		host = u.User.Username() + "@" + host
+	}
+	root := u.Scheme + "://" + host + "/" + prefix
+	found, err := l.List(ctx, root, opts)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, f := range found {
+		if keepExpanded(strings.TrimPrefix(f, root), pattern, opts) {
+			files = append(files, f)
+		}
+	}
+	slices.Sort(files)
+	return files, nil
+}
+
+// keepExpanded reports whether a file found while expanding an input is
+// read. rel is its slash separated path relative to the expanded root.
+// Hidden and marker files such as _SUCCESS, .crc files and anything below
+// _temporary are skipped.
+func keepExpanded(rel, pattern string, opts Options) bool {
+	for _, seg := range strings.Split(rel, "/") {
+		if strings.HasPrefix(seg, "_") || strings.HasPrefix(seg, ".") {
+			return false
+		}
+	}
+	if strings.HasSuffix(rel, ".crc") || !matchGlob(pattern, rel) {
+		return false
+	}
+	if len(opts.Include) > 0 && !slices.ContainsFunc(opts.Include, func(p string) bool {
+		return matchFilter(p, rel)
+	}) {
+		return false
+	}
+	return !slices.ContainsFunc(opts.Exclude, func(p string) bool {
+		return matchFilter(p, rel)
+	})
+}
+
+// matchFilter matches Include and Exclude patterns. Patterns without a
+// slash apply to the base name, the others to the relative path.
+func matchFilter(pattern, rel string) bool {
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(rel))
+		return ok
+	}
+	return matchGlob(pattern, rel)
+}
+
+func hasGlobMeta(s string) bool {
+	return strings.ContainsAny(s, "*?[")
+}
+
+// splitGlob splits a slash separated pattern into the directories before
+// the first segment with glob characters and the remaining pattern.
+func splitGlob(p string) (base, pattern string) {
+	segs := strings.Split(p, "/")
+	for i, seg := range segs {
+		if hasGlobMeta(seg) {
+			return strings.Join(segs[:i], "/"), strings.Join(segs[i:], "/")
+		}
+	}
+	return p, ""
+}
+
+// matchGlob reports whether the slash separated name matches pattern,
+// where a "**" segment matches any number of directories.
+func matchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := len(name); i >= 0; i-- {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
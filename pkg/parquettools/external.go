@@ -0,0 +1,191 @@
+package parquettools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ExternalOpener serves a scheme with a binary named
+// parquet-tools-reader-<scheme>, found on PATH, over stdin and stdout.
+//
+//	parquet-tools-reader-<scheme> open <uri>
+//
+// prints one line of JSON with the size of the file and optionally its
+// etag and modification time (RFC 3339), or an error:
+//
+//	{"size": 1234, "etag": "abc", "mod_time": "2024-01-02T15:04:05Z"}
+//	{"error": "no such file"}
+//
+// It then answers each "read <offset> <length>" line on stdin with
+// "ok <n>" followed by n bytes, fewer than length only at the end of the
+// file, or with "error <message>". It exits when stdin is closed.
+//
+//	parquet-tools-reader-<scheme> list <prefix>
+//
+// prints the URIs of the files starting with prefix, one per line. On
+// failure it exits with a non-zero status and a message on stderr. Files of
+// an external scheme go through the block cache when they have an etag or
+// a modification time.
+type ExternalOpener struct {
+	Path string
+}
+
+type externalHeader struct {
+	Size    int64     `json:"size"`
+	ETag    string    `json:"etag"`
+	ModTime time.Time `json:"mod_time"`
+	Error   string    `json:"error"`
+}
+
+func (o *ExternalOpener) Open(ctx context.Context, uri string, opts Options) (*Object, error) {
+	cmd := exec.CommandContext(ctx, o.Path, "open", uri)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	r := &externalReader{
+		uri:    uri,
+		cmd:    cmd,
+		stdin:  stdin,
+		stdout: bufio.NewReader(stdout),
+	}
+	line, err := r.stdout.ReadBytes('\n')
+	if err != nil {
+		r.Close()
+		return nil, fmt.Errorf("%s open: %w", o.Path, err)
+	}
+	var h externalHeader
+	if err := json.Unmarshal(line, &h); err != nil {
+		r.Close()
+		return nil, fmt.Errorf("%s open: %w", o.Path, err)
+	}
+	if h.Error != "" {
+		r.Close()
+		return nil, errors.New(h.Error)
+	}
+	r.size = h.Size
+	return &Object{
+		Reader:  r,
+		Size:    h.Size,
+		ETag:    h.ETag,
+		ModTime: h.ModTime,
+		Remote:  true,
+	}, nil
+}
+
+func (o *ExternalOpener) List(ctx context.Context, prefix string, opts Options) ([]string, error) {
+	out, err := exec.CommandContext(ctx, o.Path, "list", prefix).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("%s list: %s", o.Path, bytes.TrimSpace(exitErr.Stderr))
+		}
+		return nil, fmt.Errorf("%s list: %w", o.Path, err)
+	}
+	var files []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			files = append(files, line)
+		}
+	}
+	return files, nil
+}
+
+// externalReader reads a file through a running external reader. Reads
+// are serialized, the protocol has one request in flight.
+type externalReader struct {
+	uri    string
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	size   int64
+
+	mu     sync.Mutex
+	offset int64
+}
+
+func (r *externalReader) ReadAt(p []byte, off int64) (int, error) {
+	if off >= r.size {
+		return 0, io.EOF
+	}
+	length := min(int64(len(p)), r.size-off)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := fmt.Fprintf(r.stdin, "read %d %d\n", off, length); err != nil {
+		return 0, fmt.Errorf("error reading %s: %w", r.uri, err)
+	}
+	line, err := r.stdout.ReadString('\n')
+	if err != nil {
+		return 0, fmt.Errorf("error reading %s: %w", r.uri, err)
+	}
+	status, arg, _ := strings.Cut(strings.TrimSuffix(line, "\n"), " ")
+	switch status {
+	case "ok":
+		n, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil || n < 0 || n > length {
+			return 0, fmt.Errorf("error reading %s: invalid reply %q", r.uri, line)
+		}
+		if _, err := io.ReadFull(r.stdout, p[:n]); err != nil {
+			return 0, fmt.Errorf("error reading %s: %w", r.uri, err)
+		}
+		if n < int64(len(p)) {
+			return int(n), io.EOF
+		}
+		return int(n), nil
+	case "error":
+		return 0, fmt.Errorf("error reading %s: %s", r.uri, arg)
+	}
+	return 0, fmt.Errorf("error reading %s: invalid reply %q", r.uri, line)
+}
+
+func (r *externalReader) Read(p []byte) (int, error) {
+	n, err := r.ReadAt(p, r.offset)
+	r.offset += int64(n)
+	return n, err
+}
+
+func (r *externalReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.New("parquettools.externalReader.Seek: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("parquettools.externalReader.Seek: negative position")
+	}
+	r.offset = offset
+	return r.offset, nil
+}
+
+func (r *externalReader) Size() (int64, error) {
+	return r.size, nil
+}
+
+// Close closes stdin, which ends the external reader, and waits for it.
+func (r *externalReader) Close() error {
+	r.stdin.Close()
+	return r.cmd.Wait()
+}
@@ -0,0 +1,184 @@
+package parquettools
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/apache/arrow/go/v17/parquet"
+	"github.com/apache/arrow/go/v17/parquet/compress"
+	"github.com/apache/arrow/go/v17/parquet/file"
+	"github.com/apache/arrow/go/v17/parquet/metadata"
+	"github.com/apache/arrow/go/v17/parquet/schema"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// FileMeta is the metadata of a file, as printed by the meta command.
+type FileMeta struct {
+	Version   parquet.Version
+	CreatedBy string
+	NumRows   int64
+	// HasKeyValue is set when the footer has key value metadata, even an
+	// empty list.
+	HasKeyValue    bool
+	KeyValue       []KeyValue
+	NumRowGroups   int
+	NumRealColumns int
+	NumColumns     int
+	RowGroups      []RowGroupMeta
+	Schema         string
+}
+
+type KeyValue struct {
+	Key   string
+	Value string
+}
+
+type RowGroupMeta struct {
+	TotalByteSize int64
+	NumRows       int64
+	Columns       []ColumnChunkMeta
+}
+
+// ColumnChunkMeta describes a column chunk. The statistics are only set
+// when the writer stored them, Min and Max are strings for byte arrays.
+type ColumnChunkMeta struct {
+	// Column is the index of the leaf column, see SelectColumns.
+	Column        int
+	Name          string
+	NumValues     int64
+	HasStats      bool
+	HasMinMax     bool
+	Min           any
+	Max           any
+	NullCount     *int64
+	DistinctCount *int64
+
+	Compression      compress.Compression
+	Encodings        []parquet.Encoding
+	UncompressedSize int64
+	CompressedSize   int64
+}
+
+// InspectMeta collects the file, row group and column chunk metadata.
+func InspectMeta(rdr *file.Reader) (*FileMeta, error) {
+	fileMetadata := rdr.MetaData()
+	m := &FileMeta{
+		Version:        fileMetadata.Version(),
+		CreatedBy:      fileMetadata.GetCreatedBy(),
+		NumRows:        rdr.NumRows(),
+		NumRowGroups:   rdr.NumRowGroups(),
+		NumRealColumns: fileMetadata.Schema.Root().NumFields(),
+		NumColumns:     fileMetadata.Schema.NumColumns(),
+		Schema:         fileMetadata.Schema.String(),
+	}
+	if kvMeta := fileMetadata.KeyValueMetadata(); kvMeta != nil {
+		m.HasKeyValue = true
+		keys := kvMeta.Keys()
+		values := kvMeta.Values()
+		for i := 0; i < kvMeta.Len(); i++ {
+			m.KeyValue = append(m.KeyValue, KeyValue{Key: keys[i], Value: values[i]})
+		}
+	}
+	for r := 0; r < rdr.NumRowGroups(); r++ {
+		rgr := rdr.RowGroup(r)
+		rowGroupMeta := rgr.MetaData()
+		rg := RowGroupMeta{
+			TotalByteSize: rowGroupMeta.TotalByteSize(),
+			NumRows:       rgr.NumRows(),
+		}
+		for c := range fileMetadata.Schema.NumColumns() {
+			chunkMeta, err := rowGroupMeta.ColumnChunk(c)
+			if err != nil {
+				return nil, fmt.Errorf("error getting column chunk metadata: %w", err)
+			}
+			col, err := columnChunkMeta(fileMetadata.Schema.Column(c).Name(), chunkMeta)
+			if err != nil {
+				return nil, err
+			}
+			col.Column = c
+			rg.Columns = append(rg.Columns, col)
+		}
+		m.RowGroups = append(m.RowGroups, rg)
+	}
+	return m, nil
+}
+
+func columnChunkMeta(name string, chunkMeta *metadata.ColumnChunkMetaData) (ColumnChunkMeta, error) {
+	col := ColumnChunkMeta{
+		Name:             name,
+		NumValues:        chunkMeta.NumValues(),
+		Compression:      chunkMeta.Compression(),
+		Encodings:        chunkMeta.Encodings(),
+		UncompressedSize: chunkMeta.TotalUncompressedSize(),
+		CompressedSize:   chunkMeta.TotalCompressedSize(),
+	}
+	if set, _ := chunkMeta.StatsSet(); !set {
+		return col, nil
+	}
+	stats, err := chunkMeta.Statistics()
+	if err != nil {
+		return col, fmt.Errorf("error getting column chunk statistics: %w", err)
+	}
+	col.HasStats = true
+	if stats.HasMinMax() {
+		col.HasMinMax = true
+		col.Min = statValue(stats.Type(), stats.EncodeMin())
+		col.Max = statValue(stats.Type(), stats.EncodeMax())
+	}
+	if stats.HasNullCount() {
+		n := stats.NullCount()
+		col.NullCount = &n
+	}
+	if stats.HasDistinctCount() {
+		n := stats.DistinctCount()
+		col.DistinctCount = &n
+	}
+	return col, nil
+}
+
+func statValue(typ parquet.Type, encoded []byte) any {
+	v := metadata.GetStatValue(typ, encoded)
+	if b, ok := v.([]byte); ok {
+		return string(b)
+	}
+	return v
+}
+
+// Schema is the schema of a file, as its node tree and in the text format
+// of schema.PrintSchema.
+type Schema struct {
+	Root *schema.GroupNode
+	Text string
+}
+
+func InspectSchema(rdr *file.Reader) *Schema {
+	root := rdr.MetaData().Schema.Root()
+	var buf bytes.Buffer
+	schema.PrintSchema(root, &buf, 2)
+	return &Schema{Root: root, Text: buf.String()}
+}
+
+// InspectFooter returns the footer of a file, which marshals to the json
+// printed by the footer command.
+func InspectFooter(rdr *file.Reader) *metadata.FileMetaData {
+	return rdr.MetaData()
+}
+
+// SchemaDiff is the difference between the schemas of two files.
+type SchemaDiff struct {
+	Left  *Schema
+	Right *Schema
+	Diffs []diffmatchpatch.Diff
+}
+
+// Equal reports whether both schemas are the same.
+func (d *SchemaDiff) Equal() bool {
+	return d.Left.Text == d.Right.Text
+}
+
+// InspectDiff compares the schemas of two files.
+func InspectDiff(left, right *file.Reader) *SchemaDiff {
+	d := &SchemaDiff{Left: InspectSchema(left), Right: InspectSchema(right)}
+	d.Diffs = diffmatchpatch.New().DiffMain(d.Left.Text, d.Right.Text, true)
+	return d
+}
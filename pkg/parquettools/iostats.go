@@ -0,0 +1,319 @@
+package parquettools
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/apache/arrow/go/v17/parquet"
+	"github.com/apache/arrow/go/v17/parquet/metadata"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+
+	"github.com/jimyag/parquet-tools/internal/reader"
+)
+
+// IOStats records the reads of the files opened with it, see
+// Options.IOStats. It is safe for concurrent use.
+type IOStats struct {
+	start time.Time
+
+	mu    sync.Mutex
+	files []*fileStats
+}
+
+func NewIOStats() *IOStats {
+	return &IOStats{start: time.Now()}
+}
+
+// IORead is one read of a file, or one range of a prefetch.
+type IORead struct {
+	Offset int64
+	Length int64
+	// N is the number of bytes read.
+	N        int64
+	Start    time.Time
+	Duration time.Duration
+	Prefetch bool
+	Err      error
+	// Label names the part of the file read, such as "footer" or
+	// "rg 0 a.b", with "(+n more)" when the read spans several parts.
+	Label string
+}
+
+// FileIOStats are the reads of one file.
+type FileIOStats struct {
+	URI   string
+	Size  int64
+	Reads []IORead
+}
+
+type fileStats struct {
+	uri  string
+	size int64
+
+	mu      sync.Mutex
+	reads   []IORead
+	regions []region
+}
+
+// region is a labeled byte range of a file.
+type region struct {
+	start, end int64
+	label      string
+}
+
+// Files returns the reads of every file in the order the files were
+// opened.
+func (s *IOStats) Files() []FileIOStats {
+	s.mu.Lock()
+	files := slices.Clone(s.files)
+	s.mu.Unlock()
+	out := make([]FileIOStats, len(files))
+	for i, f := range files {
+		f.mu.Lock()
+		reads := slices.Clone(f.reads)
+		for j := range reads {
+			reads[j].Label = f.label(reads[j].Offset, reads[j].Length)
+		}
+		out[i] = FileIOStats{URI: f.uri, Size: f.size, Reads: reads}
+		f.mu.Unlock()
+	}
+	return out
+}
+
+// WriteSummary writes a table of the requests, bytes and latency of each
+// file. Prefetches are counted apart, the reads they served are counted
+// as reads as well.
+func (s *IOStats) WriteSummary(w io.Writer) error {
+	t := table.NewWriter()
+	t.Style().Options.DrawBorder = true
+	t.Style().Format.Footer = text.FormatDefault
+	t.AppendHeader(table.Row{"file", "reads", "bytes", "read time", "max latency", "prefetches", "prefetched bytes", "errors"})
+	var total ioTotals
+	files := s.Files()
+	for _, f := range files {
+		var ft ioTotals
+		for _, r := range f.Reads {
+			ft.add(r)
+		}
+		total.merge(ft)
+		t.AppendRow(ft.row(fmt.Sprintf("%s (%d bytes)", f.URI, f.Size)))
+	}
+	if len(files) > 1 {
+		t.AppendFooter(total.row("total"))
+	}
+	_, err := fmt.Fprintln(w, t.Render())
+	return err
+}
+
+type ioTotals struct {
+	reads, bytes           int64
+	prefetches, prefetched int64
+	errors                 int64
+	time, max              time.Duration
+}
+
+func (t *ioTotals) add(r IORead) {
+	if r.Err != nil {
+		t.errors++
+	}
+	if r.Prefetch {
+		t.prefetches++
+		t.prefetched += r.N
+		return
+	}
+	t.reads++
+	t.bytes += r.N
+	t.time += r.Duration
+	t.max = max(t.max, r.Duration)
+}
+
+func (t *ioTotals) merge(o ioTotals) {
+	t.reads += o.reads
+	t.bytes += o.bytes
+	t.prefetches += o.prefetches
+	t.prefetched += o.prefetched
+	t.errors += o.errors
+	t.time += o.time
+	t.max = max(t.max, o.max)
+}
+
+func (t ioTotals) row(name string) table.Row {
+	return table.Row{name, t.reads, t.bytes, t.time.Round(time.Microsecond), t.max.Round(time.Microsecond), t.prefetches, t.prefetched, t.errors}
+}
+
+// traceEvent is an event of the Chrome trace event format, which
+// chrome://tracing and https://ui.perfetto.dev open.
+type traceEvent struct {
+	Name  string         `json:"name"`
+	Cat   string         `json:"cat,omitempty"`
+	Phase string         `json:"ph"`
+	TS    int64          `json:"ts"`
+	Dur   int64          `json:"dur,omitempty"`
+	PID   int            `json:"pid"`
+	TID   int            `json:"tid"`
+	Args  map[string]any `json:"args,omitempty"`
+}
+
+// WriteTrace writes the reads as a Chrome trace, one thread per file and
+// one event per read named after the part of the file it served.
+func (s *IOStats) WriteTrace(w io.Writer) error {
+	events := []traceEvent{{Name: "process_name", Phase: "M", PID: 1, Args: map[string]any{"name": "parquet-tools"}}}
+	for i, f := range s.Files() {
+		tid := i + 1
+		events = append(events, traceEvent{Name: "thread_name", Phase: "M", PID: 1, TID: tid, Args: map[string]any{"name": f.URI}})
+		for _, r := range f.Reads {
+			e := traceEvent{
+				Name:  r.Label,
+				Cat:   "read",
+				Phase: "X",
+				TS:    r.Start.Sub(s.start).Microseconds(),
+				Dur:   max(r.Duration.Microseconds(), 1),
+				PID:   1,
+				TID:   tid,
+				Args:  map[string]any{"offset": r.Offset, "length": r.Length, "bytes": r.N},
+			}
+			if r.Prefetch {
+				e.Cat = "prefetch"
+			}
+			if r.Err != nil {
+				e.Args["error"] = r.Err.Error()
+			}
+			events = append(events, e)
+		}
+	}
+	return json.NewEncoder(w).Encode(map[string]any{"traceEvents": events, "displayTimeUnit": "ms"})
+}
+
+func (s *IOStats) wrap(uri string, size int64, src parquet.ReaderAtSeeker) *statsReader {
+	f := &fileStats{uri: uri, size: size}
+	s.mu.Lock()
+	s.files = append(s.files, f)
+	s.mu.Unlock()
+	return &statsReader{src: src, size: size, f: f}
+}
+
+func (f *fileStats) record(r IORead) {
+	f.mu.Lock()
+	f.reads = append(f.reads, r)
+	f.mu.Unlock()
+}
+
+// setMetaData labels the column chunks and the footer of the file.
+func (f *fileStats) setMetaData(meta *metadata.FileMetaData) {
+	regions := []region{{start: 0, end: 4, label: "header"}}
+	for i := range meta.RowGroups {
+		rg := meta.RowGroup(i)
+		for j := 0; j < rg.NumColumns(); j++ {
+			chunk, err := rg.ColumnChunk(j)
+			if err != nil {
+				continue
+			}
+			start := chunk.DataPageOffset()
+			if chunk.HasDictionaryPage() && chunk.DictionaryPageOffset() > 0 && start > chunk.DictionaryPageOffset() {
+				start = chunk.DictionaryPageOffset()
+			}
+			regions = append(regions, region{
+				start: start,
+				end:   start + chunk.TotalCompressedSize(),
+				label: fmt.Sprintf("rg %d %s", i, chunk.PathInSchema()),
+			})
+		}
+	}
+	regions = append(regions, region{start: f.size - 8 - int64(meta.Size()), end: f.size, label: "footer"})
+	f.mu.Lock()
+	f.regions = regions
+	f.mu.Unlock()
+}
+
+// label names the regions a read overlaps. It is called with f.mu held.
+func (f *fileStats) label(off, length int64) string {
+	var labels []string
+	for _, r := range f.regions {
+		if off < r.end && off+length > r.start {
+			labels = append(labels, r.label)
+		}
+	}
+	switch len(labels) {
+	case 0:
+		return fmt.Sprintf("bytes %d-%d", off, off+length-1)
+	case 1:
+		return labels[0]
+	}
+	return fmt.Sprintf("%s (+%d more)", labels[0], len(labels)-1)
+}
+
+// statsReader records the reads and prefetches of a file.
+type statsReader struct {
+	src    parquet.ReaderAtSeeker
+	size   int64
+	f      *fileStats
+	offset int64
+}
+
+func (r *statsReader) setMetaData(meta *metadata.FileMetaData) {
+	r.f.setMetaData(meta)
+}
+
+func (r *statsReader) ReadAt(p []byte, off int64) (int, error) {
+	start := time.Now()
+	n, err := r.src.ReadAt(p, off)
+	read := IORead{Offset: off, Length: int64(len(p)), N: int64(n), Start: start, Duration: time.Since(start)}
+	if err != nil && !errors.Is(err, io.EOF) {
+		read.Err = err
+	}
+	r.f.record(read)
+	return n, err
+}
+
+func (r *statsReader) Read(p []byte) (int, error) {
+	n, err := r.ReadAt(p, r.offset)
+	r.offset += int64(n)
+	return n, err
+}
+
+func (r *statsReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.New("parquettools.statsReader.Seek: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("parquettools.statsReader.Seek: negative position")
+	}
+	r.offset = offset
+	return r.offset, nil
+}
+
+// Prefetch records one event per request the underlying reader makes,
+// ranges it already holds or does not fetch are not recorded.
+func (r *statsReader) Prefetch(ranges []reader.Range, opts PrefetchOptions) error {
+	p, ok := r.src.(reader.Prefetcher)
+	if !ok {
+		return nil
+	}
+	fetched := opts.Fetched
+	opts.Fetched = func(rg reader.Range, n int64, start time.Time, err error) {
+		r.f.record(IORead{Offset: rg.Offset, Length: rg.Length, N: n, Start: start, Duration: time.Since(start), Prefetch: true, Err: err})
+		if fetched != nil {
+			fetched(rg, n, start, err)
+		}
+	}
+	return p.Prefetch(ranges, opts)
+}
+
+func (r *statsReader) Close() error {
+	if c, ok := r.src.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
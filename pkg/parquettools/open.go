@@ -0,0 +1,221 @@
+package parquettools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/apache/arrow/go/v17/parquet/file"
+	"github.com/apache/arrow/go/v17/parquet/metadata"
+	"github.com/jimyag/log"
+
+	"github.com/jimyag/parquet-tools/internal/reader"
+)
+
+const (
+	s3Scheme    = "s3"
+	s3aScheme   = "s3a"
+	localScheme = "file"
+	httpScheme  = "http"
+	httpsScheme = "https"
+	gcsScheme   = "gs"
+	abfsScheme  = "abfs"
+	abfssScheme = "abfss"
+	wasbScheme  = "wasb"
+	wasbsScheme = "wasbs"
+	azScheme    = "az"
+	hdfsScheme  = "webhdfs"
+	shdfsScheme = "swebhdfs"
+
+	// Stdin is the name of standard input.
+	Stdin = "-"
+)
+
+// File is a Parquet file opened by Open. Besides the file.Reader it holds
+// the reader below it, which serves the page indexes arrow does not read,
+// and the prefetcher of remote files. Close releases all of them.
+type File struct {
+	*file.Reader
+	source io.ReaderAt
+	// closer closes source when Reader reads from another reader.
+	closer     io.Closer
+	prefetcher reader.Prefetcher
+	prefetch   PrefetchOptions
+}
+
+// Open opens a Parquet file from a local path, "-" for stdin, a url of a
+// scheme with a registered or external Opener, see Register, or an entry
+// of a zip or tar archive, zip://<archive>!/<entry>. ctx bounds all remote
+// requests of the returned file. Closing the file removes the temporary
+// file of spooled input.
+func Open(ctx context.Context, uri string, opts Options) (*File, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	if uri == Stdin || isStream(uri) {
+		obj, err := openStream(uri, opts)
+		if err != nil {
+			return nil, err
+		}
+		return openObject(uri, obj, opts)
+	}
+	o, err := openerFor(u.Scheme)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := o.Open(ctx, uri, opts)
+	if err != nil {
+		return nil, err
+	}
+	return openObject(uri, obj, opts)
+}
+
+// OpenAll opens the inputs concurrently, at most opts.Jobs at a time. The
+// files are in input order. When some inputs fail to open, their file is
+// nil and the returned error lists each of them.
+func OpenAll(ctx context.Context, uris []string, opts Options) ([]*File, error) {
+	if n := slices.Index(uris, Stdin); n >= 0 && slices.Index(uris[n+1:], Stdin) >= 0 {
+		return nil, fmt.Errorf("stdin (%s) can only be read once", Stdin)
+	}
+	files := make([]*File, len(uris))
+	errs := make([]error, len(uris))
+	sem := make(chan struct{}, max(opts.Jobs, 1))
+	var wg sync.WaitGroup
+	for i, uri := range uris {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			f, err := Open(ctx, uri, opts)
+			if err != nil {
+				errs[i] = fmt.Errorf("%s: %w", uri, err)
+				return
+			}
+			files[i] = f
+		}()
+	}
+	wg.Wait()
+	return files, errors.Join(errs...)
+}
+
+// isStream reports whether a local path is a pipe or device that cannot be
+// seeked, such as the /dev/fd/N of a process substitution.
+func isStream(name string) bool {
+	info, err := os.Stat(name)
+	if err != nil {
+		return false
+	}
+	return info.Mode()&(os.ModeNamedPipe|os.ModeCharDevice|os.ModeSocket) != 0
+}
+
+// openStream spools stdin ("-") or a non-seekable file.
+func openStream(name string, opts Options) (*Object, error) {
+	var src io.Reader = os.Stdin
+	if name != Stdin {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		src = f
+	}
+	spool, err := reader.NewSpoolReader(src, opts.SpoolMemory, opts.SpoolMaxSize)
+	if err != nil {
+		return nil, err
+	}
+	size, err := spool.Size()
+	if err != nil {
+		spool.Close()
+		return nil, err
+	}
+	return &Object{Reader: spool, Size: size}, nil
+}
+
+// openObject opens a parquet file over the reader of an opener. Remote
+// objects go through the block cache when it is enabled and the object can
+// be identified, by the reader itself or by its etag or modification time.
+// IOStats sees the reads below the cache, the ones the opener serves.
+// Private objects, such as SSE-C encrypted ones, are only cached in memory.
+func openObject(uri string, obj *Object, opts Options) (*File, error) {
+	rd := obj.Reader
+	p, _ := obj.Reader.(reader.Prefetcher)
+	key := reader.CacheKey(uri, obj.ETag, obj.ModTime)
+	if c, ok := obj.Reader.(reader.Cacheable); ok && c.CacheKey() != "" {
+		key = c.CacheKey()
+	}
+	var stats *statsReader
+	if opts.IOStats != nil {
+		stats = opts.IOStats.wrap(uri, obj.Size, rd)
+		rd = stats
+		if p != nil {
+			p = stats
+		}
+	}
+	if obj.Remote && opts.Cache != nil && key != "" {
+		private, _ := obj.Reader.(reader.Private)
+		cached := reader.NewCachedReader(rd, obj.Size, key, opts.Cache, private != nil && private.Private())
+		rd, p = cached, cached
+	}
+	f := &File{Reader: obj.Parquet, source: rd, prefetcher: p, prefetch: opts.Prefetch}
+	if f.Reader != nil {
+		f.closer, _ = rd.(io.Closer)
+	} else {
+		rdr, err := file.NewParquetReader(rd)
+		if err != nil {
+			if c, ok := obj.Reader.(io.Closer); ok {
+				c.Close()
+			}
+			return nil, err
+		}
+		f.Reader = rdr
+	}
+	if stats != nil {
+		stats.setMetaData(f.MetaData())
+	}
+	return f, nil
+}
+
+// Close closes the file and the readers below it.
+func (f *File) Close() error {
+	err := f.Reader.Close()
+	if f.closer != nil {
+		err = errors.Join(err, f.closer.Close())
+	}
+	return err
+}
+
+// PrefetchRowGroup fetches the column chunks of a row group ahead of
+// decoding when f is a remote file. cols nil means all columns. Failures
+// are only logged, reads then fall back to single requests.
+func (f *File) PrefetchRowGroup(rg *metadata.RowGroupMetaData, cols []int) {
+	if f.prefetcher == nil {
+		return
+	}
+	ranges, err := reader.ColumnChunkRanges(rg, cols)
+	if err == nil {
+		err = f.prefetcher.Prefetch(ranges, f.prefetch)
+	}
+	if err != nil {
+		log.Warn(err).Msg("error prefetching column chunks")
+	}
+}
+
+// prefetchRanges fetches byte ranges of a remote file ahead of reading
+// them, like PrefetchRowGroup.
+func (f *File) prefetchRanges(ranges []reader.Range) {
+	if f.prefetcher == nil || len(ranges) == 0 {
+		return
+	}
+	if err := f.prefetcher.Prefetch(ranges, f.prefetch); err != nil {
+		log.Warn(err).Msg("error prefetching ranges")
+	}
+}
@@ -0,0 +1,94 @@
+package parquettools
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestOpenMmap checks that a memory-mapped file reads the same rows and
+// still prunes pages with its page indexes.
+func TestOpenMmap(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "mmap.parquet")
+	writePageIndexed(t, name, false)
+	scan := func(mmap bool) ([]Row, *ScanPlan) {
+		t.Helper()
+		opts := DefaultOptions
+		opts.Mmap = mmap
+		f, err := Open(context.Background(), name, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pred, err := NewPredicate("id BETWEEN 5000 AND 5100", f.MetaData().Schema, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		plan, err := PlanScan(f, pred, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows := readRows(t, f, RowOptions{Where: pred})
+		if err := f.Close(); err != nil {
+			t.Errorf("mmap=%v: error closing: %v", mmap, err)
+		}
+		return rows, plan
+	}
+	rows, plan := scan(false)
+	mrows, mplan := scan(true)
+	if len(rows) != 101 || !reflect.DeepEqual(mrows, rows) {
+		t.Errorf("got %d rows mapped and %d read, want 101", len(mrows), len(rows))
+	}
+	if !reflect.DeepEqual(mplan, plan) {
+		t.Errorf("got plan %+v mapped, want %+v", mplan, plan)
+	}
+	var read int64
+	for _, rg := range mplan.RowGroups {
+		read += rg.ReadRows()
+	}
+	if read >= 10000 {
+		t.Errorf("the mapped plan reads %d rows, want pages pruned", read)
+	}
+}
+
+// TestOpenHTTPWithoutHead checks that a file served without HEAD, as
+// presigned GET URLs are, is sized and opened with a single request.
+func TestOpenHTTPWithoutHead(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "get.parquet")
+	writePageIndexed(t, name, false)
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var gets []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		mu.Lock()
+		gets = append(gets, r.Header.Get("Range"))
+		mu.Unlock()
+		http.ServeContent(w, r, "get.parquet", time.Time{}, bytes.NewReader(data))
+	}))
+	defer srv.Close()
+
+	f, err := Open(context.Background(), srv.URL+"/get.parquet", DefaultOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if f.NumRows() != 40000 {
+		t.Errorf("got %d rows, want 40000", f.NumRows())
+	}
+	if len(gets) != 1 || gets[0] != "bytes=-65536" {
+		t.Errorf("got GETs %q to open the file, want the footer probe only", gets)
+	}
+}
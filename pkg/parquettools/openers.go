@@ -0,0 +1,238 @@
+package parquettools
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/apache/arrow/go/v17/parquet"
+	"github.com/apache/arrow/go/v17/parquet/file"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"github.com/jimyag/parquet-tools/internal/reader"
+)
+
+func init() {
+	Register(localScheme, OpenerFunc(openLocal))
+	Register(httpScheme, OpenerFunc(openHTTP))
+	Register(httpsScheme, OpenerFunc(openHTTP))
+	Register(s3Scheme, s3Opener{})
+	Register(s3aScheme, s3Opener{})
+	Register(gcsScheme, OpenerFunc(openGCS))
+	for _, scheme := range []string{abfsScheme, abfssScheme, wasbScheme, wasbsScheme, azScheme} {
+		Register(scheme, OpenerFunc(openAzure))
+	}
+	Register(hdfsScheme, OpenerFunc(openWebHDFS))
+	Register(shdfsScheme, OpenerFunc(openWebHDFS))
+}
+
+func openLocal(ctx context.Context, uri string, opts Options) (*Object, error) {
+	name := strings.TrimPrefix(uri, localScheme+"://")
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	obj := &Object{Reader: f, Size: info.Size(), ModTime: info.ModTime()}
+	if opts.Mmap {
+		obj.Parquet, err = file.OpenParquetFile(name, true)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return obj, nil
+}
+
+// sizedReader is implemented by the readers of every remote scheme.
+type sizedReader interface {
+	parquet.ReaderAtSeeker
+	Size() (int64, error)
+}
+
+// remoteObject wraps the reader of a remote scheme. The readers identify
+// the object version themselves, see reader.Cacheable.
+func remoteObject(r sizedReader, err error) (*Object, error) {
+	if err != nil {
+		return nil, err
+	}
+	size, err := r.Size()
+	if err != nil {
+		return nil, err
+	}
+	return &Object{Reader: r, Size: size, Remote: true}, nil
+}
+
+func openHTTP(ctx context.Context, uri string, opts Options) (*Object, error) {
+	return remoteObject(reader.NewHttpReader(ctx, uri))
+}
+
+func openGCS(ctx context.Context, uri string, opts Options) (*Object, error) {
+	return remoteObject(newGCSReader(ctx, uri, opts))
+}
+
+func openAzure(ctx context.Context, uri string, opts Options) (*Object, error) {
+	return remoteObject(newAzureReader(ctx, uri, opts))
+}
+
+func openWebHDFS(ctx context.Context, uri string, opts Options) (*Object, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	return remoteObject(newWebHDFSReader(ctx, uri, u.Host, opts))
+}
+
+type s3Opener struct{}
+
+func (s3Opener) Open(ctx context.Context, uri string, opts Options) (*Object, error) {
+	s3Cli, err := s3ClientFor(opts, uri, func(client *s3.S3) error {
+		_, err := reader.Stat(ctx, uri, client)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	s3Reader, err := reader.NewS3Reader(ctx, uri, s3Cli)
+	if err != nil {
+		return nil, fmt.Errorf("don't have access to %s: %w", uri, err)
+	}
+	return remoteObject(s3Reader, nil)
+}
+
+// List lists the keys below prefix with the client s3ClientFor picks.
+func (s3Opener) List(ctx context.Context, prefix string, opts Options) ([]string, error) {
+	bucket, key, err := reader.ParsePath(prefix)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	list := func(client *s3.S3) error {
+		var err error
+		keys, err = reader.List(ctx, bucket, key, client)
+		return err
+	}
+	client, err := s3ClientFor(opts, prefix, list)
+	if err != nil {
+		return nil, err
+	}
+	// probing already listed the prefix with the chosen client
+	if keys == nil {
+		if err := list(client); err != nil {
+			return nil, fmt.Errorf("don't have access to s3://%s/%s: %w", bucket, key, err)
+		}
+	}
+	root := strings.TrimSuffix(prefix, key)
+	files := make([]string, len(keys))
+	for i, k := range keys {
+		files[i] = root + k
+	}
+	return files, nil
+}
+
+// newGCSReader picks the [[gcs]] entry whose scopes contain the bucket, or
+// the first entry without scopes. Without a matching entry it falls back to
+// GOOGLE_APPLICATION_CREDENTIALS and STORAGE_EMULATOR_HOST.
+func newGCSReader(ctx context.Context, filename string, opts Options) (*reader.GCSReader, error) {
+	bucket, _, err := reader.ParseGCSPath(filename)
+	if err != nil {
+		return nil, err
+	}
+	cfg := opts.Config
+	var gc *GCSConfig
+	for i, c := range cfg.GCS {
+		if slices.Contains(c.Scopes, bucket) {
+			gc = &cfg.GCS[i]
+			break
+		}
+		if len(c.Scopes) == 0 && gc == nil {
+			gc = &cfg.GCS[i]
+		}
+	}
+	if gc == nil {
+		gc = &GCSConfig{
+			CredentialsFile: os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"),
+		}
+		if host := os.Getenv("STORAGE_EMULATOR_HOST"); host != "" {
+			gc.EndPoint = host
+			if !strings.Contains(host, "://") {
+				gc.EndPoint = "http://" + host
+			}
+		}
+	}
+	var token *reader.GCSTokenSource
+	if gc.CredentialsFile != "" {
+		token, err = reader.NewGCSTokenSource(gc.CredentialsFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return reader.NewGCSReader(ctx, filename, gc.EndPoint, token)
+}
+
+// newAzureReader picks the [[azure]] entry of the account in the URL whose
+// scopes contain the container, or that has no scopes. az:// URLs carry no
+// account and match on the container only. Without a matching entry it
+// falls back to AZURE_STORAGE_ACCOUNT, AZURE_STORAGE_KEY and
+// AZURE_STORAGE_SAS_TOKEN.
+func newAzureReader(ctx context.Context, filename string, opts Options) (*reader.AzureReader, error) {
+	p, err := reader.ParseAzurePath(filename)
+	if err != nil {
+		return nil, err
+	}
+	cfg := opts.Config
+	var ac *AzureConfig
+	for i, c := range cfg.Azure {
+		if p.Account != "" && c.Account != p.Account {
+			continue
+		}
+		if slices.Contains(c.Scopes, p.Container) {
+			ac = &cfg.Azure[i]
+			break
+		}
+		if len(c.Scopes) == 0 && ac == nil {
+			ac = &cfg.Azure[i]
+		}
+	}
+	if ac == nil {
+		ac = &AzureConfig{
+			Account:    os.Getenv("AZURE_STORAGE_ACCOUNT"),
+			AccountKey: os.Getenv("AZURE_STORAGE_KEY"),
+			SASToken:   os.Getenv("AZURE_STORAGE_SAS_TOKEN"),
+		}
+		if p.Account != "" && ac.Account != p.Account {
+			ac = &AzureConfig{Account: p.Account}
+		}
+	}
+	p.Account = ac.Account
+	return reader.NewAzureReader(ctx, p, ac.EndPoint, reader.AzureCredentials{
+		AccountKey: ac.AccountKey,
+		SASToken:   ac.SASToken,
+	})
+}
+
+// newWebHDFSReader uses the user of the [[webhdfs]] entry whose scopes
+// contain the namenode, or of the first entry without scopes, falling back
+// to HADOOP_USER_NAME.
+func newWebHDFSReader(ctx context.Context, filename, namenode string, opts Options) (*reader.WebHDFSReader, error) {
+	user := os.Getenv("HADOOP_USER_NAME")
+	matched := false
+	for _, c := range opts.Config.WebHDFS {
+		if slices.Contains(c.Scopes, namenode) {
+			user = c.User
+			break
+		}
+		if len(c.Scopes) == 0 && !matched {
+			user = c.User
+			matched = true
+		}
+	}
+	return reader.NewWebHDFSReader(ctx, filename, user)
+}
@@ -0,0 +1,94 @@
+// Package parquettools opens Parquet files from local paths, stdin and the
+// supported remote schemes, and inspects them. The parquet-tools commands
+// render its results.
+package parquettools
+
+import (
+	"net/http"
+
+	"github.com/jimyag/parquet-tools/internal/reader"
+)
+
+type (
+	PrefetchOptions = reader.PrefetchOptions
+	CacheOptions    = reader.CacheOptions
+	BlockCache      = reader.BlockCache
+	RetryOptions    = reader.RetryOptions
+)
+
+var (
+	DefaultPrefetchOptions = reader.DefaultPrefetchOptions
+	DefaultRetryOptions    = reader.DefaultRetryOptions
+)
+
+const DefaultCacheBlockSize = reader.DefaultCacheBlockSize
+
+// Options configure how inputs are found and opened.
+type Options struct {
+	// Config holds the entries for the remote schemes.
+	Config Config
+	// ConfigFile is where S3LearnScopes saves learned scopes.
+	ConfigFile string
+
+	// S3Profile names the [[s3]] entry used for every s3 url.
+	S3Profile string
+	// S3Probe tries every [[s3]] entry when no scope matches the bucket.
+	S3Probe bool
+	// S3LearnScopes adds the bucket to the scopes of the entry a probe
+	// found, in ConfigFile.
+	S3LearnScopes bool
+
+	// Mmap memory-maps local files with the memory map of arrow instead
+	// of reading them. IOStats then only sees the page index reads.
+	Mmap bool
+	// Jobs limits the number of inputs OpenAll opens concurrently.
+	Jobs int
+	// Cache keeps blocks of remote files, nil disables it.
+	Cache *BlockCache
+	// Prefetch configures the column chunk reads planned by RowIterator.
+	Prefetch PrefetchOptions
+	// IOStats records the reads of the opened files, nil disables it.
+	IOStats *IOStats
+
+	// SpoolMemory is the number of bytes of stdin or pipe input kept in
+	// memory before spilling to a temporary file. SpoolMaxSize rejects
+	// larger inputs, 0 means no limit.
+	SpoolMemory  int64
+	SpoolMaxSize int64
+
+	// Include and Exclude filter the files Expand finds, see Expand.
+	Include []string
+	Exclude []string
+}
+
+// DefaultOptions are the options of the parquet-tools command.
+var DefaultOptions = Options{
+	Jobs:        1,
+	Prefetch:    DefaultPrefetchOptions,
+	SpoolMemory: 64 << 20,
+}
+
+// httpClient is shared by all remote readers and the s3 client.
+var httpClient = http.DefaultClient
+
+// SetHTTPClient replaces the client of all remote reads. It must be called
+// before any file is opened.
+func SetHTTPClient(c *http.Client) {
+	httpClient = c
+	reader.SetHTTPClient(c)
+}
+
+// SetRetryOptions configures the retries of all remote requests.
+func SetRetryOptions(opts RetryOptions) {
+	reader.SetRetryOptions(opts)
+}
+
+// NewBlockCache returns a cache for Options.Cache.
+func NewBlockCache(opts CacheOptions) *BlockCache {
+	return reader.NewBlockCache(opts)
+}
+
+// ClearCacheDir removes the on-disk cache in dir.
+func ClearCacheDir(dir string) error {
+	return reader.ClearCacheDir(dir)
+}
@@ -0,0 +1,833 @@
+package parquettools
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/apache/arrow/go/v17/arrow/memory"
+	"github.com/apache/arrow/go/v17/parquet"
+	"github.com/apache/arrow/go/v17/parquet/file"
+	"github.com/apache/arrow/go/v17/parquet/metadata"
+	"github.com/apache/arrow/go/v17/parquet/schema"
+	"github.com/jimyag/log"
+
+	"github.com/jimyag/parquet-tools/internal/dumper"
+	"github.com/jimyag/parquet-tools/internal/pageindex"
+	"github.com/jimyag/parquet-tools/internal/reader"
+)
+
+// RowRange is the rows [Start, End) of a row group.
+type RowRange struct {
+	Start, End int64
+}
+
+// ScanPlan is what a scan with a predicate reads of a file, see PlanScan.
+type ScanPlan struct {
+	RowGroups []RowGroupPlan
+}
+
+// RowGroupPlan is what a scan reads of a row group.
+type RowGroupPlan struct {
+	RowGroup int
+	NumRows  int64
+	// Skipped is set when no row of the row group can match, as found
+	// from the "statistics" of its column chunks or the "page index" of
+	// its pages, the Reason.
+	Skipped bool
+	Reason  string
+	// Rows are the rows that can match, nil for all rows.
+	Rows []RowRange
+	// Columns are the column chunks read, those selected and those of
+	// the predicate.
+	Columns []ColumnPlan
+}
+
+// ColumnPlan is what a scan reads of a column chunk.
+type ColumnPlan struct {
+	Column int
+	Path   string
+	// Bytes is the size of the column chunk and ReadBytes what the scan
+	// reads of it.
+	Bytes     int64
+	ReadBytes int64
+	// Pages are the data pages of the chunk when it has an OffsetIndex.
+	Pages []PagePlan
+}
+
+// PagePlan is a data page of a column chunk.
+type PagePlan struct {
+	// Offset and Size locate the page with its header.
+	Offset int64
+	Size   int64
+	// FirstRow and NumRows are the rows of the page in the row group.
+	FirstRow int64
+	NumRows  int64
+	Read     bool
+}
+
+// ReadRows returns the number of rows the scan reads.
+func (p *RowGroupPlan) ReadRows() int64 {
+	if p.Skipped {
+		return 0
+	}
+	if p.Rows == nil {
+		return p.NumRows
+	}
+	return rowRanges(p.Rows).count()
+}
+
+// Bytes returns the size of the column chunks and what the scan reads of
+// them.
+func (p *RowGroupPlan) Bytes() (total, read int64) {
+	for _, c := range p.Columns {
+		total += c.Bytes
+		read += c.ReadBytes
+	}
+	return total, read
+}
+
+// PlanScan returns what a scan of the columns cols, nil for all, with the
+// predicate pred reads of rdr: the row groups whose column chunk
+// statistics allow a match and, when the file has page indexes, the pages
+// whose min and max values and null counts do. pred may be nil.
+func PlanScan(rdr *File, pred *Predicate, cols []int) (*ScanPlan, error) {
+	p := newScanPlanner(rdr, pred, cols)
+	plan := &ScanPlan{}
+	for i := range rdr.NumRowGroups() {
+		rg, err := p.rowGroup(i)
+		if err != nil {
+			return nil, err
+		}
+		plan.RowGroups = append(plan.RowGroups, *rg)
+	}
+	return plan, nil
+}
+
+// scanPlanner prunes the row groups and pages of a file for a predicate.
+type scanPlanner struct {
+	rdr  *File
+	pred *Predicate
+	// cols are the columns read, sorted.
+	cols []int
+	// locs are the page index locations by row group and column, nil
+	// when the file has none or they cannot be read.
+	locs [][]pageindex.Location
+	src  io.ReaderAt
+	// formatters render the statistics of the columns like their values.
+	formatters map[int]*dumper.Dumper
+}
+
+func newScanPlanner(rdr *File, pred *Predicate, cols []int) *scanPlanner {
+	sc := rdr.MetaData().Schema
+	if cols == nil {
+		cols = make([]int, sc.NumColumns())
+		for c := range cols {
+			cols[c] = c
+		}
+	}
+	p := &scanPlanner{rdr: rdr, pred: pred, cols: cols, formatters: map[int]*dumper.Dumper{}}
+	if pred == nil {
+		return p
+	}
+	p.cols = mergeColumns(cols, pred.Columns())
+	if rdr.source == nil {
+		return p
+	}
+	footer, err := rdr.MetaData().Serialize(context.Background())
+	if err != nil {
+		log.Warn(err).Msg("error reading page index locations")
+		return p
+	}
+	locs, err := pageindex.Locations(footer)
+	if err != nil || len(locs) != rdr.NumRowGroups() {
+		log.Warn(err).Msg("error reading page index locations")
+		return p
+	}
+	for _, rg := range locs {
+		for _, l := range rg {
+			if l.HasOffsetIndex() {
+				p.locs, p.src = locs, rdr.source
+				return p
+			}
+		}
+	}
+	return p
+}
+
+// rowGroup plans row group i, first from the statistics of its column
+// chunks and then from its page indexes.
+func (p *scanPlanner) rowGroup(i int) (*RowGroupPlan, error) {
+	md := p.rdr.MetaData()
+	rg := md.RowGroup(i)
+	plan := &RowGroupPlan{RowGroup: i, NumRows: rg.NumRows()}
+	for _, c := range p.cols {
+		chunk, err := rg.ColumnChunk(c)
+		if err != nil {
+			return nil, fmt.Errorf("error getting column chunk %d of row group %d: %w", c, i, err)
+		}
+		plan.Columns = append(plan.Columns, ColumnPlan{
+			Column:    c,
+			Path:      md.Schema.Column(c).Path(),
+			Bytes:     chunk.TotalCompressedSize(),
+			ReadBytes: chunk.TotalCompressedSize(),
+		})
+	}
+	if p.pred == nil || plan.NumRows == 0 {
+		return plan, nil
+	}
+	all := rowRanges{{0, plan.NumRows}}
+	chunkUnits := func(col int) []statUnit {
+		return []statUnit{p.chunkUnit(rg, col, all[0])}
+	}
+	if t, _ := p.pred.cond.prune(&pruneScope{all: all, units: chunkUnits}); len(t) == 0 {
+		plan.skip("statistics")
+		return plan, nil
+	}
+	if p.locs == nil {
+		return plan, nil
+	}
+	indexes, err := p.readIndexes(i, rg)
+	if err != nil {
+		log.Warn(err).Msgf("error reading page indexes of row group %d", i)
+		return plan, nil
+	}
+	t, _ := p.pred.cond.prune(&pruneScope{all: all, units: func(col int) []statUnit {
+		if units := p.pageUnits(col, indexes[col], plan.NumRows); units != nil {
+			return units
+		}
+		return chunkUnits(col)
+	}})
+	if len(t) == 0 {
+		plan.skip("page index")
+		return plan, nil
+	}
+	if t.count() < plan.NumRows {
+		plan.Rows = t
+	}
+	for n := range plan.Columns {
+		cp := &plan.Columns[n]
+		idx := indexes[cp.Column]
+		if idx == nil || idx.offsets == nil {
+			continue
+		}
+		locs := idx.offsets.PageLocations
+		for k, loc := range locs {
+			page := PagePlan{
+				Offset:   loc.Offset,
+				Size:     int64(loc.CompressedPageSize),
+				FirstRow: loc.FirstRowIndex,
+				NumRows:  pageEnd(locs, k, plan.NumRows) - loc.FirstRowIndex,
+			}
+			page.Read = t.overlaps(RowRange{page.FirstRow, page.FirstRow + page.NumRows})
+			if !page.Read {
+				cp.ReadBytes -= page.Size
+			}
+			cp.Pages = append(cp.Pages, page)
+		}
+	}
+	return plan, nil
+}
+
+func (p *RowGroupPlan) skip(reason string) {
+	p.Skipped, p.Reason = true, reason
+	for n := range p.Columns {
+		p.Columns[n].ReadBytes = 0
+	}
+}
+
+// pageEnd returns the row after the last row of page k.
+func pageEnd(locs []pageindex.PageLocation, k int, numRows int64) int64 {
+	if k+1 < len(locs) {
+		return locs[k+1].FirstRowIndex
+	}
+	return numRows
+}
+
+// pageIndexes are the page indexes of a column chunk, either may be nil.
+type pageIndexes struct {
+	offsets *pageindex.OffsetIndex
+	columns *pageindex.ColumnIndex
+}
+
+// readIndexes reads the OffsetIndex of the columns read and the
+// ColumnIndex of the columns of the predicate, by column.
+func (p *scanPlanner) readIndexes(i int, rg *metadata.RowGroupMetaData) (map[int]*pageIndexes, error) {
+	locs := p.locs[i]
+	predCols := map[int]bool{}
+	for _, c := range p.pred.Columns() {
+		predCols[c] = true
+	}
+	var ranges []reader.Range
+	for _, c := range p.cols {
+		if c >= len(locs) || !p.pageIndexable(rg, c) {
+			continue
+		}
+		l := locs[c]
+		if l.HasOffsetIndex() {
+			ranges = append(ranges, reader.Range{Offset: l.OffsetIndexOffset, Length: int64(l.OffsetIndexLength)})
+		}
+		if predCols[c] && l.HasColumnIndex() {
+			ranges = append(ranges, reader.Range{Offset: l.ColumnIndexOffset, Length: int64(l.ColumnIndexLength)})
+		}
+	}
+	p.rdr.prefetchRanges(ranges)
+	indexes := map[int]*pageIndexes{}
+	for _, c := range p.cols {
+		if c >= len(locs) || !p.pageIndexable(rg, c) {
+			continue
+		}
+		l := locs[c]
+		if !l.HasOffsetIndex() {
+			continue
+		}
+		idx := &pageIndexes{}
+		b, err := p.read(l.OffsetIndexOffset, l.OffsetIndexLength)
+		if err != nil {
+			return nil, err
+		}
+		if idx.offsets, err = pageindex.ParseOffsetIndex(b); err != nil {
+			return nil, err
+		}
+		if predCols[c] && l.HasColumnIndex() {
+			b, err := p.read(l.ColumnIndexOffset, l.ColumnIndexLength)
+			if err != nil {
+				return nil, err
+			}
+			if idx.columns, err = pageindex.ParseColumnIndex(b); err != nil {
+				return nil, err
+			}
+			if len(idx.columns.NullPages) != len(idx.offsets.PageLocations) {
+				return nil, fmt.Errorf("column %d has %d pages in its column index and %d in its offset index", c, len(idx.columns.NullPages), len(idx.offsets.PageLocations))
+			}
+		}
+		indexes[c] = idx
+	}
+	return indexes, nil
+}
+
+// pageIndexable reports whether the pages of a column chunk can be read
+// on their own: not encrypted.
+func (p *scanPlanner) pageIndexable(rg *metadata.RowGroupMetaData, c int) bool {
+	chunk, err := rg.ColumnChunk(c)
+	return err == nil && chunk.CryptoMetadata() == nil
+}
+
+func (p *scanPlanner) read(off int64, length int32) ([]byte, error) {
+	b := make([]byte, length)
+	if _, err := p.src.ReadAt(b, off); err != nil {
+		return nil, fmt.Errorf("error reading page index at %d: %w", off, err)
+	}
+	return b, nil
+}
+
+// statUnit is the statistics of the values of a column in some rows, a
+// column chunk or a page.
+type statUnit struct {
+	rows      RowRange
+	hasMinMax bool
+	min, max  any
+	// nulls and values are -1 when unknown.
+	nulls, values int64
+	allNull       bool
+}
+
+// unknownUnit has no statistics, all its rows can match.
+func unknownUnit(rows RowRange) statUnit {
+	return statUnit{rows: rows, nulls: -1, values: -1}
+}
+
+// chunkUnit returns the statistics of a column chunk.
+func (p *scanPlanner) chunkUnit(rg *metadata.RowGroupMetaData, col int, rows RowRange) statUnit {
+	u := unknownUnit(rows)
+	chunk, err := rg.ColumnChunk(col)
+	if err != nil {
+		return u
+	}
+	if set, _ := chunk.StatsSet(); !set {
+		return u
+	}
+	stats, err := chunk.Statistics()
+	if err != nil || stats == nil {
+		return u
+	}
+	u.values = chunk.NumValues()
+	if stats.HasNullCount() {
+		u.nulls = stats.NullCount()
+		u.allNull = u.nulls == u.values
+	}
+	if stats.HasMinMax() {
+		u.min, u.max, u.hasMinMax = p.minMax(col, stats.EncodeMin(), stats.EncodeMax())
+	}
+	return u
+}
+
+// pageUnits returns the statistics of the pages of a column chunk from its
+// ColumnIndex, nil without one.
+func (p *scanPlanner) pageUnits(col int, idx *pageIndexes, numRows int64) []statUnit {
+	if idx == nil || idx.columns == nil {
+		return nil
+	}
+	locs := idx.offsets.PageLocations
+	units := make([]statUnit, len(locs))
+	for k, loc := range locs {
+		u := unknownUnit(RowRange{loc.FirstRowIndex, pageEnd(locs, k, numRows)})
+		if !p.repeated(col) {
+			u.values = u.rows.End - u.rows.Start
+		}
+		if idx.columns.NullCounts != nil {
+			u.nulls = idx.columns.NullCounts[k]
+		}
+		if idx.columns.NullPages[k] {
+			u.allNull = true
+		} else {
+			u.min, u.max, u.hasMinMax = p.minMax(col, idx.columns.MinValues[k], idx.columns.MaxValues[k])
+		}
+		units[k] = u
+	}
+	return units
+}
+
+func (p *scanPlanner) repeated(col int) bool {
+	return p.rdr.MetaData().Schema.Column(col).MaxRepetitionLevel() > 0
+}
+
+// minMax converts plain encoded min and max statistics to the values the
+// predicate compares. ok is false for the types whose statistics are not
+// ordered like their values: INT96, INTERVAL and FLOAT16, for NaN and for
+// values that do not decode.
+func (p *scanPlanner) minMax(col int, encMin, encMax []byte) (lo, hi any, ok bool) {
+	descr := p.rdr.MetaData().Schema.Column(col)
+	switch descr.LogicalType().(type) {
+	case schema.IntervalLogicalType, schema.Float16LogicalType:
+		return nil, nil, false
+	}
+	if descr.PhysicalType() == parquet.Types.Int96 {
+		return nil, nil, false
+	}
+	f, ok := p.formatters[col]
+	if !ok {
+		f = dumper.NewFormatter(descr, false, nil)
+		p.formatters[col] = f
+	}
+	k := columnKind(descr.SchemaNode().(*schema.PrimitiveNode))
+	lo, hi = k.value(f.JSONValue(statValueOf(descr, encMin))), k.value(f.JSONValue(statValueOf(descr, encMax)))
+	if lo == nil || hi == nil {
+		return nil, nil, false
+	}
+	for _, v := range []any{lo, hi} {
+		if fl, ok := v.(float64); ok && math.IsNaN(fl) {
+			return nil, nil, false
+		}
+	}
+	return lo, hi, true
+}
+
+// statValueOf decodes a plain encoded statistic to the value type the
+// column reader returns, nil when it is too short.
+func statValueOf(descr *schema.Column, b []byte) any {
+	typ := descr.PhysicalType()
+	size := map[parquet.Type]int{
+		parquet.Types.Boolean: 1,
+		parquet.Types.Int32:   4,
+		parquet.Types.Int64:   8,
+		parquet.Types.Float:   4,
+		parquet.Types.Double:  8,
+	}[typ]
+	if len(b) < size {
+		return nil
+	}
+	switch typ {
+	case parquet.Types.ByteArray:
+		return parquet.ByteArray(b)
+	case parquet.Types.FixedLenByteArray:
+		return parquet.FixedLenByteArray(b)
+	}
+	return metadata.GetStatValue(typ, b)
+}
+
+// columnReader opens a column chunk for the plan, only reading its
+// dictionary page and the data pages it keeps. spans are the rows of the
+// pages read, nil when all are.
+func (p *scanPlanner) columnReader(rgr *file.RowGroupReader, cp ColumnPlan) (col file.ColumnChunkReader, spans []RowRange, err error) {
+	var kept rowRanges
+	skipped := false
+	for _, page := range cp.Pages {
+		if page.Read {
+			kept = kept.add(RowRange{page.FirstRow, page.FirstRow + page.NumRows})
+		} else {
+			skipped = true
+		}
+	}
+	if !skipped || len(kept) == 0 {
+		col, err := rgr.Column(cp.Column)
+		return col, nil, err
+	}
+	chunk, err := rgr.MetaData().ColumnChunk(cp.Column)
+	if err != nil {
+		return nil, nil, err
+	}
+	var parts []io.Reader
+	if start := chunkStart(chunk); start < cp.Pages[0].Offset {
+		// the dictionary page
+		parts = append(parts, io.NewSectionReader(p.src, start, cp.Pages[0].Offset-start))
+	}
+	for _, page := range cp.Pages {
+		if page.Read {
+			parts = append(parts, io.NewSectionReader(p.src, page.Offset, page.Size))
+		}
+	}
+	stream := &pageStream{src: io.MultiReader(parts...)}
+	pages, err := file.NewPageReader(stream, chunk.NumValues(), chunk.Compression(), memory.DefaultAllocator, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	descr := p.rdr.MetaData().Schema.Column(cp.Column)
+	return file.NewColumnReader(descr, pages, memory.DefaultAllocator, p.rdr.BufferPool()), kept, nil
+}
+
+// pageStream is the parquet.BufferedReader of the pages read of a column
+// chunk. Unlike a bufio.Reader, Peek grows its buffer for the page headers
+// larger than it, as the page reader retries with twice the size.
+type pageStream struct {
+	src io.Reader
+	// buf holds the bytes peeked and not read yet.
+	buf []byte
+}
+
+func (s *pageStream) Peek(n int) ([]byte, error) {
+	if have := len(s.buf); have < n {
+		s.buf = slices.Grow(s.buf, n-have)[:n]
+		m, err := io.ReadFull(s.src, s.buf[have:])
+		s.buf = s.buf[:have+m]
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		if err != nil {
+			return s.buf, err
+		}
+	}
+	return s.buf[:n], nil
+}
+
+func (s *pageStream) Discard(n int) (int, error) {
+	if n <= len(s.buf) {
+		s.buf = s.buf[n:]
+		return n, nil
+	}
+	buffered := len(s.buf)
+	s.buf = nil
+	m, err := io.CopyN(io.Discard, s.src, int64(n-buffered))
+	return buffered + int(m), err
+}
+
+func (s *pageStream) Read(p []byte) (int, error) {
+	if len(s.buf) > 0 {
+		n := copy(p, s.buf)
+		s.buf = s.buf[n:]
+		return n, nil
+	}
+	return s.src.Read(p)
+}
+
+// chunkStart returns the offset of the first page of a column chunk, the
+// dictionary page when it has one.
+func chunkStart(chunk *metadata.ColumnChunkMetaData) int64 {
+	start := chunk.DataPageOffset()
+	if chunk.HasDictionaryPage() && chunk.DictionaryPageOffset() > 0 && start > chunk.DictionaryPageOffset() {
+		start = chunk.DictionaryPageOffset()
+	}
+	return start
+}
+
+// ranges returns the byte ranges the plan reads of a row group.
+func (p *RowGroupPlan) ranges(rg *metadata.RowGroupMetaData) []reader.Range {
+	var ranges []reader.Range
+	for _, cp := range p.Columns {
+		chunk, err := rg.ColumnChunk(cp.Column)
+		if err != nil {
+			continue
+		}
+		if cp.ReadBytes == cp.Bytes {
+			chunkRanges, err := reader.ColumnChunkRanges(rg, []int{cp.Column})
+			if err == nil {
+				ranges = append(ranges, chunkRanges...)
+			}
+			continue
+		}
+		if start := chunkStart(chunk); start < cp.Pages[0].Offset {
+			ranges = append(ranges, reader.Range{Offset: start, Length: cp.Pages[0].Offset - start})
+		}
+		for _, page := range cp.Pages {
+			if page.Read {
+				ranges = append(ranges, reader.Range{Offset: page.Offset, Length: page.Size})
+			}
+		}
+	}
+	return ranges
+}
+
+// rowRanges are sorted, disjoint and not adjacent row ranges.
+type rowRanges []RowRange
+
+func (a rowRanges) count() int64 {
+	var n int64
+	for _, r := range a {
+		n += r.End - r.Start
+	}
+	return n
+}
+
+// add appends r, which does not start before the last range.
+func (a rowRanges) add(r RowRange) rowRanges {
+	if r.Start >= r.End {
+		return a
+	}
+	if n := len(a); n > 0 && r.Start <= a[n-1].End {
+		a[n-1].End = max(a[n-1].End, r.End)
+		return a
+	}
+	return append(a, r)
+}
+
+func (a rowRanges) union(b rowRanges) rowRanges {
+	var out rowRanges
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		if j == len(b) || (i < len(a) && a[i].Start <= b[j].Start) {
+			out = out.add(a[i])
+			i++
+		} else {
+			out = out.add(b[j])
+			j++
+		}
+	}
+	return out
+}
+
+func (a rowRanges) intersect(b rowRanges) rowRanges {
+	var out rowRanges
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		out = out.add(RowRange{max(a[i].Start, b[j].Start), min(a[i].End, b[j].End)})
+		if a[i].End < b[j].End {
+			i++
+		} else {
+			j++
+		}
+	}
+	return out
+}
+
+func (a rowRanges) overlaps(r RowRange) bool {
+	for _, x := range a {
+		if x.Start < r.End && r.Start < x.End {
+			return true
+		}
+	}
+	return false
+}
+
+// formatRanges formats indexes as ranges, such as "0-3,7".
+func formatRanges(indexes []int) string {
+	var b strings.Builder
+	for i := 0; i < len(indexes); {
+		j := i
+		for j+1 < len(indexes) && indexes[j+1] == indexes[j]+1 {
+			j++
+		}
+		if b.Len() > 0 {
+			b.WriteString(",")
+		}
+		if j > i {
+			fmt.Fprintf(&b, "%d-%d", indexes[i], indexes[j])
+		} else {
+			fmt.Fprintf(&b, "%d", indexes[i])
+		}
+		i = j + 1
+	}
+	return b.String()
+}
+
+// ReadPages returns the pages a scan reads, as ranges of page indexes
+// such as "0-3,7", empty when it reads none.
+func (c *ColumnPlan) ReadPages() string {
+	var read []int
+	for k, page := range c.Pages {
+		if page.Read {
+			read = append(read, k)
+		}
+	}
+	return formatRanges(read)
+}
+
+// pruneScope holds the statistics of the units of rows of a row group,
+// by column.
+type pruneScope struct {
+	all   rowRanges
+	units func(col int) []statUnit
+}
+
+// leaf evaluates a test of a column on each unit, f returning whether the
+// test can be true and can be false for some row of the unit. It returns
+// the rows where the test can be true and those where it can be false.
+func (s *pruneScope) leaf(c *columnRef, f func(u statUnit) (bool, bool)) (canTrue, canFalse rowRanges) {
+	if c.column < 0 {
+		return s.all, s.all
+	}
+	for _, u := range s.units(c.column) {
+		t, fl := f(u)
+		if c.repeated {
+			// any element may not match, or the list be empty
+			fl = true
+		}
+		if t {
+			canTrue = canTrue.add(u.rows)
+		}
+		if fl {
+			canFalse = canFalse.add(u.rows)
+		}
+	}
+	return canTrue, canFalse
+}
+
+// The prune methods return the rows of a row group where the condition
+// can be true and those where it can be false. Rows where it is unknown
+// are in neither.
+
+func (c andCond) prune(s *pruneScope) (rowRanges, rowRanges) {
+	lt, lf := c.left.prune(s)
+	rt, rf := c.right.prune(s)
+	return lt.intersect(rt), lf.union(rf)
+}
+
+func (c orCond) prune(s *pruneScope) (rowRanges, rowRanges) {
+	lt, lf := c.left.prune(s)
+	rt, rf := c.right.prune(s)
+	return lt.union(rt), lf.intersect(rf)
+}
+
+func (c notCond) prune(s *pruneScope) (rowRanges, rowRanges) {
+	t, f := c.x.prune(s)
+	return f, t
+}
+
+func swap(t, f rowRanges, not bool) (rowRanges, rowRanges) {
+	if not {
+		return f, t
+	}
+	return t, f
+}
+
+var flipped = map[string]string{"=": "=", "!=": "!=", "<": ">", "<=": ">=", ">": "<", ">=": "<="}
+
+func (c compareCond) prune(s *pruneScope) (rowRanges, rowRanges) {
+	col, lit, op := c.left, c.right, c.op
+	if _, ok := col.(literal); ok {
+		col, lit, op = c.right, c.left, flipped[op]
+	}
+	ref, ok := col.(*columnRef)
+	l, isLit := lit.(literal)
+	if !ok || !isLit {
+		return s.all, s.all
+	}
+	return s.leaf(ref, func(u statUnit) (bool, bool) {
+		return rangeCompare(u, op, l.value)
+	})
+}
+
+// rangeCompare returns whether col op v can be true and can be false for
+// the values of a unit.
+func rangeCompare(u statUnit, op string, v any) (bool, bool) {
+	if v == nil || u.allNull {
+		return false, false
+	}
+	if !u.hasMinMax {
+		return true, true
+	}
+	lo, ok1 := compareValues(u.min, v)
+	hi, ok2 := compareValues(u.max, v)
+	if !ok1 || !ok2 {
+		return true, true
+	}
+	single := lo == 0 && hi == 0
+	within := lo <= 0 && hi >= 0
+	switch op {
+	case "=":
+		return within, !single
+	case "!=":
+		return !single, within
+	case "<":
+		return lo < 0, hi >= 0
+	case "<=":
+		return lo <= 0, hi > 0
+	case ">":
+		return hi > 0, lo <= 0
+	case ">=":
+		return hi >= 0, lo < 0
+	}
+	return true, true
+}
+
+func (c betweenCond) prune(s *pruneScope) (rowRanges, rowRanges) {
+	ref, ok := c.x.(*columnRef)
+	low, ok1 := c.low.(literal)
+	high, ok2 := c.high.(literal)
+	if !ok || !ok1 || !ok2 {
+		return s.all, s.all
+	}
+	t, f := s.leaf(ref, func(u statUnit) (bool, bool) {
+		ge, geFalse := rangeCompare(u, ">=", low.value)
+		le, leFalse := rangeCompare(u, "<=", high.value)
+		return ge && le, geFalse || leFalse
+	})
+	return swap(t, f, c.not)
+}
+
+func (c inCond) prune(s *pruneScope) (rowRanges, rowRanges) {
+	ref, ok := c.x.(*columnRef)
+	if !ok {
+		return s.all, s.all
+	}
+	var values []any
+	for _, o := range c.list {
+		l, ok := o.(literal)
+		if !ok {
+			return s.all, s.all
+		}
+		values = append(values, l.value)
+	}
+	t, f := s.leaf(ref, func(u statUnit) (bool, bool) {
+		t, f := false, true
+		for _, v := range values {
+			vt, vf := rangeCompare(u, "=", v)
+			t = t || vt
+			f = f && vf
+		}
+		return t, f
+	})
+	return swap(t, f, c.not)
+}
+
+func (c nullCond) prune(s *pruneScope) (rowRanges, rowRanges) {
+	if c.x.repeated || len(c.x.nullSteps) != len(c.x.steps) {
+		return s.all, s.all
+	}
+	t, f := s.leaf(c.x, func(u statUnit) (bool, bool) {
+		if u.allNull {
+			return true, false
+		}
+		if u.nulls < 0 || u.values < 0 {
+			return true, true
+		}
+		return u.nulls > 0, u.nulls < u.values
+	})
+	return swap(t, f, c.not)
+}
+
+func (c likeCond) prune(s *pruneScope) (rowRanges, rowRanges) {
+	return s.all, s.all
+}
@@ -0,0 +1,643 @@
+package parquettools
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/apache/arrow/go/v17/parquet"
+	"github.com/apache/arrow/go/v17/parquet/compress"
+	"github.com/apache/arrow/go/v17/parquet/file"
+	"github.com/apache/arrow/go/v17/parquet/schema"
+)
+
+func TestRowRanges(t *testing.T) {
+	a := rowRanges{}.add(RowRange{0, 10}).add(RowRange{10, 15}).add(RowRange{12, 14}).add(RowRange{20, 20}).add(RowRange{30, 40})
+	if want := (rowRanges{{0, 15}, {30, 40}}); !reflect.DeepEqual(a, want) {
+		t.Fatalf("add: got %v, want %v", a, want)
+	}
+	if n := a.count(); n != 25 {
+		t.Errorf("count: got %d, want 25", n)
+	}
+	b := rowRanges{{5, 20}, {25, 31}, {39, 50}}
+	if got, want := a.union(b), (rowRanges{{0, 20}, {25, 50}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("union: got %v, want %v", got, want)
+	}
+	if got, want := a.intersect(b), (rowRanges{{5, 15}, {30, 31}, {39, 40}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("intersect: got %v, want %v", got, want)
+	}
+	if got := a.intersect(nil); got != nil {
+		t.Errorf("intersect with none: got %v", got)
+	}
+	for r, want := range map[RowRange]bool{{15, 30}: false, {14, 15}: true, {40, 41}: false, {0, 100}: true} {
+		if got := a.overlaps(r); got != want {
+			t.Errorf("overlaps %v: got %v, want %v", r, got, want)
+		}
+	}
+}
+
+// TestRowRangesRandom checks the operations against sets of rows.
+func TestRowRangesRandom(t *testing.T) {
+	const rows = 64
+	rnd := rand.New(rand.NewSource(1))
+	random := func() (rowRanges, [rows]bool) {
+		var set [rows]bool
+		var r rowRanges
+		for start := 0; start < rows; {
+			start += rnd.Intn(8)
+			end := min(start+rnd.Intn(8), rows)
+			r = r.add(RowRange{int64(start), int64(end)})
+			for i := start; i < end; i++ {
+				set[i] = true
+			}
+			start = end
+		}
+		return r, set
+	}
+	check := func(op string, r rowRanges, want func(i int) bool) {
+		t.Helper()
+		for k, x := range r {
+			if x.Start >= x.End || (k > 0 && x.Start <= r[k-1].End) {
+				t.Fatalf("%s: %v is not sorted, disjoint and not adjacent", op, r)
+			}
+		}
+		for i := range rows {
+			if got := r.overlaps(RowRange{int64(i), int64(i + 1)}); got != want(i) {
+				t.Fatalf("%s: row %d in %v is %v, want %v", op, i, r, got, want(i))
+			}
+		}
+	}
+	for range 500 {
+		a, as := random()
+		b, bs := random()
+		check("union", a.union(b), func(i int) bool { return as[i] || bs[i] })
+		check("intersect", a.intersect(b), func(i int) bool { return as[i] && bs[i] })
+	}
+}
+
+// TestRangeCompare checks rangeCompare against every value of small
+// units: it must report exactly whether the comparison is true and false
+// for some value between min and max.
+func TestRangeCompare(t *testing.T) {
+	for _, op := range []string{"=", "!=", "<", "<=", ">", ">="} {
+		for lo := 0; lo < 5; lo++ {
+			for hi := lo; hi < 5; hi++ {
+				u := statUnit{hasMinMax: true, min: float64(lo), max: float64(hi), nulls: 0, values: 1}
+				for v := -1; v <= 5; v++ {
+					var wantTrue, wantFalse bool
+					for x := lo; x <= hi; x++ {
+						if compareResult(op, cmpInt(x, v)) {
+							wantTrue = true
+						} else {
+							wantFalse = true
+						}
+					}
+					gotTrue, gotFalse := rangeCompare(u, op, float64(v))
+					if gotTrue != wantTrue || gotFalse != wantFalse {
+						t.Errorf("[%d, %d] %s %d: got %v, %v, want %v, %v", lo, hi, op, v, gotTrue, gotFalse, wantTrue, wantFalse)
+					}
+				}
+			}
+		}
+	}
+
+	nan := math.NaN()
+	tests := []struct {
+		name              string
+		u                 statUnit
+		v                 any
+		canTrue, canFalse bool
+	}{
+		{"all null", statUnit{allNull: true, nulls: 3, values: 3}, 1.0, false, false},
+		{"null literal", statUnit{hasMinMax: true, min: 0.0, max: 1.0}, nil, false, false},
+		{"no min max", unknownUnit(RowRange{0, 1}), 1.0, true, true},
+		{"NaN literal", statUnit{hasMinMax: true, min: 0.0, max: 1.0}, nan, true, true},
+		{"NaN unit", statUnit{hasMinMax: true, min: nan, max: 1.0}, 5.0, true, true},
+		{"other kind", statUnit{hasMinMax: true, min: "a", max: "b"}, 1.0, true, true},
+	}
+	for _, tt := range tests {
+		for _, op := range []string{"=", "!=", "<", "<=", ">", ">="} {
+			canTrue, canFalse := rangeCompare(tt.u, op, tt.v)
+			if canTrue != tt.canTrue || canFalse != tt.canFalse {
+				t.Errorf("%s %s: got %v, %v, want %v, %v", tt.name, op, canTrue, canFalse, tt.canTrue, tt.canFalse)
+			}
+		}
+	}
+}
+
+func cmpInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// TestPrune checks the rows the conditions can be true and false for,
+// over pages of x: [0, 9], [10, 19], all null and without statistics.
+func TestPrune(t *testing.T) {
+	x, err := schema.NewPrimitiveNode("x", parquet.Repetitions.Optional, parquet.Types.Double, -1, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := schema.NewGroupNode("schema", parquet.Repetitions.Required, schema.FieldList{x}, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc := schema.NewSchema(root)
+	s := &pruneScope{
+		all: rowRanges{{0, 40}},
+		units: func(int) []statUnit {
+			return []statUnit{
+				{rows: RowRange{0, 10}, hasMinMax: true, min: 0.0, max: 9.0, nulls: 0, values: 10},
+				{rows: RowRange{10, 20}, hasMinMax: true, min: 10.0, max: 19.0, nulls: 2, values: 10},
+				{rows: RowRange{20, 30}, allNull: true, nulls: 10, values: 10},
+				unknownUnit(RowRange{30, 40}),
+			}
+		},
+	}
+	tests := []struct {
+		expr              string
+		canTrue, canFalse rowRanges
+	}{
+		{"x < 5", rowRanges{{0, 10}, {30, 40}}, rowRanges{{0, 20}, {30, 40}}},
+		{"x = 15", rowRanges{{10, 20}, {30, 40}}, rowRanges{{0, 20}, {30, 40}}},
+		{"NOT x >= 10", rowRanges{{0, 10}, {30, 40}}, rowRanges{{10, 20}, {30, 40}}},
+		{"x IS NULL", rowRanges{{10, 40}}, rowRanges{{0, 20}, {30, 40}}},
+		{"x IS NOT NULL", rowRanges{{0, 20}, {30, 40}}, rowRanges{{10, 40}}},
+		{"x BETWEEN 12 AND 30", rowRanges{{10, 20}, {30, 40}}, rowRanges{{0, 20}, {30, 40}}},
+		{"x NOT BETWEEN 0 AND 9", rowRanges{{10, 20}, {30, 40}}, rowRanges{{0, 10}, {30, 40}}},
+		{"x IN (3, 25)", rowRanges{{0, 10}, {30, 40}}, rowRanges{{0, 20}, {30, 40}}},
+		{"x < 5 AND x > 15", rowRanges{{30, 40}}, rowRanges{{0, 20}, {30, 40}}},
+		{"x < 5 OR x IS NULL", rowRanges{{0, 40}}, rowRanges{{0, 20}, {30, 40}}},
+		{"x = NULL", nil, nil},
+		{"x LIKE '1%'", rowRanges{{0, 40}}, rowRanges{{0, 40}}},
+	}
+	for _, tt := range tests {
+		pred, err := NewPredicate(tt.expr, sc, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.expr, err)
+		}
+		canTrue, canFalse := pred.cond.prune(s)
+		if !reflect.DeepEqual(canTrue, tt.canTrue) || !reflect.DeepEqual(canFalse, tt.canFalse) {
+			t.Errorf("%s: got %v, %v, want %v, %v", tt.expr, canTrue, canFalse, tt.canTrue, tt.canFalse)
+		}
+	}
+}
+
+// TestRowIteratorPruning checks that the rows read with the pages pruned
+// are the rows of a full scan the predicate matches.
+func TestRowIteratorPruning(t *testing.T) {
+	dir := t.TempDir()
+	for _, partial := range []bool{false, true} {
+		name := filepath.Join(dir, fmt.Sprintf("pidx-%v.parquet", partial))
+		writePageIndexed(t, name, partial)
+		rdr, err := Open(context.Background(), name, DefaultOptions)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rdr.Close()
+
+		all := readRows(t, rdr, RowOptions{})
+		if len(all) != 40000 {
+			t.Fatalf("read %d rows, want 40000", len(all))
+		}
+		exprs := []string{
+			"id < 150",
+			"id BETWEEN 5000 AND 5100 OR id > 39990",
+			"country = 'FR'",
+			"amount IS NULL AND id < 1000",
+			"amount > 990",
+			"NOT id >= 100",
+			"ts > '2024-01-28T00:00:00Z'",
+			"id IN (3, 20000, 39999)",
+			"tags = 'n12'",
+			"tags IS NULL AND id > 39000",
+			"id < 0",
+		}
+		for _, expr := range exprs {
+			pred, err := NewPredicate(expr, rdr.MetaData().Schema, nil)
+			if err != nil {
+				t.Fatalf("%s: %v", expr, err)
+			}
+			var want []Row
+			for _, row := range all {
+				if pred.Match(row) {
+					want = append(want, row)
+				}
+			}
+			got := readRows(t, rdr, RowOptions{Where: pred})
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("partial=%v %s: read %d rows, want %d", partial, expr, len(got), len(want))
+			}
+
+			cols := []int{0}
+			got = readRows(t, rdr, RowOptions{Where: pred, Columns: cols})
+			if len(got) != len(want) {
+				t.Errorf("partial=%v %s with id only: read %d rows, want %d", partial, expr, len(got), len(want))
+			}
+		}
+
+		// the file must have pages to skip for the test to mean something
+		pred, err := NewPredicate("id < 150", rdr.MetaData().Schema, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		plan, err := PlanScan(rdr, pred, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var read int64
+		for _, rg := range plan.RowGroups {
+			read += rg.ReadRows()
+		}
+		if read == 0 || read >= 10000 {
+			t.Errorf("partial=%v: the plan reads %d rows of 40000", partial, read)
+		}
+	}
+}
+
+func readRows(t *testing.T, rdr *File, opts RowOptions) []Row {
+	t.Helper()
+	it := NewRowIterator(rdr, opts)
+	var rows []Row
+	for {
+		row, ok := it.Next()
+		if !ok {
+			break
+		}
+		rows = append(rows, row)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return rows
+}
+
+// writePageIndexed writes 4 row groups of 10000 rows in small DataPageV2
+// pages and adds page indexes built from the page statistics, which the
+// arrow writer does not write. With partial the list column has none.
+func writePageIndexed(t *testing.T, name string, partial bool) {
+	t.Helper()
+	must := func(n schema.Node, err error) schema.Node {
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	req, opt, rep := parquet.Repetitions.Required, parquet.Repetitions.Optional, parquet.Repetitions.Repeated
+	elem := must(schema.NewPrimitiveNodeLogical("element", opt, schema.StringLogicalType{}, parquet.Types.ByteArray, 0, -1))
+	list := must(schema.NewGroupNode("list", rep, schema.FieldList{elem}, -1))
+	root := must(schema.NewGroupNode("schema", req, schema.FieldList{
+		must(schema.NewPrimitiveNode("id", req, parquet.Types.Int64, -1, -1)),
+		must(schema.NewPrimitiveNodeLogical("country", req, schema.StringLogicalType{}, parquet.Types.ByteArray, 0, -1)),
+		must(schema.NewPrimitiveNode("amount", opt, parquet.Types.Int32, -1, -1)),
+		must(schema.NewPrimitiveNodeLogical("ts", req, schema.NewTimestampLogicalType(true, schema.TimeUnitMillis), parquet.Types.Int64, 0, -1)),
+		must(schema.NewGroupNodeLogical("tags", opt, schema.FieldList{list}, schema.ListLogicalType{}, -1)),
+	}, -1)).(*schema.GroupNode)
+
+	var buf bytes.Buffer
+	props := parquet.NewWriterProperties(
+		parquet.WithDataPageSize(1024),
+		parquet.WithBatchSize(100),
+		parquet.WithDataPageVersion(parquet.DataPageV2),
+		parquet.WithCompression(compress.Codecs.Snappy),
+		parquet.WithDictionaryDefault(false),
+		parquet.WithDictionaryFor("country", true),
+		parquet.WithStats(true),
+	)
+	w := file.NewParquetWriter(&buf, root, file.WithWriterProps(props))
+	countries := []string{"DE", "FR", "US", "JP"}
+	const perGroup = 10000
+	base := int64(1704067200000) // 2024-01-01
+	for g := range 4 {
+		var ids, tss []int64
+		var cs, tv []parquet.ByteArray
+		var am []int32
+		var amDef, tDef, tRep []int16
+		for i := g * perGroup; i < (g+1)*perGroup; i++ {
+			ids = append(ids, int64(i))
+			tss = append(tss, base+int64(i)*60000)
+			cs = append(cs, parquet.ByteArray(countries[(i/2500)%4]))
+			if i%7 == 0 {
+				amDef = append(amDef, 0)
+			} else {
+				amDef = append(amDef, 1)
+				am = append(am, int32(i%1000))
+			}
+			switch i % 5 {
+			case 0:
+				tDef, tRep = append(tDef, 0), append(tRep, 0)
+			case 1:
+				tDef, tRep = append(tDef, 1), append(tRep, 0)
+			default:
+				tDef, tRep = append(tDef, 3), append(tRep, 0)
+				tv = append(tv, parquet.ByteArray(fmt.Sprintf("t%d", i%3)))
+				if i%11 == 0 {
+					tDef, tRep = append(tDef, 2), append(tRep, 1)
+				} else {
+					tDef, tRep = append(tDef, 3), append(tRep, 1)
+					tv = append(tv, parquet.ByteArray(fmt.Sprintf("n%d", i)))
+				}
+			}
+		}
+		rg := w.AppendRowGroup()
+		write := func(f func(cw file.ColumnChunkWriter) error) {
+			cw, err := rg.NextColumn()
+			if err == nil {
+				err = f(cw)
+			}
+			if err == nil {
+				err = cw.Close()
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		write(func(cw file.ColumnChunkWriter) error {
+			_, err := cw.(*file.Int64ColumnChunkWriter).WriteBatch(ids, nil, nil)
+			return err
+		})
+		write(func(cw file.ColumnChunkWriter) error {
+			_, err := cw.(*file.ByteArrayColumnChunkWriter).WriteBatch(cs, nil, nil)
+			return err
+		})
+		write(func(cw file.ColumnChunkWriter) error {
+			_, err := cw.(*file.Int32ColumnChunkWriter).WriteBatch(am, amDef, nil)
+			return err
+		})
+		write(func(cw file.ColumnChunkWriter) error {
+			_, err := cw.(*file.Int64ColumnChunkWriter).WriteBatch(tss, nil, nil)
+			return err
+		})
+		write(func(cw file.ColumnChunkWriter) error {
+			_, err := cw.(*file.ByteArrayColumnChunkWriter).WriteBatch(tv, tDef, tRep)
+			return err
+		})
+		if err := rg.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data := buf.Bytes()
+	footerLen := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footerStart := len(data) - 8 - footerLen
+	footer := (&thriftReader{b: data[footerStart : len(data)-8]}).readStruct()
+	out := bytes.NewBuffer(append([]byte(nil), data[:footerStart]...))
+	for _, rgv := range thriftField(footer, 4).items {
+		for c := range thriftField(rgv.st, 1).items {
+			chunk := &thriftField(rgv.st, 1).items[c]
+			md := thriftField(chunk.st, 3).st
+			start := thriftField(md, 9).i
+			if d := thriftField(md, 11); d != nil && d.i < start {
+				start = d.i
+			}
+			end := start + thriftField(md, 7).i
+			var nullPages, mins, maxs, nullCounts, locs []thriftValue
+			var firstRow int64
+			for off := start; off < end; {
+				r := &thriftReader{b: data, off: int(off)}
+				header := r.readStruct()
+				size := int64(r.off) - off + thriftField(header, 3).i
+				switch thriftField(header, 1).i {
+				case 3: // DATA_PAGE_V2
+					h := thriftField(header, 8).st
+					values, nulls, rows := thriftField(h, 1).i, thriftField(h, 2).i, thriftField(h, 3).i
+					var lo, hi []byte
+					if stats := thriftField(h, 8); stats != nil {
+						lo, hi = thriftField(stats.st, 6).b, thriftField(stats.st, 5).b
+					}
+					nullPages = append(nullPages, thriftValue{typ: 1, bl: nulls == values})
+					mins = append(mins, thriftValue{typ: 8, b: lo})
+					maxs = append(maxs, thriftValue{typ: 8, b: hi})
+					nullCounts = append(nullCounts, thriftI64(nulls))
+					locs = append(locs, thriftValue{typ: 12, st: []thriftFieldValue{{1, thriftI64(off)}, {2, thriftI32(size)}, {3, thriftI64(firstRow)}}})
+					firstRow += rows
+				case 2: // DICTIONARY_PAGE
+				default:
+					t.Fatalf("unexpected page type %d", thriftField(header, 1).i)
+				}
+				off += size
+			}
+			if partial && c == 4 {
+				continue
+			}
+			var e thriftWriter
+			e.writeStruct([]thriftFieldValue{
+				{1, thriftValue{typ: 9, elem: 1, items: nullPages}},
+				{2, thriftValue{typ: 9, elem: 8, items: mins}},
+				{3, thriftValue{typ: 9, elem: 8, items: maxs}},
+				{4, thriftI32(0)},
+				{5, thriftValue{typ: 9, elem: 6, items: nullCounts}},
+			})
+			columnIndexOff, columnIndexLen := out.Len(), e.Len()
+			out.Write(e.Bytes())
+			e.Reset()
+			e.writeStruct([]thriftFieldValue{{1, thriftValue{typ: 9, elem: 12, items: locs}}})
+			offsetIndexOff, offsetIndexLen := out.Len(), e.Len()
+			out.Write(e.Bytes())
+			var fields []thriftFieldValue
+			for _, f := range chunk.st {
+				if f.id <= 3 {
+					fields = append(fields, f)
+				}
+			}
+			fields = append(fields,
+				thriftFieldValue{4, thriftI64(int64(offsetIndexOff))}, thriftFieldValue{5, thriftI32(int64(offsetIndexLen))},
+				thriftFieldValue{6, thriftI64(int64(columnIndexOff))}, thriftFieldValue{7, thriftI32(int64(columnIndexLen))})
+			for _, f := range chunk.st {
+				if f.id > 7 {
+					fields = append(fields, f)
+				}
+			}
+			chunk.st = fields
+		}
+	}
+	var e thriftWriter
+	e.writeStruct(footer)
+	out.Write(e.Bytes())
+	out.Write(binary.LittleEndian.AppendUint32(nil, uint32(e.Len())))
+	out.WriteString("PAR1")
+	if err := os.WriteFile(name, out.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// thriftValue is a value of the thrift compact protocol, enough of it to
+// rewrite a footer.
+type thriftValue struct {
+	typ   byte
+	i     int64
+	b     []byte
+	bl    bool
+	elem  byte
+	items []thriftValue
+	st    []thriftFieldValue
+}
+
+type thriftFieldValue struct {
+	id int16
+	v  thriftValue
+}
+
+func thriftField(fields []thriftFieldValue, id int16) *thriftValue {
+	for i := range fields {
+		if fields[i].id == id {
+			return &fields[i].v
+		}
+	}
+	return nil
+}
+
+func thriftI64(v int64) thriftValue { return thriftValue{typ: 6, i: v} }
+func thriftI32(v int64) thriftValue { return thriftValue{typ: 5, i: v} }
+
+type thriftReader struct {
+	b   []byte
+	off int
+}
+
+func (r *thriftReader) byte() byte {
+	c := r.b[r.off]
+	r.off++
+	return c
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.b[r.off:])
+	r.off += n
+	return v
+}
+
+func (r *thriftReader) varint() int64 {
+	v := r.uvarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftReader) read(typ byte, inList bool) thriftValue {
+	v := thriftValue{typ: typ}
+	switch typ {
+	case 1, 2:
+		if inList {
+			v.bl = r.byte() == 1
+		} else {
+			v.bl = typ == 1
+		}
+	case 3:
+		v.i = int64(r.byte())
+	case 4, 5, 6:
+		v.i = r.varint()
+	case 7:
+		v.b = r.b[r.off : r.off+8]
+		r.off += 8
+	case 8:
+		n := int(r.uvarint())
+		v.b = r.b[r.off : r.off+n]
+		r.off += n
+	case 9, 10:
+		h := r.byte()
+		n := int(h >> 4)
+		if n == 15 {
+			n = int(r.uvarint())
+		}
+		v.elem = h & 0x0f
+		for range n {
+			v.items = append(v.items, r.read(v.elem, true))
+		}
+	case 12:
+		v.st = r.readStruct()
+	default:
+		panic(fmt.Sprintf("thrift type %d", typ))
+	}
+	return v
+}
+
+func (r *thriftReader) readStruct() []thriftFieldValue {
+	var fields []thriftFieldValue
+	var id int16
+	for {
+		h := r.byte()
+		if h == 0 {
+			return fields
+		}
+		if delta := int16(h >> 4); delta != 0 {
+			id += delta
+		} else {
+			id = int16(r.varint())
+		}
+		fields = append(fields, thriftFieldValue{id, r.read(h&0x0f, false)})
+	}
+}
+
+type thriftWriter struct{ bytes.Buffer }
+
+func (w *thriftWriter) uvarint(v uint64) { w.Write(binary.AppendUvarint(nil, v)) }
+func (w *thriftWriter) varint(v int64)   { w.uvarint(uint64(v<<1) ^ uint64(v>>63)) }
+
+func (w *thriftWriter) write(v thriftValue, inList bool) {
+	switch v.typ {
+	case 1, 2:
+		if inList {
+			if v.bl {
+				w.WriteByte(1)
+			} else {
+				w.WriteByte(2)
+			}
+		}
+	case 3:
+		w.WriteByte(byte(v.i))
+	case 4, 5, 6:
+		w.varint(v.i)
+	case 7:
+		w.Write(v.b)
+	case 8:
+		w.uvarint(uint64(len(v.b)))
+		w.Write(v.b)
+	case 9, 10:
+		if len(v.items) < 15 {
+			w.WriteByte(byte(len(v.items))<<4 | v.elem)
+		} else {
+			w.WriteByte(0xf0 | v.elem)
+			w.uvarint(uint64(len(v.items)))
+		}
+		for _, item := range v.items {
+			w.write(item, true)
+		}
+	case 12:
+		w.writeStruct(v.st)
+	}
+}
+
+func (w *thriftWriter) writeStruct(fields []thriftFieldValue) {
+	var last int16
+	for _, f := range fields {
+		typ := f.v.typ
+		if typ == 1 || typ == 2 {
+			typ = 2
+			if f.v.bl {
+				typ = 1
+			}
+		}
+		if d := f.id - last; d > 0 && d <= 15 {
+			w.WriteByte(byte(d)<<4 | typ)
+		} else {
+			w.WriteByte(typ)
+			w.varint(int64(f.id))
+		}
+		last = f.id
+		w.write(f.v, false)
+	}
+	w.WriteByte(0)
+}
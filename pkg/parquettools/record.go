@@ -0,0 +1,245 @@
+package parquettools
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/apache/arrow/go/v17/parquet"
+	"github.com/apache/arrow/go/v17/parquet/schema"
+
+	"github.com/jimyag/parquet-tools/internal/dumper"
+)
+
+// columnPath is the path from the root of the schema to a leaf column,
+// with the definition and repetition level each node reaches.
+type columnPath struct {
+	nodes []schema.Node
+	// index is the position of each node in its parent.
+	index []int
+	def   []int16
+	rep   []int16
+}
+
+func newColumnPath(col *schema.Column) columnPath {
+	var nodes []schema.Node
+	for n := col.SchemaNode(); n.Parent() != nil; n = n.Parent() {
+		nodes = append(nodes, n)
+	}
+	slices.Reverse(nodes)
+	p := columnPath{
+		nodes: nodes,
+		index: make([]int, len(nodes)),
+		def:   make([]int16, len(nodes)),
+		rep:   make([]int16, len(nodes)),
+	}
+	var def, rep int16
+	for i, n := range nodes {
+		p.index[i] = n.Parent().(*schema.GroupNode).FieldIndexByField(n)
+		switch n.RepetitionType() {
+		case parquet.Repetitions.Optional:
+			def++
+		case parquet.Repetitions.Repeated:
+			def++
+			rep++
+		}
+		p.def[i], p.rep[i] = def, rep
+	}
+	return p
+}
+
+// recordGroup holds the fields of a group of a record being assembled.
+// Fields of columns that were not read stay unset.
+type recordGroup struct {
+	values []any
+	set    []bool
+}
+
+func newRecordGroup(n schema.Node) *recordGroup {
+	size := n.(*schema.GroupNode).NumFields()
+	return &recordGroup{values: make([]any, size), set: make([]bool, size)}
+}
+
+func (g *recordGroup) put(i int, v any) {
+	g.values[i], g.set[i] = v, true
+}
+
+// assemble adds the values of one column of a record to root, following
+// the definition levels to find nulls and empty lists, and the repetition
+// levels to find where a new list element starts. Columns below the same
+// repeated node fill the same elements, which are tracked by index.
+func assemble(root *recordGroup, p columnPath, values []dumper.Value, format func(any) any) {
+	// index of the current element of each repeated node
+	idx := make([]int, len(p.nodes))
+	for _, v := range values {
+		cur := root
+		for i, n := range p.nodes {
+			pos := p.index[i]
+			leaf := i == len(p.nodes)-1
+			if n.RepetitionType() == parquet.Repetitions.Repeated {
+				list, _ := cur.values[pos].([]any)
+				if v.Def < p.def[i] {
+					// the list is empty, its parent is defined
+					if !cur.set[pos] {
+						cur.put(pos, []any{})
+					}
+					break
+				}
+				switch {
+				case v.Rep < p.rep[i]:
+					idx[i] = 0
+				case v.Rep == p.rep[i]:
+					idx[i]++
+				}
+				if leaf {
+					list = append(list, format(v.Value))
+					cur.put(pos, list)
+					break
+				}
+				if idx[i] >= len(list) {
+					list = append(list, newRecordGroup(n))
+					cur.put(pos, list)
+				}
+				cur = list[idx[i]].(*recordGroup)
+				continue
+			}
+			if v.Def < p.def[i] {
+				if !cur.set[pos] {
+					cur.put(pos, nil)
+				}
+				break
+			}
+			if leaf {
+				cur.put(pos, format(v.Value))
+				break
+			}
+			if !cur.set[pos] || cur.values[pos] == nil {
+				cur.put(pos, newRecordGroup(n))
+			}
+			cur = cur.values[pos].(*recordGroup)
+		}
+	}
+}
+
+// groupRow converts an assembled group to a Row in schema order.
+func groupRow(n *schema.GroupNode, g *recordGroup) Row {
+	row := make(Row, 0, n.NumFields())
+	for i := range n.NumFields() {
+		if !g.set[i] {
+			continue
+		}
+		f := n.Field(i)
+		row = append(row, Field{Name: f.Name(), Value: nodeValue(f, g.values[i])})
+	}
+	return row
+}
+
+// nodeValue converts an assembled value of n: lists and repeated fields
+// to []any, LIST groups to the list of their elements, MAP groups to a Row
+// keyed by the formatted keys and other groups to a Row.
+func nodeValue(n schema.Node, v any) any {
+	if v == nil {
+		return nil
+	}
+	if n.RepetitionType() == parquet.Repetitions.Repeated {
+		list := v.([]any)
+		out := make([]any, len(list))
+		for i, e := range list {
+			out[i] = elementValue(n, e)
+		}
+		return out
+	}
+	return elementValue(n, v)
+}
+
+// elementValue converts a single value of n, one element when n is
+// repeated.
+func elementValue(n schema.Node, v any) any {
+	g, ok := n.(*schema.GroupNode)
+	if !ok {
+		return v
+	}
+	rg := v.(*recordGroup)
+	switch {
+	case isListGroup(g):
+		return listValue(g, rg)
+	case isMapGroup(g):
+		return mapValue(g, rg)
+	}
+	return groupRow(g, rg)
+}
+
+// isListGroup reports whether g is annotated as a LIST with a single
+// repeated field.
+func isListGroup(g *schema.GroupNode) bool {
+	_, list := g.LogicalType().(schema.ListLogicalType)
+	if !list && g.ConvertedType() != schema.ConvertedTypes.List {
+		return false
+	}
+	return g.NumFields() == 1 && g.Field(0).RepetitionType() == parquet.Repetitions.Repeated
+}
+
+// isMapGroup reports whether g is annotated as a MAP with a single
+// repeated key_value group.
+func isMapGroup(g *schema.GroupNode) bool {
+	_, isMap := g.LogicalType().(schema.MapLogicalType)
+	if !isMap && g.ConvertedType() != schema.ConvertedTypes.Map && g.ConvertedType() != schema.ConvertedTypes.MapKeyValue {
+		return false
+	}
+	if g.NumFields() != 1 || g.Field(0).RepetitionType() != parquet.Repetitions.Repeated {
+		return false
+	}
+	kv, ok := g.Field(0).(*schema.GroupNode)
+	return ok && kv.NumFields() >= 1
+}
+
+// listValue returns the elements of a LIST group. It follows the backward
+// compatibility rules of the Parquet spec for lists written without the
+// three-level structure.
+func listValue(g *schema.GroupNode, rg *recordGroup) []any {
+	list, _ := rg.values[0].([]any)
+	out := make([]any, len(list))
+	rep := g.Field(0)
+	elem, threeLevel := listElement(g)
+	if !threeLevel {
+		for i, e := range list {
+			out[i] = elementValue(rep, e)
+		}
+		return out
+	}
+	for i, e := range list {
+		erg := e.(*recordGroup)
+		if erg.set[0] {
+			out[i] = nodeValue(elem, erg.values[0])
+		}
+	}
+	return out
+}
+
+// listElement returns the element node of a LIST group. threeLevel is
+// false when the repeated field is the element: a primitive, a group
+// with more than one field or one named "array" or "<name>_tuple".
+func listElement(g *schema.GroupNode) (elem schema.Node, threeLevel bool) {
+	rep := g.Field(0)
+	repGroup, ok := rep.(*schema.GroupNode)
+	if !ok || repGroup.NumFields() != 1 || rep.Name() == "array" || rep.Name() == g.Name()+"_tuple" {
+		return rep, false
+	}
+	return repGroup.Field(0), true
+}
+
+// mapValue returns the entries of a MAP group keyed by the formatted key.
+func mapValue(g *schema.GroupNode, rg *recordGroup) Row {
+	list, _ := rg.values[0].([]any)
+	kv := g.Field(0).(*schema.GroupNode)
+	row := make(Row, 0, len(list))
+	for _, e := range list {
+		erg := e.(*recordGroup)
+		key := fmt.Sprint(erg.values[0])
+		var val any
+		if kv.NumFields() > 1 && erg.set[1] {
+			val = nodeValue(kv.Field(1), erg.values[1])
+		}
+		row = append(row, Field{Name: key, Value: val})
+	}
+	return row
+}
@@ -0,0 +1,204 @@
+package parquettools
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/apache/arrow/go/v17/parquet"
+	"github.com/apache/arrow/go/v17/parquet/schema"
+
+	"github.com/jimyag/parquet-tools/internal/dumper"
+)
+
+var (
+	required = parquet.Repetitions.Required
+	optional = parquet.Repetitions.Optional
+	repeated = parquet.Repetitions.Repeated
+)
+
+func leaf(name string, rep parquet.Repetition) schema.Node {
+	return schema.MustPrimitive(schema.NewPrimitiveNode(name, rep, parquet.Types.Int32, -1, -1))
+}
+
+func group(name string, rep parquet.Repetition, fields ...schema.Node) schema.Node {
+	return schema.MustGroup(schema.NewGroupNode(name, rep, fields, -1))
+}
+
+func listGroup(name string, rep parquet.Repetition, field schema.Node) schema.Node {
+	return schema.MustGroup(schema.NewGroupNodeLogical(name, rep, schema.FieldList{field}, schema.ListLogicalType{}, -1))
+}
+
+// record assembles one record of a schema with a single top-level field
+// from the values of each leaf column, keyed by path.
+func record(t *testing.T, field schema.Node, columns map[string][]dumper.Value) Row {
+	t.Helper()
+	sc := schema.NewSchema(schema.MustGroup(schema.NewGroupNode("schema", required, schema.FieldList{field}, -1)))
+	root := newRecordGroup(sc.Root())
+	for i := range sc.NumColumns() {
+		col := sc.Column(i)
+		values, ok := columns[col.Path()]
+		if !ok {
+			t.Fatalf("no values for %s", col.Path())
+		}
+		assemble(root, newColumnPath(col), values, func(v any) any { return v })
+	}
+	return groupRow(sc.Root(), root)
+}
+
+func val(v any, def, rep int16) dumper.Value {
+	return dumper.Value{Value: v, Def: def, Rep: rep}
+}
+
+func TestAssemble(t *testing.T) {
+	threeLevel := listGroup("a", optional, group("list", repeated, leaf("element", optional)))
+	nested := listGroup("a", optional, group("list", repeated,
+		listGroup("element", optional, group("list", repeated, leaf("element", optional)))))
+	mapNode := schema.MustGroup(schema.NewGroupNodeLogical("a", optional, schema.FieldList{
+		group("key_value", repeated, leaf("key", required), leaf("value", optional)),
+	}, schema.MapLogicalType{}, -1))
+	legacyMap := schema.MustGroup(schema.NewGroupNodeConverted("a", optional, schema.FieldList{
+		group("map", repeated, leaf("key", required), leaf("value", optional)),
+	}, schema.ConvertedTypes.MapKeyValue, -1))
+	keysOnly := schema.MustGroup(schema.NewGroupNodeConverted("a", required, schema.FieldList{
+		group("key_value", repeated, leaf("key", required)),
+	}, schema.ConvertedTypes.Map, -1))
+
+	tests := []struct {
+		name    string
+		field   schema.Node
+		columns map[string][]dumper.Value
+		want    any
+	}{
+		{
+			name:    "three-level list",
+			field:   threeLevel,
+			columns: map[string][]dumper.Value{"a.list.element": {val(1, 3, 0), val(nil, 2, 1), val(3, 3, 1)}},
+			want:    []any{1, nil, 3},
+		},
+		{
+			name:    "empty list",
+			field:   threeLevel,
+			columns: map[string][]dumper.Value{"a.list.element": {val(nil, 1, 0)}},
+			want:    []any{},
+		},
+		{
+			name:    "null list",
+			field:   threeLevel,
+			columns: map[string][]dumper.Value{"a.list.element": {val(nil, 0, 0)}},
+			want:    nil,
+		},
+		{
+			name:  "list of lists",
+			field: nested,
+			columns: map[string][]dumper.Value{"a.list.element.list.element": {
+				val(1, 5, 0), val(2, 5, 2), val(nil, 3, 1), val(nil, 2, 1), val(nil, 4, 1),
+			}},
+			want: []any{[]any{1, 2}, []any{}, nil, []any{nil}},
+		},
+		{
+			name:    "two-level list of primitives",
+			field:   listGroup("a", optional, leaf("element", repeated)),
+			columns: map[string][]dumper.Value{"a.element": {val(1, 2, 0), val(2, 2, 1)}},
+			want:    []any{1, 2},
+		},
+		{
+			name:    "two-level list with more than one field",
+			field:   listGroup("a", required, group("pair", repeated, leaf("x", required), leaf("y", optional))),
+			columns: map[string][]dumper.Value{"a.pair.x": {val(1, 1, 0), val(3, 1, 1)}, "a.pair.y": {val(2, 2, 0), val(nil, 1, 1)}},
+			want:    []any{Row{{"x", 1}, {"y", 2}}, Row{{"x", 3}, {"y", nil}}},
+		},
+		{
+			name:    "repeated group named array",
+			field:   listGroup("a", optional, group("array", repeated, leaf("x", required))),
+			columns: map[string][]dumper.Value{"a.array.x": {val(1, 2, 0), val(2, 2, 1)}},
+			want:    []any{Row{{"x", 1}}, Row{{"x", 2}}},
+		},
+		{
+			name:    "repeated group named after the list",
+			field:   listGroup("a", optional, group("a_tuple", repeated, leaf("x", optional))),
+			columns: map[string][]dumper.Value{"a.a_tuple.x": {val(1, 3, 0), val(nil, 2, 1)}},
+			want:    []any{Row{{"x", 1}}, Row{{"x", nil}}},
+		},
+		{
+			name:    "converted LIST",
+			field:   schema.MustGroup(schema.NewGroupNodeConverted("a", required, schema.FieldList{group("bag", repeated, leaf("array", optional))}, schema.ConvertedTypes.List, -1)),
+			columns: map[string][]dumper.Value{"a.bag.array": {val(7, 2, 0)}},
+			want:    []any{7},
+		},
+		{
+			name:    "repeated primitive",
+			field:   leaf("a", repeated),
+			columns: map[string][]dumper.Value{"a": {val(1, 1, 0), val(2, 1, 1)}},
+			want:    []any{1, 2},
+		},
+		{
+			name:    "empty repeated primitive",
+			field:   leaf("a", repeated),
+			columns: map[string][]dumper.Value{"a": {val(nil, 0, 0)}},
+			want:    []any{},
+		},
+		{
+			name:    "repeated group",
+			field:   group("a", repeated, leaf("x", optional)),
+			columns: map[string][]dumper.Value{"a.x": {val(nil, 1, 0), val(2, 2, 1)}},
+			want:    []any{Row{{"x", nil}}, Row{{"x", 2}}},
+		},
+		{
+			name:    "map",
+			field:   mapNode,
+			columns: map[string][]dumper.Value{"a.key_value.key": {val("k", 2, 0), val("j", 2, 1)}, "a.key_value.value": {val(1, 3, 0), val(nil, 2, 1)}},
+			want:    Row{{"k", 1}, {"j", nil}},
+		},
+		{
+			name:    "empty map",
+			field:   mapNode,
+			columns: map[string][]dumper.Value{"a.key_value.key": {val(nil, 1, 0)}, "a.key_value.value": {val(nil, 1, 0)}},
+			want:    Row{},
+		},
+		{
+			name:    "null map",
+			field:   mapNode,
+			columns: map[string][]dumper.Value{"a.key_value.key": {val(nil, 0, 0)}, "a.key_value.value": {val(nil, 0, 0)}},
+			want:    nil,
+		},
+		{
+			name:    "MAP_KEY_VALUE map",
+			field:   legacyMap,
+			columns: map[string][]dumper.Value{"a.map.key": {val(1, 2, 0)}, "a.map.value": {val(2, 3, 0)}},
+			want:    Row{{"1", 2}},
+		},
+		{
+			name:    "map without values",
+			field:   keysOnly,
+			columns: map[string][]dumper.Value{"a.key_value.key": {val("k", 1, 0)}},
+			want:    Row{{"k", nil}},
+		},
+		{
+			name:    "null optional group",
+			field:   group("a", optional, leaf("x", optional), group("b", optional, leaf("y", required))),
+			columns: map[string][]dumper.Value{"a.x": {val(nil, 0, 0)}, "a.b.y": {val(nil, 0, 0)}},
+			want:    nil,
+		},
+		{
+			name:    "optional group with null fields",
+			field:   group("a", optional, leaf("x", optional), group("b", optional, leaf("y", required))),
+			columns: map[string][]dumper.Value{"a.x": {val(nil, 1, 0)}, "a.b.y": {val(nil, 1, 0)}},
+			want:    Row{{"x", nil}, {"b", nil}},
+		},
+		{
+			name:    "nested group",
+			field:   group("a", optional, leaf("x", optional), group("b", optional, leaf("y", required))),
+			columns: map[string][]dumper.Value{"a.x": {val(1, 2, 0)}, "a.b.y": {val(2, 2, 0)}},
+			want:    Row{{"x", 1}, {"b", Row{{"y", 2}}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := record(t, tt.field, tt.columns)
+			want := Row{{"a", tt.want}}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %#v, want %#v", got, want)
+			}
+		})
+	}
+}
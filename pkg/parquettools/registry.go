@@ -0,0 +1,96 @@
+package parquettools
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/apache/arrow/go/v17/parquet"
+	"github.com/apache/arrow/go/v17/parquet/file"
+
+	"github.com/jimyag/parquet-tools/internal/reader"
+)
+
+type (
+	// Range is a byte range of a file.
+	Range = reader.Range
+	// Prefetcher can be implemented by the reader of an Object to fetch
+	// the column chunks of a row group ahead of decoding.
+	Prefetcher = reader.Prefetcher
+)
+
+// Object is a file opened by an Opener.
+type Object struct {
+	// Reader reads the file. It is closed with the file.Reader when it
+	// implements io.Closer.
+	Reader parquet.ReaderAtSeeker
+	Size   int64
+	// ETag and ModTime identify the version of the file. Remote objects
+	// with either of them are kept in the block cache.
+	ETag    string
+	ModTime time.Time
+	// Remote objects go through the block cache.
+	Remote bool
+	// Parquet is set by Openers that open the Parquet file themselves,
+	// such as the local one with Options.Mmap, which uses the memory map
+	// of arrow. Reader then only serves the page indexes.
+	Parquet *file.Reader
+}
+
+// Opener opens the files of a URL scheme.
+type Opener interface {
+	Open(ctx context.Context, uri string, opts Options) (*Object, error)
+}
+
+// Lister is implemented by Openers that can expand prefixes and globs,
+// see Expand.
+type Lister interface {
+	// List returns the files whose URI starts with prefix, which ends in
+	// "/" or is the root of a bucket.
+	List(ctx context.Context, prefix string, opts Options) ([]string, error)
+}
+
+// OpenerFunc adapts a function to an Opener.
+type OpenerFunc func(ctx context.Context, uri string, opts Options) (*Object, error)
+
+func (f OpenerFunc) Open(ctx context.Context, uri string, opts Options) (*Object, error) {
+	return f(ctx, uri, opts)
+}
+
+var (
+	openersMu sync.RWMutex
+	openers   = map[string]Opener{}
+)
+
+// Register makes o open the URLs of scheme, replacing the opener
+// registered before. Local paths have the scheme "file".
+func Register(scheme string, o Opener) {
+	openersMu.Lock()
+	defer openersMu.Unlock()
+	openers[scheme] = o
+}
+
+// ExternalReaderPrefix names the binaries on PATH that serve the schemes
+// without a registered Opener, see ExternalOpener.
+const ExternalReaderPrefix = "parquet-tools-reader-"
+
+// openerFor returns the opener registered for scheme, or the external
+// reader found on PATH.
+func openerFor(scheme string) (Opener, error) {
+	if scheme == "" {
+		scheme = localScheme
+	}
+	openersMu.RLock()
+	o, ok := openers[scheme]
+	openersMu.RUnlock()
+	if ok {
+		return o, nil
+	}
+	path, err := exec.LookPath(ExternalReaderPrefix + scheme)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported scheme %q", scheme)
+	}
+	return &ExternalOpener{Path: path}, nil
+}
@@ -0,0 +1,260 @@
+package parquettools
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/apache/arrow/go/v17/parquet/file"
+
+	"github.com/jimyag/parquet-tools/internal/dumper"
+)
+
+// RowOptions configure a RowIterator.
+type RowOptions struct {
+	// ConvertInt96AsTime formats int96 values as timestamps.
+	ConvertInt96AsTime bool
+	// Location is where timestamps adjusted to UTC are shown, UTC when
+	// nil.
+	Location *time.Location
+	// Columns are the leaf columns read, see SelectColumns, nil reads all.
+	// Only their column chunks are fetched and decoded, the rows hold the
+	// fields of the groups above them.
+	Columns []int
+	// Where skips the rows it does not match. Its columns are read as
+	// well, the rows only hold the fields of Columns.
+	Where *Predicate
+}
+
+// Field is a value of a row. Values are rendered by the logical type of
+// their column: decimals are a string with their exact digits, JSON a
+// json.RawMessage, unsigned integers a uint32 or uint64 and dates, times,
+// timestamps, UUIDs and intervals a string. Values without a logical type
+// are a bool, int32, int64, float32 or float64, or formatted for the other
+// physical types. Groups and maps are a Row, lists and repeated fields a
+// []any, and nulls nil.
+type Field struct {
+	Name  string
+	Value any
+}
+
+// Row holds the fields of a record, or of a group or map in a record, in
+// schema order.
+type Row []Field
+
+// RowIterator reads the records of a file one row group after the other,
+// prefetching the column chunks of remote files. Nested records are
+// rebuilt from the definition and repetition levels of the columns. With
+// Where, the row groups and pages that cannot match are skipped, see
+// PlanScan.
+type RowIterator struct {
+	rdr  *File
+	opts RowOptions
+
+	planner  *scanPlanner
+	rowGroup int
+	scanners []*columnScanner
+	// rows are the rows of the row group that can match, nil for all,
+	// and next the first row not read yet.
+	rows rowRanges
+	next int64
+	err  error
+}
+
+// columnScanner reads the records of a column chunk.
+type columnScanner struct {
+	*dumper.Dumper
+	path columnPath
+	// output is set for the columns of the rows, the others are only
+	// read for Where.
+	output bool
+	// spans are the rows of the pages read, nil when all are, and row
+	// the row of the next record, in spans[span].
+	spans []RowRange
+	span  int
+	row   int64
+}
+
+// nextRecord reads the next record and moves row past it.
+func (s *columnScanner) nextRecord() ([]dumper.Value, bool) {
+	values, ok := s.NextRecord()
+	if !ok {
+		return nil, false
+	}
+	s.row++
+	if s.spans != nil && s.row == s.spans[s.span].End && s.span+1 < len(s.spans) {
+		s.span++
+		s.row = s.spans[s.span].Start
+	}
+	return values, true
+}
+
+// seek skips the records before row, which is in the pages read.
+func (s *columnScanner) seek(row int64) {
+	for s.row < row {
+		if _, ok := s.nextRecord(); !ok {
+			return
+		}
+	}
+}
+
+func NewRowIterator(rdr *File, opts RowOptions) *RowIterator {
+	return &RowIterator{rdr: rdr, opts: opts}
+}
+
+// Next returns the next row, false after the last row or an error.
+func (it *RowIterator) Next() (Row, bool) {
+	for it.err == nil {
+		if it.scanners == nil {
+			if it.rowGroup >= it.rdr.NumRowGroups() {
+				return nil, false
+			}
+			it.err = it.openRowGroup()
+			it.rowGroup++
+			continue
+		}
+		row, ok := it.readRow()
+		if !ok {
+			it.scanners = nil
+			continue
+		}
+		if row != nil {
+			return row, true
+		}
+	}
+	return nil, false
+}
+
+// Err returns the error that stopped Next.
+func (it *RowIterator) Err() error {
+	return it.err
+}
+
+// openRowGroup opens the scanners of the next row group, none when it is
+// pruned.
+func (it *RowIterator) openRowGroup() error {
+	rgr := it.rdr.RowGroup(it.rowGroup)
+	sc := it.rdr.MetaData().Schema
+	cols := it.opts.Columns
+	if cols == nil {
+		cols = make([]int, sc.NumColumns())
+		for c := range cols {
+			cols[c] = c
+		}
+	}
+	output := make([]bool, sc.NumColumns())
+	for _, c := range cols {
+		output[c] = true
+	}
+	if it.opts.Where == nil {
+		it.rdr.PrefetchRowGroup(rgr.MetaData(), cols)
+		it.rows, it.next = nil, 0
+		scanners := make([]*columnScanner, len(cols))
+		for i, c := range cols {
+			col, err := rgr.Column(c)
+			if err != nil {
+				return fmt.Errorf("error getting column %d: %w", c, err)
+			}
+			scanners[i] = it.newScanner(col, c, output[c], nil)
+		}
+		it.scanners = scanners
+		return nil
+	}
+	if it.planner == nil {
+		it.planner = newScanPlanner(it.rdr, it.opts.Where, cols)
+	}
+	plan, err := it.planner.rowGroup(it.rowGroup)
+	if err != nil {
+		return err
+	}
+	if plan.Skipped {
+		return nil
+	}
+	it.rdr.prefetchRanges(plan.ranges(rgr.MetaData()))
+	it.rows, it.next = plan.Rows, 0
+	scanners := make([]*columnScanner, len(plan.Columns))
+	for i, cp := range plan.Columns {
+		col, spans, err := it.planner.columnReader(rgr, cp)
+		if err != nil {
+			return fmt.Errorf("error getting column %d: %w", cp.Column, err)
+		}
+		scanners[i] = it.newScanner(col, cp.Column, output[cp.Column], spans)
+	}
+	it.scanners = scanners
+	return nil
+}
+
+func (it *RowIterator) newScanner(col file.ColumnChunkReader, c int, output bool, spans []RowRange) *columnScanner {
+	s := &columnScanner{
+		Dumper: dumper.NewDumper(col, it.opts.ConvertInt96AsTime, it.opts.Location),
+		path:   newColumnPath(it.rdr.MetaData().Schema.Column(c)),
+		output: output,
+		spans:  spans,
+	}
+	if spans != nil {
+		s.row = spans[0].Start
+	}
+	return s
+}
+
+// nextRow returns the next row of the row group to read, false after the
+// last one that can match.
+func (it *RowIterator) nextRow() (int64, bool) {
+	if it.rows == nil {
+		return it.next, true
+	}
+	for _, r := range it.rows {
+		if it.next < r.End {
+			return max(it.next, r.Start), true
+		}
+	}
+	return 0, false
+}
+
+// readRow reads the next record, false at the end of the row group. The
+// row is nil when Where does not match it.
+func (it *RowIterator) readRow() (Row, bool) {
+	target, ok := it.nextRow()
+	if !ok {
+		return nil, false
+	}
+	it.next = target + 1
+	schemaRoot := it.rdr.MetaData().Schema.Root()
+	root := newRecordGroup(schemaRoot)
+	// the rows leave out the columns only read for Where
+	var out *recordGroup
+	if slices.ContainsFunc(it.scanners, func(s *columnScanner) bool { return !s.output }) {
+		out = newRecordGroup(schemaRoot)
+	}
+	data := false
+	for _, s := range it.scanners {
+		s.seek(target)
+		values, ok := s.nextRecord()
+		if !ok {
+			continue
+		}
+		data = true
+		assemble(root, s.path, values, s.JSONValue)
+		if out != nil && s.output {
+			assemble(out, s.path, values, s.JSONValue)
+		}
+	}
+	if !data {
+		return nil, false
+	}
+	row := groupRow(schemaRoot, root)
+	if it.opts.Where != nil && !it.opts.Where.Match(row) {
+		return nil, true
+	}
+	if out != nil {
+		return groupRow(schemaRoot, out), true
+	}
+	return row, true
+}
+
+// mergeColumns returns the sorted union of two sorted column lists.
+func mergeColumns(a, b []int) []int {
+	out := slices.Concat(a, b)
+	slices.Sort(out)
+	return slices.Compact(out)
+}
@@ -0,0 +1,482 @@
+package parquettools
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/processcreds"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/jimyag/log"
+
+	"github.com/jimyag/parquet-tools/internal/reader"
+)
+
+type ObjectVersion = reader.ObjectVersion
+
+// ListVersions returns the versions and delete markers of an s3 object,
+// newest first.
+func ListVersions(ctx context.Context, uri string, opts Options) ([]ObjectVersion, error) {
+	bucket, key, err := reader.ParsePath(uri)
+	if err != nil {
+		return nil, err
+	}
+	var versions []ObjectVersion
+	list := func(client *s3.S3) error {
+		var err error
+		versions, err = reader.ListVersions(ctx, bucket, key, client)
+		return err
+	}
+	client, err := s3ClientFor(opts, uri, list)
+	if err != nil {
+		return nil, err
+	}
+	// probing already listed the versions with the chosen client
+	if versions == nil {
+		if err := list(client); err != nil {
+			return nil, err
+		}
+	}
+	return versions, nil
+}
+
+func newS3Client(c S3Config) (*s3.S3, error) {
+	sess, err := newS3Session(c)
+	if err != nil {
+		return nil, err
+	}
+	key, err := sseCustomerKey(c)
+	if err != nil {
+		return nil, err
+	}
+	// The shared client is set on the s3 client rather than the session,
+	// so that the session's handling of AWS_CA_BUNDLE doesn't modify it.
+	// NewHTTPClient trusts AWS_CA_BUNDLE in the shared client instead.
+	client := s3.New(sess, &aws.Config{HTTPClient: httpClient})
+	reader.ApplyS3Options(client, reader.S3Options{
+		SSECustomerKey: key,
+		RequesterPays:  c.RequesterPays,
+	})
+	return client, nil
+}
+
+// sseCustomerKey loads the SSE-C key of c, nil if it has none.
+func sseCustomerKey(c S3Config) ([]byte, error) {
+	const keySize = 32
+	var encoded, source string
+	switch {
+	case c.SSECustomerKey != "":
+		encoded, source = c.SSECustomerKey, "sse_customer_key"
+	case c.SSECustomerKeyFile != "":
+		data, err := os.ReadFile(c.SSECustomerKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) == keySize {
+			return data, nil
+		}
+		encoded, source = strings.TrimSpace(string(data)), c.SSECustomerKeyFile
+	case c.SSECustomerKeyEnv != "":
+		encoded, source = os.Getenv(c.SSECustomerKeyEnv), c.SSECustomerKeyEnv
+		if encoded == "" {
+			return nil, fmt.Errorf("sse_customer_key_env %s is not set", c.SSECustomerKeyEnv)
+		}
+	default:
+		return nil, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SSE-C key in %s: %w", source, err)
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("invalid SSE-C key in %s: got %d bytes, want %d", source, len(key), keySize)
+	}
+	return key, nil
+}
+
+// newS3Session builds a session for a [[s3]] entry. Sources that don't set
+// credentials explicitly use the default AWS chain of the session: env
+// vars, shared config and credentials files (including SSO and
+// credential_process profiles), web identity tokens and EC2/ECS roles.
+func newS3Session(c S3Config) (*session.Session, error) {
+	awsCfg := aws.Config{
+		DisableSSL:       aws.Bool(c.DisableSSL),
+		S3ForcePathStyle: aws.Bool(c.ForcePathStyle),
+		// requests are retried by the readers, see reader.RetryOptions
+		MaxRetries: aws.Int(0),
+	}
+	// aws-sdk-go v1 does not read AWS_ENDPOINT_URL itself.
+	endpoint := c.EndPoint
+	if endpoint == "" {
+		endpoint = os.Getenv("AWS_ENDPOINT_URL_S3")
+	}
+	if endpoint == "" {
+		endpoint = os.Getenv("AWS_ENDPOINT_URL")
+	}
+	if endpoint != "" {
+		awsCfg.Endpoint = aws.String(endpoint)
+	}
+	if c.Region != "" {
+		awsCfg.Region = aws.String(c.Region)
+	}
+	opts := session.Options{
+		Config:            awsCfg,
+		Profile:           c.Profile,
+		SharedConfigState: session.SharedConfigEnable,
+	}
+
+	switch c.CredentialSource {
+	case "":
+		if c.AccessKey != "" || c.SecretKey != "" {
+			opts.Config.Credentials = credentials.NewStaticCredentials(c.AccessKey, c.SecretKey, c.SessionToken)
+		}
+	case CredentialSourceStatic, CredentialSourceSessionToken:
+		if c.AccessKey == "" && c.SecretKey == "" {
+			return nil, fmt.Errorf("s3 config for %s has no access_key and secret_key", endpoint)
+		}
+		if c.CredentialSource == CredentialSourceSessionToken && c.SessionToken == "" {
+			return nil, fmt.Errorf("credential_source %q needs session_token", c.CredentialSource)
+		}
+		opts.Config.Credentials = credentials.NewStaticCredentials(c.AccessKey, c.SecretKey, c.SessionToken)
+	case CredentialSourceEnv:
+		opts.Config.Credentials = credentials.NewEnvCredentials()
+	case CredentialSourceCredentialProcess:
+		if c.CredentialProcess == "" {
+			return nil, fmt.Errorf("credential_source %q needs credential_process", c.CredentialSource)
+		}
+		opts.Config.Credentials = processcreds.NewCredentials(c.CredentialProcess)
+	case CredentialSourceAssumeRole:
+		if c.RoleARN == "" {
+			return nil, fmt.Errorf("credential_source %q needs role_arn", c.CredentialSource)
+		}
+		// the role is assumed with the static keys of the entry when set,
+		// otherwise with the default chain (or profile)
+		if c.AccessKey != "" {
+			opts.Config.Credentials = credentials.NewStaticCredentials(c.AccessKey, c.SecretKey, c.SessionToken)
+		}
+		base, err := session.NewSessionWithOptions(opts)
+		if err != nil {
+			return nil, err
+		}
+		opts.Config.Credentials = stscreds.NewCredentials(base, c.RoleARN, func(p *stscreds.AssumeRoleProvider) {
+			if c.ExternalID != "" {
+				p.ExternalID = aws.String(c.ExternalID)
+			}
+			if c.RoleSessionName != "" {
+				p.RoleSessionName = c.RoleSessionName
+			}
+		})
+	case CredentialSourceProfile, CredentialSourceDefault:
+	default:
+		return nil, fmt.Errorf("unknown credential_source %q", c.CredentialSource)
+	}
+	return session.NewSessionWithOptions(opts)
+}
+
+// resolveS3 maps an s3 url to a [[s3]] entry without any request. It picks
+// the entry named by the url user (s3://name@bucket/key) or opts.S3Profile,
+// then the first entry with the bucket in its scopes, then the first entry
+// with a scope pattern (see path.Match) matching the bucket. It returns -1
+// when no entry matches.
+func resolveS3(opts Options, filename string) (int, error) {
+	cfg := opts.Config
+	u, err := url.Parse(filename)
+	if err != nil {
+		return -1, err
+	}
+	name := opts.S3Profile
+	if u.User != nil && u.User.Username() != "" {
+		name = u.User.Username()
+	}
+	if name != "" {
+		i := slices.IndexFunc(cfg.S3, func(c S3Config) bool { return c.Name == name })
+		if i < 0 {
+			return -1, fmt.Errorf("no [[s3]] config entry named %q", name)
+		}
+		return i, nil
+	}
+
+	bucket := u.Host
+	if i := slices.IndexFunc(cfg.S3, func(c S3Config) bool {
+		return slices.Contains(c.Scopes, bucket)
+	}); i >= 0 {
+		return i, nil
+	}
+	return slices.IndexFunc(cfg.S3, func(c S3Config) bool {
+		return slices.ContainsFunc(c.Scopes, func(scope string) bool {
+			ok, _ := path.Match(scope, bucket)
+			return ok
+		})
+	}), nil
+}
+
+// s3ClientFor returns the client for an s3 url. When resolveS3 finds no
+// entry and opts.S3Probe is set, every entry is tried with probe until one
+// succeeds, and with opts.S3LearnScopes the bucket is saved in its scopes.
+// Otherwise the default AWS credential chain is used.
+func s3ClientFor(opts Options, filename string, probe func(*s3.S3) error) (*s3.S3, error) {
+	cfg := opts.Config
+	i, err := resolveS3(opts, filename)
+	if err != nil {
+		return nil, err
+	}
+	if i >= 0 {
+		return newS3Client(cfg.S3[i])
+	}
+
+	if opts.S3Probe {
+		bucket, _, err := reader.ParsePath(filename)
+		if err != nil {
+			return nil, err
+		}
+		if i, ok := probedS3.Load(bucket); ok && i.(int) < len(cfg.S3) {
+			return newS3Client(cfg.S3[i.(int)])
+		}
+		for i, c := range cfg.S3 {
+			client, err := newS3Client(c)
+			if err != nil {
+				return nil, err
+			}
+			if err := probe(client); err != nil {
+				log.Debug().Str("endpoint", c.EndPoint).Str("error", err.Error()).Msg("s3 probe failed")
+				continue
+			}
+			probedS3.Store(bucket, i)
+			if opts.S3LearnScopes && opts.ConfigFile != "" {
+				if err := learnS3Scope(opts.ConfigFile, i, c, bucket); err != nil {
+					log.Warn(err).Msg("error saving s3 scope")
+				}
+			}
+			return client, nil
+		}
+	}
+	return newS3Client(S3Config{CredentialSource: CredentialSourceDefault})
+}
+
+// probedS3 maps the buckets found by probing to their entry, so the other
+// files of a bucket don't probe again.
+var probedS3 sync.Map
+
+// learnS3Scope adds bucket to the scopes of the i-th entry of configFile.
+// The config file is re-read under a lock and replaced atomically, so
+// parallel invocations neither race nor leave a truncated file. The entry
+// must still look like c, in case the file was edited meanwhile. Only its
+// scopes are rewritten, the comments and layout of the file are kept.
+func learnS3Scope(configFile string, i int, c S3Config, bucket string) error {
+	unlock, err := lockFile(configFile + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	text, err := os.ReadFile(configFile)
+	if err != nil {
+		return err
+	}
+	cfg := Config{}
+	if _, err := toml.Decode(string(text), &cfg); err != nil {
+		return err
+	}
+	if i >= len(cfg.S3) || cfg.S3[i].Name != c.Name || cfg.S3[i].EndPoint != c.EndPoint || cfg.S3[i].AccessKey != c.AccessKey {
+		return fmt.Errorf("s3 config file %s changed, not saving scope %s", configFile, bucket)
+	}
+	if slices.Contains(cfg.S3[i].Scopes, bucket) {
+		return nil
+	}
+	patched, err := addS3Scope(text, i, bucket)
+	if err != nil {
+		return fmt.Errorf("error saving scope %s to %s: %w", bucket, configFile, err)
+	}
+	// the patched file must decode to the same config with one more scope
+	want := cfg
+	want.S3 = slices.Clone(cfg.S3)
+	want.S3[i].Scopes = append(slices.Clone(cfg.S3[i].Scopes), bucket)
+	got := Config{}
+	if _, err := toml.Decode(string(patched), &got); err != nil || !reflect.DeepEqual(got, want) {
+		return fmt.Errorf("error saving scope %s to %s: the file layout is not supported", bucket, configFile)
+	}
+
+	info, err := os.Stat(configFile)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(configFile), ".s3-config-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(patched); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), configFile)
+}
+
+// tomlHeader matches a table or array of tables header line.
+var tomlHeader = regexp.MustCompile(`^\s*\[\[?\s*([A-Za-z0-9_."' -]+?)\s*\]\]?\s*(#.*)?$`)
+
+// tomlScopes matches the scopes key of an entry.
+var tomlScopes = regexp.MustCompile(`^\s*(scopes|"scopes")\s*=`)
+
+// addS3Scope adds bucket to the scopes array of the i-th [[s3]] entry of
+// a config file, or adds a scopes key after the last key of the entry.
+func addS3Scope(text []byte, i int, bucket string) ([]byte, error) {
+	lines := strings.SplitAfter(string(text), "\n")
+	start, end := -1, len(lines)
+	n := 0
+	for l, line := range lines {
+		m := tomlHeader.FindStringSubmatch(strings.TrimRight(line, "\r\n"))
+		if m == nil {
+			continue
+		}
+		if start >= 0 {
+			end = l
+			break
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), "[[") && m[1] == "s3" {
+			if n == i {
+				start = l
+			}
+			n++
+		}
+	}
+	if start < 0 {
+		return nil, fmt.Errorf("no [[s3]] entry %d", i)
+	}
+	value := strconv.Quote(bucket)
+
+	for l := start + 1; l < end; l++ {
+		loc := tomlScopes.FindStringIndex(lines[l])
+		if loc == nil {
+			continue
+		}
+		// find the closing bracket of the array, which may span lines
+		rest := strings.Join(lines[l:end], "")
+		off := loc[1]
+		close, last, err := tomlArrayEnd(rest, off)
+		if err != nil {
+			return nil, err
+		}
+		insert := value
+		if last != '[' && last != ',' {
+			insert = ", " + value
+		}
+		patched := rest[:close] + insert + rest[close:]
+		return []byte(strings.Join(lines[:l], "") + patched + strings.Join(lines[end:], "")), nil
+	}
+
+	// no scopes yet, add them after the last key of the entry
+	after := start
+	indent := ""
+	for l := start + 1; l < end; l++ {
+		trimmed := strings.TrimSpace(lines[l])
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		after = l
+		if strings.Contains(trimmed, "=") {
+			indent = lines[l][:len(lines[l])-len(strings.TrimLeft(lines[l], " \t"))]
+		}
+	}
+	line := indent + "scopes = [" + value + "]\n"
+	if !strings.HasSuffix(lines[after], "\n") {
+		line = "\n" + line
+	}
+	out := strings.Join(lines[:after+1], "") + line + strings.Join(lines[after+1:], "")
+	return []byte(out), nil
+}
+
+// tomlArrayEnd returns the offset of the bracket closing the array that
+// starts after off in s, and the last character of the array before it
+// outside of strings, comments and spaces.
+func tomlArrayEnd(s string, off int) (int, byte, error) {
+	depth := 0
+	var last byte
+	for p := off; p < len(s); p++ {
+		switch c := s[p]; c {
+		case ' ', '\t', '\r', '\n':
+		case '#':
+			for p < len(s) && s[p] != '\n' {
+				p++
+			}
+		case '"', '\'':
+			p++
+			for p < len(s) && s[p] != c {
+				if c == '"' && s[p] == '\\' {
+					p++
+				}
+				p++
+			}
+			if p >= len(s) {
+				return 0, 0, errors.New("unterminated string in scopes")
+			}
+			last = c
+		case '[':
+			depth++
+			last = c
+		case ']':
+			depth--
+			if depth == 0 {
+				return p, last, nil
+			}
+			last = c
+		default:
+			if depth == 0 {
+				return 0, 0, errors.New("scopes is not an array")
+			}
+			last = c
+		}
+	}
+	return 0, 0, errors.New("unterminated scopes array")
+}
+
+// lockFile takes an exclusive lock by creating name, waiting for other
+// holders for up to lockTimeout. Other processes' locks are never removed,
+// a lock left over by a crashed process must be deleted by hand.
+func lockFile(name string) (unlock func(), err error) {
+	const lockTimeout = 10 * time.Second
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(name) }, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, err
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timeout waiting for lock %s, remove it if no other parquet-tools is running", name)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
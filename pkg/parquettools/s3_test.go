@@ -0,0 +1,133 @@
+package parquettools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAddS3Scope(t *testing.T) {
+	tests := []struct {
+		name string
+		i    int
+		in   string
+		want string
+	}{
+		{
+			name: "append",
+			in: `# storage
+[[s3]]
+  name = "a" # first
+  scopes = ["x"] # learned
+`,
+			want: `# storage
+[[s3]]
+  name = "a" # first
+  scopes = ["x", "bkt"] # learned
+`,
+		},
+		{
+			name: "empty",
+			in:   "[[s3]]\nscopes = []\n",
+			want: "[[s3]]\nscopes = [\"bkt\"]\n",
+		},
+		{
+			name: "multiline",
+			in:   "[[s3]]\nscopes = [\n  \"x\", # ]\n  'y',\n]\n",
+			want: "[[s3]]\nscopes = [\n  \"x\", # ]\n  'y',\n\"bkt\"]\n",
+		},
+		{
+			name: "second entry",
+			i:    1,
+			in: `[[s3]]
+name = "a"
+scopes = ["x"]
+
+[transport]
+timeout = "1s"
+
+[[s3]]
+name = "b"
+  endpoint = "http://e"
+
+# trailing comment
+[[gcs]]
+scopes = []
+`,
+			want: `[[s3]]
+name = "a"
+scopes = ["x"]
+
+[transport]
+timeout = "1s"
+
+[[s3]]
+name = "b"
+  endpoint = "http://e"
+  scopes = ["bkt"]
+
+# trailing comment
+[[gcs]]
+scopes = []
+`,
+		},
+		{
+			name: "no trailing newline",
+			in:   `[[s3]]` + "\n" + `name = "a"`,
+			want: "[[s3]]\nname = \"a\"\nscopes = [\"bkt\"]\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := addS3Scope([]byte(tt.in), tt.i, "bkt")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddS3ScopeErrors(t *testing.T) {
+	tests := map[string]string{
+		"no entry":     "[[gcs]]\nscopes = []\n",
+		"not an array": "[[s3]]\nscopes = \"x\"\n",
+		"unterminated": "[[s3]]\nscopes = [\"x\"\n",
+	}
+	for name, in := range tests {
+		if got, err := addS3Scope([]byte(in), 0, "bkt"); err == nil {
+			t.Errorf("%s: got %q, want an error", name, got)
+		}
+	}
+}
+
+func TestLearnS3Scope(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "config.toml")
+	in := "# keep me\n[[s3]]\nname = \"a\"\nendpoint = \"http://e\"\n"
+	if err := os.WriteFile(name, []byte(in), 0600); err != nil {
+		t.Fatal(err)
+	}
+	c := S3Config{Name: "a", EndPoint: "http://e"}
+	for range 2 {
+		if err := learnS3Scope(name, 0, c, "bkt"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := in + "scopes = [\"bkt\"]\n"; string(got) != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if _, err := os.Stat(name + ".lock"); !os.IsNotExist(err) {
+		t.Errorf("lock left behind: %v", err)
+	}
+
+	// the entry no longer matches the one probed
+	if err := learnS3Scope(name, 0, S3Config{Name: "b"}, "x"); err == nil {
+		t.Error("want an error for a changed entry")
+	}
+}
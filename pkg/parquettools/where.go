@@ -0,0 +1,888 @@
+package parquettools
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow/go/v17/parquet"
+	"github.com/apache/arrow/go/v17/parquet/schema"
+
+	"github.com/jimyag/parquet-tools/internal/predicate"
+)
+
+// Predicate is a row filter expression bound to the schema of a file, see
+// NewPredicate.
+type Predicate struct {
+	expr    predicate.Expr
+	cond    cond
+	columns []int
+}
+
+// NewPredicate parses a filter expression such as
+//
+//	country = 'DE' AND amount > 100 AND ts BETWEEN '2024-01-01' AND '2024-02-01'
+//
+// and binds its columns to sc. It supports =, !=, <>, <, <=, >, >=,
+// [NOT] BETWEEN, [NOT] IN, IS [NOT] NULL, [NOT] LIKE, REGEXP or ~ for
+// regular expressions, AND, OR, NOT and parentheses.
+//
+// Columns are dot separated paths of field names. The elements of a LIST
+// are reached through the list, with or without the names of its repeated
+// group and element, and the values of a MAP by their key, as in
+// "attrs.color", or all of them with "attrs.key_value.value". A column
+// below a list matches when any of its elements does.
+//
+// Literals are converted to the logical type of the column they are
+// compared with: numbers are compared exactly, decimals included, and
+// strings are parsed as dates, times or timestamps. Timestamps without a
+// zone are read in loc for columns adjusted to UTC. Byte arrays without a
+// logical type are compared as the string of their bytes. LIKE and regular
+// expressions match the values as cat prints them, those byte arrays
+// excepted. Comparisons with null are unknown, as in SQL, and rows only
+// match when the expression is true.
+func NewPredicate(expr string, sc *schema.Schema, loc *time.Location) (*Predicate, error) {
+	e, err := predicate.Parse(expr)
+	if err != nil {
+		return nil, err
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	b := &binder{sc: sc, loc: loc}
+	c, err := b.bind(e)
+	if err != nil {
+		return nil, err
+	}
+	matched := make([]bool, sc.NumColumns())
+	for _, prefix := range b.need {
+		for i := range sc.NumColumns() {
+			if hasPrefix(sc.Column(i).ColumnPath(), prefix) {
+				matched[i] = true
+			}
+		}
+	}
+	p := &Predicate{expr: e, cond: c}
+	for i, ok := range matched {
+		if ok {
+			p.columns = append(p.columns, i)
+		}
+	}
+	return p, nil
+}
+
+// Match reports whether the expression is true for row, which holds the
+// fields of at least the columns of the predicate.
+func (p *Predicate) Match(row Row) bool {
+	return p.cond.eval(row) == tTrue
+}
+
+// Columns returns the leaf columns the predicate reads, in schema order.
+func (p *Predicate) Columns() []int {
+	return p.columns
+}
+
+func (p *Predicate) String() string {
+	return p.expr.String()
+}
+
+func hasPrefix(path, prefix []string) bool {
+	return len(path) >= len(prefix) && slices.Equal(path[:len(prefix)], prefix)
+}
+
+// truth is a value of SQL three-valued logic, ordered so that AND is the
+// minimum and OR the maximum.
+type truth int8
+
+const (
+	tFalse truth = iota
+	tUnknown
+	tTrue
+)
+
+func truthOf(b bool) truth {
+	if b {
+		return tTrue
+	}
+	return tFalse
+}
+
+type cond interface {
+	eval(row Row) truth
+	// prune returns the rows of a row group where the condition can be
+	// true and those where it can be false, from the statistics of the
+	// column chunks or pages, see prune.go.
+	prune(s *pruneScope) (canTrue, canFalse rowRanges)
+}
+
+type (
+	andCond struct{ left, right cond }
+	orCond  struct{ left, right cond }
+	notCond struct{ x cond }
+
+	compareCond struct {
+		op          string
+		left, right operand
+	}
+	betweenCond struct {
+		x, low, high operand
+		not          bool
+	}
+	inCond struct {
+		x    operand
+		list []operand
+		not  bool
+	}
+	nullCond struct {
+		x   *columnRef
+		not bool
+	}
+	likeCond struct {
+		x   *columnRef
+		re  *regexp.Regexp
+		not bool
+	}
+)
+
+func (c andCond) eval(row Row) truth {
+	l := c.left.eval(row)
+	if l == tFalse {
+		return tFalse
+	}
+	return min(l, c.right.eval(row))
+}
+
+func (c orCond) eval(row Row) truth {
+	l := c.left.eval(row)
+	if l == tTrue {
+		return tTrue
+	}
+	return max(l, c.right.eval(row))
+}
+
+func (c notCond) eval(row Row) truth {
+	return tTrue - c.x.eval(row)
+}
+
+func negate(t truth, not bool) truth {
+	if not {
+		return tTrue - t
+	}
+	return t
+}
+
+func (c compareCond) eval(row Row) truth {
+	return anyPair(c.left.values(row), c.right.values(row), func(a, b any) truth { return ordered(a, b, c.op) })
+}
+
+func (c betweenCond) eval(row Row) truth {
+	low, high := c.low.values(row), c.high.values(row)
+	t := tFalse
+	for _, x := range c.x.values(row) {
+		ge := anyPair([]any{x}, low, func(a, b any) truth { return ordered(a, b, ">=") })
+		le := anyPair([]any{x}, high, func(a, b any) truth { return ordered(a, b, "<=") })
+		if t = max(t, min(ge, le)); t == tTrue {
+			break
+		}
+	}
+	return negate(t, c.not)
+}
+
+func (c inCond) eval(row Row) truth {
+	xs := c.x.values(row)
+	t := tFalse
+	for _, o := range c.list {
+		if t = max(t, anyPair(xs, o.values(row), func(a, b any) truth { return ordered(a, b, "=") })); t == tTrue {
+			break
+		}
+	}
+	return negate(t, c.not)
+}
+
+func (c nullCond) eval(row Row) truth {
+	null := false
+	for _, v := range c.x.collect(row, c.x.nullSteps) {
+		if v == nil {
+			null = true
+			break
+		}
+	}
+	return negate(truthOf(null), c.not)
+}
+
+func (c likeCond) eval(row Row) truth {
+	t := tFalse
+	for _, v := range c.x.collect(row, c.x.steps) {
+		if v == nil {
+			t = max(t, tUnknown)
+			continue
+		}
+		if c.x.kind.binary {
+			v = c.x.kind.value(v)
+		}
+		if c.re.MatchString(valueText(v)) {
+			t = tTrue
+			break
+		}
+	}
+	return negate(t, c.not)
+}
+
+// anyPair is true when f is true for a pair of values of a and b, a
+// column below a list having one value per element.
+func anyPair(a, b []any, f func(a, b any) truth) truth {
+	t := tFalse
+	for _, x := range a {
+		for _, y := range b {
+			if t = max(t, f(x, y)); t == tTrue {
+				return t
+			}
+		}
+	}
+	return t
+}
+
+// ordered compares a and b with op, unknown when either is null.
+func ordered(a, b any, op string) truth {
+	n, ok := compareValues(a, b)
+	if !ok {
+		return tUnknown
+	}
+	return truthOf(compareResult(op, n))
+}
+
+func compareResult(op string, n int) bool {
+	switch op {
+	case "=":
+		return n == 0
+	case "!=":
+		return n != 0
+	case "<":
+		return n < 0
+	case "<=":
+		return n <= 0
+	case ">":
+		return n > 0
+	case ">=":
+		return n >= 0
+	}
+	return false
+}
+
+// compareValues compares two bound values of the same kind, ok is false
+// when either is null or NaN.
+func compareValues(a, b any) (int, bool) {
+	switch a := a.(type) {
+	case *big.Rat:
+		if b, ok := b.(*big.Rat); ok {
+			return a.Cmp(b), true
+		}
+	case float64:
+		if b, ok := b.(float64); ok && !math.IsNaN(a) && !math.IsNaN(b) {
+			switch {
+			case a < b:
+				return -1, true
+			case a > b:
+				return 1, true
+			}
+			return 0, true
+		}
+	case string:
+		if b, ok := b.(string); ok {
+			return strings.Compare(a, b), true
+		}
+	case bool:
+		if b, ok := b.(bool); ok {
+			switch {
+			case a == b:
+				return 0, true
+			case b:
+				return -1, true
+			}
+			return 1, true
+		}
+	case time.Time:
+		if b, ok := b.(time.Time); ok {
+			return a.Compare(b), true
+		}
+	}
+	return 0, false
+}
+
+// valueText is a value as cat prints it, for LIKE and regular
+// expressions.
+func valueText(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case json.RawMessage:
+		return string(v)
+	}
+	return fmt.Sprint(v)
+}
+
+// operand is a column or a literal converted to the kind of the column it
+// is compared with.
+type operand interface {
+	values(row Row) []any
+}
+
+type literal struct{ value any }
+
+func (l literal) values(Row) []any {
+	return []any{l.value}
+}
+
+// kindClass is how the values of a column are compared.
+type kindClass int
+
+const (
+	kindString kindClass = iota
+	kindNumber
+	kindFloat
+	kindBool
+	kindTimestamp
+	kindDate
+	kindTime
+)
+
+type kind struct {
+	class kindClass
+	// bits is the size of floats, 32 or 64.
+	bits int
+	// adjusted is set for timestamps adjusted to UTC.
+	adjusted bool
+	// binary is set for byte arrays without a logical type, which rows
+	// and statistics render as hex. They are compared as the string of
+	// their bytes.
+	binary bool
+}
+
+func (k kind) String() string {
+	switch k.class {
+	case kindNumber, kindFloat:
+		return "number"
+	case kindBool:
+		return "boolean"
+	case kindTimestamp:
+		return "timestamp"
+	case kindDate:
+		return "date"
+	case kindTime:
+		return "time"
+	}
+	return "string"
+}
+
+// columnKind returns how the values of a leaf column are compared, by its
+// logical type or else its physical type.
+func columnKind(n *schema.PrimitiveNode) kind {
+	switch lt := n.LogicalType().(type) {
+	case *schema.DecimalLogicalType, *schema.IntLogicalType:
+		return kind{class: kindNumber}
+	case schema.Float16LogicalType:
+		return kind{class: kindFloat, bits: 32}
+	case schema.DateLogicalType:
+		return kind{class: kindDate}
+	case *schema.TimeLogicalType:
+		return kind{class: kindTime}
+	case *schema.TimestampLogicalType:
+		return kind{class: kindTimestamp, adjusted: lt.IsAdjustedToUTC()}
+	case schema.StringLogicalType, schema.EnumLogicalType, schema.JSONLogicalType,
+		schema.BSONLogicalType, schema.UUIDLogicalType, schema.IntervalLogicalType:
+		return kind{class: kindString}
+	}
+	switch n.PhysicalType() {
+	case parquet.Types.Boolean:
+		return kind{class: kindBool}
+	case parquet.Types.Int32, parquet.Types.Int64:
+		return kind{class: kindNumber}
+	case parquet.Types.Float:
+		return kind{class: kindFloat, bits: 32}
+	case parquet.Types.Double:
+		return kind{class: kindFloat, bits: 64}
+	case parquet.Types.ByteArray, parquet.Types.FixedLenByteArray:
+		return kind{class: kindString, binary: n.ConvertedType() != schema.ConvertedTypes.UTF8}
+	}
+	return kind{class: kindString}
+}
+
+// stepKind is how a column path goes down one level of a row.
+type stepKind int
+
+const (
+	// stepField takes the field or map entry of a Row by name.
+	stepField stepKind = iota
+	// stepEach goes into every element of a list.
+	stepEach
+	// stepKeys takes the keys of every entry of a map.
+	stepKeys
+	// stepValues takes the values of every entry of a map.
+	stepValues
+)
+
+type step struct {
+	kind stepKind
+	name string
+}
+
+// columnRef is a column of the expression resolved against the schema.
+type columnRef struct {
+	name  string
+	steps []step
+	// nullSteps stops at a list instead of going into its elements, for
+	// IS NULL.
+	nullSteps []step
+	// node is the schema node reached, a leaf unless the column is only
+	// used with IS NULL.
+	node schema.Node
+	kind kind
+	// column is the index of the leaf column, -1 for groups.
+	column int
+	// repeated is set below a list or map, where the column has many
+	// values per row.
+	repeated bool
+}
+
+// collect returns the values reached by steps from v, nil for nulls and
+// missing fields.
+func collect(v any, steps []step, out []any) []any {
+	if len(steps) == 0 || v == nil {
+		return append(out, v)
+	}
+	s, rest := steps[0], steps[1:]
+	switch s.kind {
+	case stepField:
+		row, _ := v.(Row)
+		for _, f := range row {
+			if f.Name == s.name {
+				return collect(f.Value, rest, out)
+			}
+		}
+		return append(out, nil)
+	case stepEach:
+		list, _ := v.([]any)
+		for _, e := range list {
+			out = collect(e, rest, out)
+		}
+	case stepKeys, stepValues:
+		row, _ := v.(Row)
+		for _, f := range row {
+			if s.kind == stepKeys {
+				out = collect(f.Name, rest, out)
+			} else {
+				out = collect(f.Value, rest, out)
+			}
+		}
+	}
+	return out
+}
+
+func (c *columnRef) collect(row Row, steps []step) []any {
+	return collect(row, steps, nil)
+}
+
+func (c *columnRef) values(row Row) []any {
+	vals := c.collect(row, c.steps)
+	for i, v := range vals {
+		vals[i] = c.kind.value(v)
+	}
+	return vals
+}
+
+// value converts a value of a row to its bound form, nil when it is null
+// or does not parse.
+func (k kind) value(v any) any {
+	if v == nil {
+		return nil
+	}
+	switch k.class {
+	case kindNumber:
+		r := new(big.Rat)
+		switch v := v.(type) {
+		case int32:
+			return r.SetInt64(int64(v))
+		case int64:
+			return r.SetInt64(v)
+		case uint32:
+			return r.SetInt64(int64(v))
+		case uint64:
+			return r.SetInt(new(big.Int).SetUint64(v))
+		}
+		if _, ok := r.SetString(valueText(v)); ok {
+			return r
+		}
+	case kindFloat:
+		switch v := v.(type) {
+		case float32:
+			return float64(v)
+		case float64:
+			return v
+		}
+		if f, err := strconv.ParseFloat(valueText(v), 64); err == nil {
+			return f
+		}
+	case kindBool:
+		if b, ok := v.(bool); ok {
+			return b
+		}
+	case kindTimestamp, kindDate, kindTime:
+		s, _ := v.(string)
+		layout := time.DateOnly
+		switch {
+		case k.class == kindTime:
+			layout, s = "15:04:05.999999999", strings.TrimSuffix(s, "Z")
+		case k.class == kindTimestamp && k.adjusted:
+			layout = time.RFC3339Nano
+		case k.class == kindTimestamp:
+			layout = "2006-01-02T15:04:05.999999999"
+		}
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	default:
+		s := valueText(v)
+		if k.binary {
+			if b, err := hex.DecodeString(strings.ReplaceAll(s, " ", "")); err == nil {
+				return string(b)
+			}
+		}
+		return s
+	}
+	return nil
+}
+
+// timestampLayouts are the accepted layouts of date and timestamp
+// literals without a zone.
+var timestampLayouts = []string{
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+	time.DateOnly,
+}
+
+// literal converts a literal to the kind of the column it is compared
+// with.
+func (k kind) literal(l predicate.Literal, loc *time.Location) (any, error) {
+	if l.Kind == predicate.Null {
+		return nil, nil
+	}
+	switch k.class {
+	case kindNumber:
+		if l.Kind != predicate.Bool {
+			if r, ok := new(big.Rat).SetString(l.Text); ok {
+				return r, nil
+			}
+		}
+	case kindFloat:
+		if l.Kind != predicate.Bool {
+			if f, err := strconv.ParseFloat(l.Text, k.bits); err == nil {
+				return f, nil
+			}
+		}
+	case kindBool:
+		if l.Kind == predicate.Bool {
+			return l.Bool, nil
+		}
+		if l.Kind == predicate.String {
+			if b, err := strconv.ParseBool(l.Text); err == nil {
+				return b, nil
+			}
+		}
+	case kindString:
+		if l.Kind != predicate.Bool {
+			return l.Text, nil
+		}
+	case kindTimestamp:
+		if l.Kind != predicate.String {
+			break
+		}
+		if t, err := time.Parse(time.RFC3339Nano, l.Text); err == nil {
+			if !k.adjusted {
+				// compare with the local date and time as written
+				t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+			}
+			return t, nil
+		}
+		in := time.UTC
+		if k.adjusted {
+			in = loc
+		}
+		for _, layout := range timestampLayouts {
+			if t, err := time.ParseInLocation(layout, l.Text, in); err == nil {
+				return t, nil
+			}
+		}
+	case kindDate:
+		if l.Kind != predicate.String {
+			break
+		}
+		if t, err := time.Parse(time.DateOnly, l.Text); err == nil {
+			return t, nil
+		}
+	case kindTime:
+		if l.Kind != predicate.String {
+			break
+		}
+		if t, err := time.Parse("15:04:05.999999999", strings.TrimSuffix(l.Text, "Z")); err == nil {
+			return t, nil
+		}
+	}
+	return nil, fmt.Errorf("%s is not a %s", l, k)
+}
+
+// binder resolves the columns of an expression and converts its literals.
+type binder struct {
+	sc  *schema.Schema
+	loc *time.Location
+	// need are the column path prefixes of the leaf columns read.
+	need [][]string
+}
+
+func (b *binder) bind(e predicate.Expr) (cond, error) {
+	switch e := e.(type) {
+	case predicate.And:
+		l, r, err := b.bindPair(e.Left, e.Right)
+		return andCond{l, r}, err
+	case predicate.Or:
+		l, r, err := b.bindPair(e.Left, e.Right)
+		return orCond{l, r}, err
+	case predicate.Not:
+		x, err := b.bind(e.X)
+		return notCond{x}, err
+	case predicate.Compare:
+		ops, err := b.bindOperands(e.Op, e.Left, e.Right)
+		if err != nil {
+			return nil, err
+		}
+		return compareCond{e.Op, ops[0], ops[1]}, nil
+	case predicate.Between:
+		ops, err := b.bindOperands("BETWEEN", e.X, e.Low, e.High)
+		if err != nil {
+			return nil, err
+		}
+		return betweenCond{ops[0], ops[1], ops[2], e.Not}, nil
+	case predicate.In:
+		ops, err := b.bindOperands("=", append([]predicate.Operand{e.X}, e.List...)...)
+		if err != nil {
+			return nil, err
+		}
+		return inCond{ops[0], ops[1:], e.Not}, nil
+	case predicate.IsNull:
+		c, err := b.column(e.X, false)
+		if err != nil {
+			return nil, err
+		}
+		return nullCond{c, e.Not}, nil
+	case predicate.Like:
+		c, err := b.column(e.X, true)
+		if err != nil {
+			return nil, err
+		}
+		pattern := e.Pattern
+		if !e.Regexp {
+			if pattern, err = likePattern(pattern); err != nil {
+				return nil, fmt.Errorf("invalid pattern in %s: %w", e, err)
+			}
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern in %s: %w", e, err)
+		}
+		return likeCond{c, re, e.Not}, nil
+	}
+	return nil, fmt.Errorf("unsupported expression %s", e)
+}
+
+func (b *binder) bindPair(left, right predicate.Expr) (cond, cond, error) {
+	l, err := b.bind(left)
+	if err != nil {
+		return nil, nil, err
+	}
+	r, err := b.bind(right)
+	if err != nil {
+		return nil, nil, err
+	}
+	return l, r, nil
+}
+
+// bindOperands binds operands compared with each other: the columns must
+// be of the same kind and the literals are converted to it.
+func (b *binder) bindOperands(op string, operands ...predicate.Operand) ([]operand, error) {
+	var k *kind
+	var first *columnRef
+	out := make([]operand, len(operands))
+	for i, o := range operands {
+		if _, ok := o.(predicate.Column); !ok {
+			continue
+		}
+		c, err := b.column(o, true)
+		if err != nil {
+			return nil, err
+		}
+		if k == nil {
+			k, first = &c.kind, c
+		} else if c.kind.class != k.class {
+			return nil, fmt.Errorf("cannot compare %s column %s with %s column %s", k, first.name, c.kind, c.name)
+		}
+		out[i] = c
+	}
+	if k == nil {
+		return nil, fmt.Errorf("%s compares no column", operands[0])
+	}
+	if k.class == kindBool && op != "=" && op != "!=" {
+		return nil, fmt.Errorf("boolean column %s cannot be compared with %s", first.name, op)
+	}
+	for i, o := range operands {
+		l, ok := o.(predicate.Literal)
+		if !ok {
+			continue
+		}
+		v, err := k.literal(l, b.loc)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", first.name, err)
+		}
+		out[i] = literal{v}
+	}
+	return out, nil
+}
+
+// column resolves a column operand. leaf requires a primitive column,
+// groups, lists and maps are allowed for IS NULL.
+func (b *binder) column(o predicate.Operand, leaf bool) (*columnRef, error) {
+	col, ok := o.(predicate.Column)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a column", o)
+	}
+	c := &columnRef{name: col.String()}
+	segs := col.Path
+	var node schema.Node = b.sc.Root()
+	var prefix []string
+	explicit := false
+	for len(segs) > 0 {
+		g, ok := node.(*schema.GroupNode)
+		if !ok {
+			return nil, fmt.Errorf("unknown column %s: %s is not a group", c.name, node.Name())
+		}
+		idx := g.FieldIndexByName(segs[0])
+		switch {
+		case isMapGroup(g):
+			kv := g.Field(0).(*schema.GroupNode)
+			if segs[0] == kv.Name() && len(segs) > 1 {
+				// all the keys or values of the map
+				i := kv.FieldIndexByName(segs[1])
+				if i < 0 {
+					return nil, fmt.Errorf("unknown column %s: no %s in map %s", c.name, segs[1], g.Name())
+				}
+				kindOf := stepKeys
+				if i > 0 {
+					kindOf = stepValues
+				}
+				c.steps = append(c.steps, step{kind: kindOf})
+				node, prefix, segs = kv.Field(i), append(prefix, kv.Name(), segs[1]), segs[2:]
+			} else {
+				if kv.NumFields() < 2 {
+					return nil, fmt.Errorf("unknown column %s: map %s has no values", c.name, g.Name())
+				}
+				// the entry of a key, which also needs the key column
+				b.need = append(b.need, append(slices.Clone(prefix), kv.Name(), kv.Field(0).Name()))
+				c.steps = append(c.steps, step{kind: stepField, name: segs[0]})
+				node, prefix, segs = kv.Field(1), append(prefix, kv.Name(), kv.Field(1).Name()), segs[1:]
+			}
+		case idx < 0:
+			return nil, fmt.Errorf("unknown column %s", c.name)
+		default:
+			c.steps = append(c.steps, step{kind: stepField, name: segs[0]})
+			node, prefix, segs = g.Field(idx), append(prefix, segs[0]), segs[1:]
+		}
+		c.nullSteps = slices.Clone(c.steps)
+		node, prefix, segs, explicit = b.elements(c, node, prefix, segs)
+		if explicit {
+			c.nullSteps = slices.Clone(c.steps)
+		}
+	}
+	c.node = node
+	b.need = append(b.need, prefix)
+	c.column = -1
+	if p, ok := node.(*schema.PrimitiveNode); ok {
+		c.kind = columnKind(p)
+		for i := range b.sc.NumColumns() {
+			if col := b.sc.Column(i); slices.Equal(col.ColumnPath(), prefix) {
+				c.column, c.repeated = i, col.MaxRepetitionLevel() > 0
+			}
+		}
+	} else if leaf {
+		return nil, fmt.Errorf("column %s is a group, it can only be tested with IS NULL", c.name)
+	}
+	return c, nil
+}
+
+// elements goes into the elements of node when it is repeated or a LIST,
+// skipping the names of the repeated group and the element when segs
+// starts with them, which explicit reports.
+func (b *binder) elements(c *columnRef, node schema.Node, prefix, segs []string) (schema.Node, []string, []string, bool) {
+	if node.RepetitionType() == parquet.Repetitions.Repeated {
+		c.steps = append(c.steps, step{kind: stepEach})
+		return node, prefix, segs, false
+	}
+	g, ok := node.(*schema.GroupNode)
+	if !ok || !isListGroup(g) {
+		return node, prefix, segs, false
+	}
+	c.steps = append(c.steps, step{kind: stepEach})
+	rep := g.Field(0)
+	elem, threeLevel := listElement(g)
+	prefix = append(prefix, rep.Name())
+	explicit := false
+	if len(segs) > 0 && segs[0] == rep.Name() {
+		segs, explicit = segs[1:], true
+	}
+	if threeLevel {
+		prefix = append(prefix, elem.Name())
+		if explicit && len(segs) > 0 && segs[0] == elem.Name() {
+			segs = segs[1:]
+		}
+		// a list of lists
+		node, prefix, segs, _ = b.elements(c, elem, prefix, segs)
+		return node, prefix, segs, explicit
+	}
+	return elem, prefix, segs, explicit
+}
+
+// likePattern converts a LIKE pattern to a regular expression. A
+// backslash escapes %, _ and itself.
+func likePattern(p string) (string, error) {
+	var b strings.Builder
+	b.WriteString("(?s)^")
+	escaped := false
+	for _, r := range p {
+		switch {
+		case escaped:
+			if r != '%' && r != '_' && r != '\\' {
+				return "", fmt.Errorf("\\%c is not an escape, only %%, _ and \\ are", r)
+			}
+			b.WriteString(regexp.QuoteMeta(string(r)))
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '%':
+			b.WriteString(".*")
+		case r == '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	if escaped {
+		return "", errors.New("the pattern ends with an escape")
+	}
+	b.WriteString("$")
+	return b.String(), nil
+}
@@ -0,0 +1,180 @@
+package parquettools
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow/go/v17/parquet"
+	"github.com/apache/arrow/go/v17/parquet/schema"
+)
+
+// whereSchema has a column of each kind the predicates compare.
+func whereSchema(t *testing.T) *schema.Schema {
+	t.Helper()
+	must := func(n schema.Node, err error) schema.Node {
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	req, opt, rep := parquet.Repetitions.Required, parquet.Repetitions.Optional, parquet.Repetitions.Repeated
+	dec := must(schema.NewPrimitiveNodeLogical("amount", opt, schema.NewDecimalLogicalType(10, 2), parquet.Types.Int64, 0, -1))
+	elem := must(schema.NewPrimitiveNodeLogical("element", opt, schema.StringLogicalType{}, parquet.Types.ByteArray, 0, -1))
+	list := must(schema.NewGroupNode("list", rep, schema.FieldList{elem}, -1))
+	root := must(schema.NewGroupNode("schema", req, schema.FieldList{
+		must(schema.NewPrimitiveNode("id", req, parquet.Types.Int64, -1, -1)),
+		must(schema.NewPrimitiveNodeLogical("name", opt, schema.StringLogicalType{}, parquet.Types.ByteArray, 0, -1)),
+		dec,
+		must(schema.NewPrimitiveNode("score", opt, parquet.Types.Double, -1, -1)),
+		must(schema.NewPrimitiveNode("flag", opt, parquet.Types.Boolean, -1, -1)),
+		must(schema.NewPrimitiveNodeLogical("ts", opt, schema.NewTimestampLogicalType(true, schema.TimeUnitMillis), parquet.Types.Int64, 0, -1)),
+		must(schema.NewPrimitiveNodeLogical("day", opt, schema.DateLogicalType{}, parquet.Types.Int32, 0, -1)),
+		must(schema.NewPrimitiveNode("raw", opt, parquet.Types.ByteArray, -1, -1)),
+		must(schema.NewGroupNodeLogical("tags", opt, schema.FieldList{list}, schema.ListLogicalType{}, -1)),
+	}, -1))
+	return schema.NewSchema(root.(*schema.GroupNode))
+}
+
+func TestPredicateMatch(t *testing.T) {
+	sc := whereSchema(t)
+	// rows hold the values as RowIterator renders them
+	row := Row{
+		{"id", int64(1)},
+		{"name", nil},
+		{"amount", "12.50"},
+		{"score", 0.5},
+		{"flag", true},
+		{"ts", "2024-01-02T03:04:05Z"},
+		{"day", "2024-01-02"},
+		{"raw", "61 62"},
+		{"tags", []any{"a", nil}},
+	}
+	named := Row{
+		{"id", int64(2)},
+		{"name", `50%_off\`},
+		{"amount", nil},
+		{"score", nil},
+		{"flag", false},
+		{"ts", nil},
+		{"day", nil},
+		{"raw", nil},
+		{"tags", nil},
+	}
+	tests := []struct {
+		expr string
+		row  Row
+		want bool
+	}{
+		// comparisons with null are unknown, and so is their negation
+		{"name = 'x'", row, false},
+		{"NOT name = 'x'", row, false},
+		{"name != 'x'", row, false},
+		{"name = NULL", row, false},
+		{"NOT name = NULL", row, false},
+		{"name IS NULL", row, true},
+		{"name IS NOT NULL", row, false},
+		{"NOT name IS NULL", row, false},
+		// three-valued AND and OR
+		{"name = 'x' OR id = 1", row, true},
+		{"name = 'x' OR id = 2", row, false},
+		{"NOT (name = 'x' OR id = 2)", row, false},
+		{"name = 'x' AND id = 2", row, false},
+		{"NOT (name = 'x' AND id = 2)", row, true},
+		{"NOT (name = 'x' AND id = 1)", row, false},
+		{"name BETWEEN 'a' AND 'z'", row, false},
+		{"name NOT BETWEEN 'a' AND 'z'", row, false},
+		{"name LIKE '%'", row, false},
+		{"name NOT LIKE '%'", row, false},
+		// IN with NULL in the list is unknown unless a value matches
+		{"id IN (1, NULL)", row, true},
+		{"id IN (2, NULL)", row, false},
+		{"NOT id IN (2, NULL)", row, false},
+		{"id NOT IN (2, NULL)", row, false},
+		{"id NOT IN (1, NULL)", row, false},
+		{"id NOT IN (2, 3)", row, true},
+		{"name IN ('x', NULL)", row, false},
+		// precedence
+		{"id = 2 AND flag OR id = 1", row, true},
+		{"id = 2 AND (flag OR id = 1)", row, false},
+		{"NOT id = 2 AND flag", row, true},
+		{"id BETWEEN 0 AND 1 AND flag", row, true},
+		// typed comparisons
+		{"amount = 12.5", row, true},
+		{"amount > 12.499999999999999999", row, true},
+		{"amount BETWEEN '12' AND 13", row, true},
+		{"score < 1", row, true},
+		{"flag", row, true},
+		{"flag = 'true'", row, true},
+		{"flag", named, false},
+		{"NOT flag", named, true},
+		{"ts > '2024-01-02'", row, true},
+		{"ts = '2024-01-02T03:04:05Z'", row, true},
+		{"ts < '2024-01-02 03:04:06'", row, true},
+		{"day = '2024-01-02'", row, true},
+		{"raw = 'ab'", row, true},
+		{"raw LIKE 'a%'", row, true},
+		{"tags = 'a'", row, true},
+		{"tags = 'b'", row, false},
+		{"tags.list.element = 'a'", row, true},
+		{"tags IS NULL", named, true},
+		// LIKE escapes
+		{`name LIKE '50\%\_off\\'`, named, true},
+		{`name LIKE '50\%%'`, named, true},
+		{`name LIKE '5_\%_%'`, named, true},
+		{`name LIKE '50\_%'`, named, false},
+		{`name LIKE '50%off%'`, named, true},
+		{`name LIKE '50.%'`, named, false},
+		{`name LIKE '50%_OFF%'`, named, false},
+		{`name REGEXP '^50%'`, named, true},
+		{`name ~ '\\$'`, named, true},
+	}
+	for _, tt := range tests {
+		p, err := NewPredicate(tt.expr, sc, time.UTC)
+		if err != nil {
+			t.Errorf("%s: %v", tt.expr, err)
+			continue
+		}
+		if got := p.Match(tt.row); got != tt.want {
+			t.Errorf("%s on %v: got %v, want %v", tt.expr, tt.row, got, tt.want)
+		}
+	}
+}
+
+func TestPredicateErrors(t *testing.T) {
+	sc := whereSchema(t)
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"nope = 1", "unknown column nope"},
+		{"id.x = 1", "unknown column id.x: id is not a group"},
+		{"tags = 1 AND tags.list.nope = 1", "unknown column tags.list.nope"},
+		{"tags.list = 'a' OR tags = 1 AND tags > 'x' AND id = 'abc'", "column id: 'abc' is not a number"},
+		{"id = name", "cannot compare number column id with string column name"},
+		{"1 = 2", "1 compares no column"},
+		{"flag < true", "boolean column flag cannot be compared with <"},
+		{"flag = 2", "column flag: 2 is not a boolean"},
+		{"ts > 'yesterday'", "column ts: 'yesterday' is not a timestamp"},
+		{"day = 1", "column day: 1 is not a date"},
+		{"name = TRUE", "column name: TRUE is not a string"},
+		{"tags.list = 'a' AND schema = 1", "unknown column schema"},
+		{"id BETWEEN 1 AND 'x'", "column id: 'x' is not a number"},
+		{"id IN (1, 'x')", "column id: 'x' is not a number"},
+		{`name LIKE 'a\'`, "the pattern ends with an escape"},
+		{`name LIKE '\a'`, `\a is not an escape`},
+		{"name REGEXP '('", "invalid pattern in name REGEXP '('"},
+		{"name = ", "syntax error at offset 7"},
+	}
+	for _, tt := range tests {
+		_, err := NewPredicate(tt.expr, sc, time.UTC)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %v, want an error containing %q", tt.expr, err, tt.want)
+		}
+	}
+
+	// groups only take IS NULL
+	if _, err := NewPredicate("tags IS NULL", sc, time.UTC); err != nil {
+		t.Errorf("tags IS NULL: %v", err)
+	}
+}